@@ -0,0 +1,132 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"../app"
+	// "github.com/Xero67/web-fire-family/app"
+)
+
+// LowStock is raised when a change takes a product's quantity from above its NotificationQuantity
+// to at or below it.
+type LowStock struct {
+	SKU         int    `json:"sku"`
+	ProductName string `json:"productname"`
+	Quantity    int    `json:"quantity"`
+	Threshold   int    `json:"notificationquantity"`
+	DateCreated string `json:"datecreated"`
+}
+
+func (a LowStock) String() string {
+	return fmt.Sprintf("Low stock: %v (sku %v) is down to %v, notification quantity is %v", a.ProductName, a.SKU, a.Quantity, a.Threshold)
+}
+
+// Crossed reports whether going from before to after crosses threshold on the way down. Products with
+// a threshold of 0 or less never alert. Only the change that crosses alerts, the scans after it don't,
+// until the quantity has gone back above the threshold.
+func Crossed(threshold int, before int, after int) bool {
+	return threshold > 0 && before > threshold && after <= threshold
+}
+
+// Notifier sends low stock alerts somewhere a person will see them.
+type Notifier interface {
+	Notify(a LowStock) error
+}
+
+// LogNotifier writes alerts to the application log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(a LowStock) error {
+	log.Println(a)
+	return nil
+}
+
+// WebhookNotifier POSTs each alert as JSON to URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n WebhookNotifier) Notify(a LowStock) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %v answered %v", n.URL, resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier emails each alert to To. Username and Password are optional, Addr is host:port.
+type SMTPNotifier struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n SMTPNotifier) Notify(a LowStock) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		host := n.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+	return smtp.SendMail(n.Addr, auth, n.From, n.To, Message(n.From, n.To, a))
+}
+
+// Message formats the email SMTPNotifier sends for a.
+func Message(from string, to []string, a LowStock) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %v\r\n", from)
+	fmt.Fprintf(&b, "To: %v\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: Low stock: %v (sku %v)\r\n", a.ProductName, a.SKU)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%v\r\n", a)
+	return b.Bytes()
+}
+
+// FromSettings builds the notifier picked in config.yml.
+func FromSettings(s app.Alerts) (Notifier, error) {
+	switch s.Notifier {
+	case "", "log":
+		return LogNotifier{}, nil
+	case "webhook":
+		if s.WebhookURL == "" {
+			return nil, fmt.Errorf("alertnotifier webhook needs an alertwebhook URL")
+		}
+		return WebhookNotifier{URL: s.WebhookURL}, nil
+	case "smtp":
+		if s.SMTPHost == "" || s.From == "" || len(s.To) == 0 {
+			return nil, fmt.Errorf("alertnotifier smtp needs smtphost, alertfrom and alertto")
+		}
+		port := s.SMTPPort
+		if port == 0 {
+			port = 25
+		}
+		return SMTPNotifier{Addr: s.SMTPHost + ":" + strconv.Itoa(port), Username: s.SMTPUser, Password: s.SMTPPass, From: s.From, To: s.To}, nil
+	default:
+		return nil, fmt.Errorf("unknown alertnotifier %q, expected log, webhook or smtp", s.Notifier)
+	}
+}
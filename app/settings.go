@@ -13,6 +13,8 @@ type Web struct {
 	Port int `yaml:"webport,omitempty"`
 }
 
+// Dbdriver holds the database settings. Driver is "mysql" (the default), "postgres" or "sqlite",
+// in which case Database is the path of the local database file and the other fields are ignored.
 type Dbdriver struct {
 	Database string `yaml:"database,omitempty"`
 	Driver   string `yaml:"driver,omitempty"`
@@ -20,6 +22,26 @@ type Dbdriver struct {
 	Dbuser   string `yaml:"user,omitempty"`
 	Dbpass   string `yaml:"pass,omitempty"`
 	Port     int    `yaml:"dbport,omitempty"`
+	SSLMode  string `yaml:"sslmode,omitempty"` // postgres only, defaults to disable
+}
+
+// Stock holds the inventory rules. NegativeStock is the policy for products that don't set their own:
+// "reject" (the default), "allow" or "clamp".
+type Stock struct {
+	NegativeStock string `yaml:"negativestock,omitempty"`
+}
+
+// Alerts picks where low stock alerts are sent. Notifier is "log" (the default), "webhook", which POSTs
+// each alert to WebhookURL, or "smtp", which emails To.
+type Alerts struct {
+	Notifier   string   `yaml:"alertnotifier,omitempty"`
+	WebhookURL string   `yaml:"alertwebhook,omitempty"`
+	SMTPHost   string   `yaml:"smtphost,omitempty"`
+	SMTPPort   int      `yaml:"smtpport,omitempty"`
+	SMTPUser   string   `yaml:"smtpuser,omitempty"`
+	SMTPPass   string   `yaml:"smtppass,omitempty"`
+	From       string   `yaml:"alertfrom,omitempty"`
+	To         []string `yaml:"alertto,omitempty"`
 }
 
 func (d Dbdriver) LoadSettingsDefault() Dbdriver {
@@ -29,7 +51,7 @@ func (d Dbdriver) LoadSettingsDefault() Dbdriver {
 	dat, err := ioutil.ReadFile("github.com/Xero67/web-fire-family/config.yml")
 	yaml.Unmarshal(dat, &d)
 	if err != nil {
-		log.Fatal("cannot unmarshal data %v", err)
+		log.Fatalf("cannot unmarshal data %v", err)
 	}
 	return d
 }
@@ -39,7 +61,7 @@ func (web Web) loadSettingsDefault() Web {
 	dat, err := ioutil.ReadFile("github.com/Xero67/web-fire-family/config.yml")
 	yaml.Unmarshal(dat, &web)
 	if err != nil {
-		log.Fatal("cannot unmarshal data %v", err)
+		log.Fatalf("cannot unmarshal data %v", err)
 	}
 	return web
 }
@@ -50,7 +72,7 @@ func (d Dbdriver) LoadSettings(s string) Dbdriver {
 	dat, err := ioutil.ReadFile(s)
 	yaml.Unmarshal(dat, &d)
 	if err != nil {
-		log.Fatal("cannot unmarshal data %v", err)
+		log.Fatalf("cannot unmarshal data %v", err)
 	}
 	return d
 }
@@ -61,7 +83,25 @@ func (web Web) LoadSettings(s string) Web {
 	dat, err := ioutil.ReadFile(s)
 	yaml.Unmarshal(dat, &web)
 	if err != nil {
-		log.Fatal("cannot unmarshal data %v", err)
+		log.Fatalf("cannot unmarshal data %v", err)
 	}
 	return web
 }
+
+func (stock Stock) LoadSettings(s string) Stock {
+	dat, err := ioutil.ReadFile(s)
+	yaml.Unmarshal(dat, &stock)
+	if err != nil {
+		log.Fatalf("cannot unmarshal data %v", err)
+	}
+	return stock
+}
+
+func (a Alerts) LoadSettings(s string) Alerts {
+	dat, err := ioutil.ReadFile(s)
+	yaml.Unmarshal(dat, &a)
+	if err != nil {
+		log.Fatalf("cannot unmarshal data %v", err)
+	}
+	return a
+}
@@ -0,0 +1,140 @@
+package labels
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"../models"
+	// "github.com/Xero67/web-fire-family/models"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+)
+
+// Barcode kinds a label can carry
+const (
+	Code128 = "code128"
+	QR      = "qr"
+)
+
+// Label is what gets printed for one product: its name, a line of details and a barcode of its SKU.
+// Sizes are in printer dots, the defaults fit 2" x 1" stock on a 203 dpi Zebra.
+type Label struct {
+	Title   string
+	Details string
+	Caption string
+	Code    string
+	Kind    string
+	Width   int
+	Height  int
+}
+
+// DefaultWidth and DefaultHeight are 2" x 1" at 203 dpi
+const (
+	DefaultWidth  = 406
+	DefaultHeight = 203
+)
+
+// FromProduct builds the label for p with the given barcode kind, Code128 if kind is empty.
+func FromProduct(p *models.Product, kind string) (*Label, error) {
+	if kind == "" {
+		kind = Code128
+	}
+	if kind != Code128 && kind != QR {
+		return nil, fmt.Errorf("unknown barcode %q, expected code128 or qr", kind)
+	}
+
+	details := make([]string, 0, 3)
+	for _, d := range []string{p.Color, p.TrimColor, p.Size} {
+		if d = strings.TrimSpace(d); d != "" {
+			details = append(details, d)
+		}
+	}
+	sku := strconv.Itoa(p.SKU)
+	return &Label{
+		Title:   p.ProductName,
+		Details: strings.Join(details, " / "),
+		Caption: "SKU " + sku,
+		Code:    sku,
+		Kind:    kind,
+		Width:   DefaultWidth,
+		Height:  DefaultHeight,
+	}, nil
+}
+
+// Modules returns the barcode as a grid of dark (true) and light modules, one row for Code128. Every
+// renderer draws from this so they all print the same symbol.
+func (l *Label) Modules() ([][]bool, error) {
+	var bc barcode.Barcode
+	var err error
+	if l.Kind == QR {
+		bc, err = qr.Encode(l.Code, qr.M, qr.Auto)
+	} else {
+		bc, err = code128.Encode(l.Code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := bc.Bounds()
+	rows := b.Dy()
+	if l.Kind != QR {
+		rows = 1
+	}
+	grid := make([][]bool, rows)
+	for y := 0; y < rows; y++ {
+		grid[y] = make([]bool, b.Dx())
+		for x := 0; x < b.Dx(); x++ {
+			grid[y][x] = isDark(bc.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return grid, nil
+}
+
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+// layout is where each part of the label goes, shared by the PNG and SVG renderers
+type layout struct {
+	margin   int
+	textW    int // width available to the text
+	codeX    int
+	codeY    int
+	module   int // size of one module in dots
+	barH     int // bar height for Code128
+	captionY int
+}
+
+const labelMargin = 12
+
+func (l *Label) layout(grid [][]bool) layout {
+	lay := layout{margin: labelMargin, textW: l.Width - 2*labelMargin}
+	cols := len(grid[0])
+	if l.Kind == QR {
+		// QR square on the right, text on the left
+		side := l.Height - 2*labelMargin
+		lay.module = side / cols
+		if lay.module < 1 {
+			lay.module = 1
+		}
+		size := lay.module * cols
+		lay.codeX = l.Width - labelMargin - size
+		lay.codeY = (l.Height - size) / 2
+		lay.textW = lay.codeX - 2*labelMargin
+		lay.captionY = l.Height - labelMargin
+		return lay
+	}
+	lay.module = (l.Width - 2*labelMargin) / cols
+	if lay.module < 1 {
+		lay.module = 1
+	}
+	lay.codeX = (l.Width - lay.module*cols) / 2
+	lay.codeY = l.Height / 3
+	lay.captionY = l.Height - labelMargin
+	lay.barH = lay.captionY - 16 - lay.codeY
+	return lay
+}
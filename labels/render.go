@@ -0,0 +1,118 @@
+package labels
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// fit shortens s to at most n characters, marking the cut with "..."
+func fit(s string, n int) string {
+	if n < 4 || len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
+
+// PNG draws the label as a black and white image, one pixel per printer dot.
+func (l *Label) PNG(w io.Writer) error {
+	grid, err := l.Modules()
+	if err != nil {
+		return err
+	}
+	lay := l.layout(grid)
+
+	img := image.NewGray(image.Rect(0, 0, l.Width, l.Height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	face := basicfont.Face7x13
+	chars := lay.textW / 7
+	text := func(s string, x int, y int) {
+		d := &font.Drawer{Dst: img, Src: image.Black, Face: face, Dot: fixed.P(x, y)}
+		d.DrawString(fit(s, chars))
+	}
+	text(l.Title, lay.margin, lay.margin+11)
+	text(l.Details, lay.margin, lay.margin+28)
+
+	for y, row := range grid {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			r := image.Rect(lay.codeX+x*lay.module, lay.codeY+y*lay.module, lay.codeX+(x+1)*lay.module, lay.codeY+(y+1)*lay.module)
+			if l.Kind != QR {
+				r.Max.Y = lay.codeY + lay.barH
+			}
+			draw.Draw(img, r, image.NewUniform(color.Black), image.Point{}, draw.Src)
+		}
+	}
+	if l.Kind == QR {
+		text(l.Caption, lay.margin, lay.captionY)
+	} else {
+		d := &font.Drawer{Face: face}
+		x := (l.Width - d.MeasureString(l.Caption).Round()) / 2
+		text(l.Caption, x, lay.captionY)
+	}
+
+	return png.Encode(w, img)
+}
+
+// SVG writes the label as a scalable image sized in printer dots. Runs of dark modules are merged into
+// one rect each to keep the file small.
+func (l *Label) SVG(w io.Writer) error {
+	grid, err := l.Modules()
+	if err != nil {
+		return err
+	}
+	lay := l.layout(grid)
+	chars := lay.textW / 7
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", l.Width, l.Height, l.Width, l.Height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", l.Width, l.Height)
+	text := func(s string, x int, y int, size int, anchor string) {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-family="sans-serif" font-size="%d" text-anchor="%v">`, x, y, size, anchor)
+		xml.EscapeText(&b, []byte(fit(s, chars)))
+		b.WriteString("</text>\n")
+	}
+	text(l.Title, lay.margin, lay.margin+12, 16, "start")
+	text(l.Details, lay.margin, lay.margin+30, 12, "start")
+
+	b.WriteString(`<g fill="#000">` + "\n")
+	for y, row := range grid {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			h := lay.module
+			if l.Kind != QR {
+				h = lay.barH
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d"/>`+"\n", lay.codeX+x*lay.module, lay.codeY+y*lay.module, run*lay.module, h)
+			x += run - 1
+		}
+	}
+	b.WriteString("</g>\n")
+	if l.Kind == QR {
+		text(l.Caption, lay.margin, lay.captionY, 12, "start")
+	} else {
+		text(l.Caption, l.Width/2, lay.captionY, 12, "middle")
+	}
+	b.WriteString("</svg>\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,184 @@
+package labels
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Sheet is a page of die cut label stock. Every measurement is in inches, the unit Avery publishes its
+// templates in. Labels are filled left to right, then top to bottom.
+type Sheet struct {
+	PageWidth   float64 `json:"pagewidth"`
+	PageHeight  float64 `json:"pageheight"`
+	Rows        int     `json:"rows"`
+	Columns     int     `json:"columns"`
+	TopMargin   float64 `json:"topmargin"`
+	LeftMargin  float64 `json:"leftmargin"`
+	LabelWidth  float64 `json:"labelwidth"`
+	LabelHeight float64 `json:"labelheight"`
+	ColumnGap   float64 `json:"columngap"`
+	RowGap      float64 `json:"rowgap"`
+}
+
+// DefaultTemplate is the sheet used when a request doesn't name one
+const DefaultTemplate = "5160"
+
+// Templates are the common Avery letter size sheets. Most other brands sell stock with the same layout
+// under the same numbers.
+var Templates = map[string]Sheet{
+	// 30 per sheet, 1" x 2-5/8" address labels
+	"5160": {PageWidth: 8.5, PageHeight: 11, Rows: 10, Columns: 3, TopMargin: 0.5, LeftMargin: 0.1875, LabelWidth: 2.625, LabelHeight: 1, ColumnGap: 0.125},
+	// 10 per sheet, 2" x 4" shipping labels
+	"5163": {PageWidth: 8.5, PageHeight: 11, Rows: 5, Columns: 2, TopMargin: 0.5, LeftMargin: 0.15625, LabelWidth: 4, LabelHeight: 2, ColumnGap: 0.1875},
+	// 6 per sheet, 3-1/3" x 4" shipping labels
+	"5164": {PageWidth: 8.5, PageHeight: 11, Rows: 3, Columns: 2, TopMargin: 0.5, LeftMargin: 0.15625, LabelWidth: 4, LabelHeight: 10.0 / 3, ColumnGap: 0.1875},
+	// 80 per sheet, 1/2" x 1-3/4" return address labels
+	"5167": {PageWidth: 8.5, PageHeight: 11, Rows: 20, Columns: 4, TopMargin: 0.5, LeftMargin: 0.28125, LabelWidth: 1.75, LabelHeight: 0.5, ColumnGap: 0.3125},
+}
+
+// PerPage is how many labels fit on one sheet.
+func (s Sheet) PerPage() int {
+	return s.Rows * s.Columns
+}
+
+// Validate checks the sheet has a sensible size and that its labels fit on the page.
+func (s Sheet) Validate() error {
+	if s.Rows < 1 || s.Columns < 1 {
+		return fmt.Errorf("rows and columns must be at least 1")
+	}
+	if s.PageWidth <= 0 || s.PageHeight <= 0 || s.LabelWidth <= 0 || s.LabelHeight <= 0 {
+		return fmt.Errorf("page and label sizes must be greater than 0")
+	}
+	if s.TopMargin < 0 || s.LeftMargin < 0 || s.ColumnGap < 0 || s.RowGap < 0 {
+		return fmt.Errorf("margins and gaps can't be negative")
+	}
+	// a little slack for templates given as rounded decimals
+	const slack = 0.01
+	if s.LeftMargin+float64(s.Columns)*s.LabelWidth+float64(s.Columns-1)*s.ColumnGap > s.PageWidth+slack {
+		return fmt.Errorf("%d columns of %v\" labels don't fit across a %v\" page", s.Columns, s.LabelWidth, s.PageWidth)
+	}
+	if s.TopMargin+float64(s.Rows)*s.LabelHeight+float64(s.Rows-1)*s.RowGap > s.PageHeight+slack {
+		return fmt.Errorf("%d rows of %v\" labels don't fit down a %v\" page", s.Rows, s.LabelHeight, s.PageHeight)
+	}
+	return nil
+}
+
+// Pages is how many sheets n labels take when the first skip positions of the first sheet are used up.
+func (s Sheet) Pages(n int, skip int) int {
+	return (skip + n + s.PerPage() - 1) / s.PerPage()
+}
+
+// PDF writes the labels onto as many sheets as they need, starting skip positions into the first
+// sheet so a partly used sheet can be fed back through the printer. Print it at actual size, scaling to
+// fit the page moves every label off its die cut.
+func (s Sheet) PDF(w io.Writer, labels []*Label, skip int) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if skip < 0 || skip >= s.PerPage() {
+		return fmt.Errorf("skip must be between 0 and %d", s.PerPage()-1)
+	}
+
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "in", Size: gofpdf.SizeType{Wd: s.PageWidth, Ht: s.PageHeight}})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetFillColor(0, 0, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	for i, l := range labels {
+		pos := (skip + i) % s.PerPage()
+		if i == 0 || pos == 0 {
+			pdf.AddPage()
+		}
+		x := s.LeftMargin + float64(pos%s.Columns)*(s.LabelWidth+s.ColumnGap)
+		y := s.TopMargin + float64(pos/s.Columns)*(s.LabelHeight+s.RowGap)
+		if err := l.drawPDF(pdf, tr, x, y, s.LabelWidth, s.LabelHeight); err != nil {
+			return err
+		}
+	}
+	if len(labels) == 0 {
+		pdf.AddPage()
+	}
+	return pdf.Output(w)
+}
+
+// drawPDF draws the label into the w x h inch cell at x, y. Text is sized from the cell height so the
+// same label reads on 1/2" return address stock and 2" shipping labels.
+func (l *Label) drawPDF(pdf *gofpdf.Fpdf, tr func(string) string, x float64, y float64, w float64, h float64) error {
+	grid, err := l.Modules()
+	if err != nil {
+		return err
+	}
+
+	pad := math.Min(0.08, h*0.08)
+	titleSize := math.Max(5, math.Min(14, h*72*0.14)) // points
+	smallSize := titleSize * 0.8
+	line := func(pt float64) float64 { return pt / 72 * 1.15 }
+	text := func(s string, pt float64, tx float64, ty float64, maxW float64, centre bool) {
+		pdf.SetFont("Helvetica", "", pt)
+		s = tr(s)
+		if pdf.GetStringWidth(s) > maxW {
+			for len(s) > 0 && pdf.GetStringWidth(s+"...") > maxW {
+				s = s[:len(s)-1]
+			}
+			s += "..."
+		}
+		if centre {
+			tx += (maxW - pdf.GetStringWidth(s)) / 2
+		}
+		pdf.Text(tx, ty, s)
+	}
+
+	left, top, innerW, innerH := x+pad, y+pad, w-2*pad, h-2*pad
+	if l.Kind == QR {
+		// QR square on the right, text on the left
+		module := innerH / float64(len(grid))
+		size := module * float64(len(grid))
+		codeX := x + w - pad - size
+		for r, row := range grid {
+			for c, dark := range row {
+				if dark {
+					pdf.Rect(codeX+float64(c)*module, top+float64(r)*module, module, module, "F")
+				}
+			}
+		}
+		textW := codeX - pad - left
+		text(l.Title, titleSize, left, top+line(titleSize)*0.8, textW, false)
+		text(l.Details, smallSize, left, top+line(titleSize)+line(smallSize)*0.8, textW, false)
+		text(l.Caption, smallSize, left, y+h-pad, textW, false)
+		return pdf.Error()
+	}
+
+	// Code128: title and details across the top, bars in the middle and the caption underneath. The
+	// details line is dropped when it would leave the bars too short to scan.
+	header := line(titleSize)
+	showDetails := l.Details != "" && innerH-header-line(smallSize)*2 >= innerH*0.4
+	if showDetails {
+		header += line(smallSize)
+	}
+	barTop := top + header + pad/2
+	barH := y + h - pad - line(smallSize) - barTop
+	module := math.Min(innerW/float64(len(grid[0])), 0.02)
+	codeX := x + (w-module*float64(len(grid[0])))/2
+	row := grid[0]
+	for c := 0; c < len(row); c++ {
+		if !row[c] {
+			continue
+		}
+		run := 1
+		for c+run < len(row) && row[c+run] {
+			run++
+		}
+		pdf.Rect(codeX+float64(c)*module, barTop, float64(run)*module, barH, "F")
+		c += run - 1
+	}
+	text(l.Title, titleSize, left, top+line(titleSize)*0.8, innerW, false)
+	if showDetails {
+		text(l.Details, smallSize, left, top+line(titleSize)+line(smallSize)*0.8, innerW, false)
+	}
+	text(l.Caption, smallSize, left, y+h-pad, innerW, true)
+	return pdf.Error()
+}
@@ -0,0 +1,48 @@
+package labels
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// zplField escapes s for a ^FH field. ^ and ~ would otherwise start a new command.
+func zplField(s string) string {
+	return strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E").Replace(s)
+}
+
+// ZPL writes the label as a ZPL II program for Zebra printers. The printer draws the barcode itself,
+// which prints sharper than sending it an image.
+func (l *Label) ZPL(w io.Writer) error {
+	m := labelMargin
+	textW := l.Width - 2*m
+	var b strings.Builder
+	b.WriteString("^XA\n")
+	b.WriteString("^CI28\n") // UTF-8
+	fmt.Fprintf(&b, "^PW%d\n^LL%d\n", l.Width, l.Height)
+
+	grid, err := l.Modules()
+	if err != nil {
+		return err
+	}
+	if l.Kind == QR {
+		// ^BQ draws 5 dots per module, right aligned. The field origin is above the symbol by a few
+		// dots, which the -10 makes up for.
+		size := 5 * len(grid[0])
+		textW = l.Width - 3*m - size
+		fmt.Fprintf(&b, "^FO%d,%d^BQN,2,5^FDMA,%v^FS\n", l.Width-m-size, (l.Height-size)/2-10, zplField(l.Code))
+	} else {
+		// 2 dots per module, centred. The printer adds the quiet zone.
+		fmt.Fprintf(&b, "^FO%d,%d^BY2^BCN,%d,N,N,N^FD%v^FS\n", (l.Width-2*len(grid[0]))/2, l.Height/3, l.Height/3, zplField(l.Code))
+		fmt.Fprintf(&b, "^FO%d,%d^A0N,18,18^FB%d,1,0,C^FH^FD%v^FS\n", m, l.Height-m-18, textW, zplField(l.Caption))
+	}
+	fmt.Fprintf(&b, "^FO%d,%d^A0N,26,26^FB%d,1,0,L^FH^FD%v^FS\n", m, m, textW, zplField(l.Title))
+	fmt.Fprintf(&b, "^FO%d,%d^A0N,20,20^FB%d,1,0,L^FH^FD%v^FS\n", m, m+32, textW, zplField(l.Details))
+	if l.Kind == QR {
+		fmt.Fprintf(&b, "^FO%d,%d^A0N,18,18^FB%d,1,0,L^FH^FD%v^FS\n", m, l.Height-m-18, textW, zplField(l.Caption))
+	}
+	b.WriteString("^XZ\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
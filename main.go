@@ -1,32 +1,115 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
 
+	"./alerts"
 	"./app"
 	"./models"
 	"./routes"
+	"./webhooks"
+	// "github.com/Xero67/web-fire-family/alerts"
 	// "github.com/Xero67/web-fire-family/app"
 	// "github.com/Xero67/web-fire-family/models"
 	// "github.com/Xero67/web-fire-family/routes"
+	// "github.com/Xero67/web-fire-family/webhooks"
 )
 
 var Dbdriver app.Dbdriver
 var web app.Web
+var stock app.Stock
+var alertSettings app.Alerts
 
 func main() {
 	Dbdriver = Dbdriver.LoadSettings("./config.yml")
 	web = web.LoadSettings("./config.yml")
+	stock = stock.LoadSettings("./config.yml")
+	alertSettings = alertSettings.LoadSettings("./config.yml")
 	var addr string
 	addr = ":" + strconv.Itoa(web.Port)
+	dialect := models.DialectFor(Dbdriver.Driver)
+
+	// web-fire-family migrate up|down [steps]|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		db, err := models.OpenDB(&Dbdriver)
+		if err != nil {
+			log.Fatalf("Database wasn't initialized! %v", err)
+		}
+		if err := migrate(db, dialect, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	db, err := models.InitDB(&Dbdriver)
 	if err != nil {
-		fmt.Errorf("Database wasn't initialized!")
+		log.Fatalf("Database wasn't initialized! %v", err)
+	}
+
+	store := models.NewSQLStore(db, dialect)
+	if !models.ValidNegativeStock(stock.NegativeStock) {
+		log.Fatalf("Unknown negativestock policy %q, expected reject, allow or clamp", stock.NegativeStock)
+	}
+	store.NegativeStock = stock.NegativeStock
+
+	notifier, err := alerts.FromSettings(alertSettings)
+	if err != nil {
+		log.Fatal(err)
 	}
+	routes.SetNotifier(notifier)
+	routes.SetWebhooks(webhooks.NewDispatcher(store))
+
+	http.ListenAndServe(addr, routes.InitRoutes(store, store))
+}
 
-	env := models.Env{Db: db}
+func migrate(db *sql.DB, dialect models.Dialect, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
 
-	http.ListenAndServe(addr, routes.InitRoutes(env))
+	switch args[0] {
+	case "up":
+		done, err := models.MigrateUp(db, dialect)
+		for _, m := range done {
+			fmt.Printf("applied  %04d_%v\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		done, err := models.MigrateDown(db, dialect, steps)
+		for _, m := range done {
+			fmt.Printf("reverted %04d_%v\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		states, err := models.MigrationStatus(db, dialect)
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt
+			}
+			fmt.Printf("%04d_%-30v %v\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
 }
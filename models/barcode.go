@@ -0,0 +1,208 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Barcode - a code printed on packaging that identifies a product. A product can have several, e.g. its
+// own UPC and a supplier's Code128 label.
+type Barcode struct {
+	BarcodeID   int    `json:"barcodeid,omitempty"`
+	Code        string `json:"code"`
+	Symbology   string `json:"symbology"`
+	SKU         int    `json:"sku"`
+	DateCreated string `json:"datecreated,omitempty"`
+}
+
+// Barcode symbologies
+const (
+	// UPCA is 12 digits, the last one a check digit
+	UPCA = "upc-a"
+	// EAN13 is 13 digits, the last one a check digit. A UPC-A is an EAN-13 starting with 0.
+	EAN13 = "ean-13"
+	// Code128 is any printable ASCII, its check character is part of the printed symbol, not the data
+	Code128 = "code128"
+)
+
+// DetectSymbology guesses the symbology of code: 12 or 13 digits are a UPC-A or an EAN-13, anything else
+// is taken to be Code128. A bad check digit is left for ValidateBarcode to refuse, it is far more likely a
+// mistyped UPC than a Code128 label that happens to be all digits.
+func DetectSymbology(code string) string {
+	if allDigits(code) {
+		switch len(code) {
+		case 12:
+			return UPCA
+		case 13:
+			return EAN13
+		}
+	}
+	return Code128
+}
+
+// ValidateBarcode checks that code is well formed for symbology, including its check digit.
+func ValidateBarcode(symbology string, code string) error {
+	switch symbology {
+	case UPCA, EAN13:
+		n := 12
+		if symbology == EAN13 {
+			n = 13
+		}
+		if len(code) != n || !allDigits(code) {
+			return fmt.Errorf("%v must be %v digits", symbology, n)
+		}
+		if !gtinCheck(code) {
+			return fmt.Errorf("%v %v has a bad check digit", symbology, code)
+		}
+	case Code128:
+		if len(code) == 0 || len(code) > 48 {
+			return fmt.Errorf("code128 must be 1 to 48 characters")
+		}
+		for _, c := range code {
+			if c < 32 || c > 126 {
+				return fmt.Errorf("code128 can only hold printable ASCII")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown symbology %q, expected upc-a, ean-13 or code128", symbology)
+	}
+	return nil
+}
+
+func allDigits(code string) bool {
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return code != ""
+}
+
+// gtinCheck verifies the mod 10 check digit shared by UPC and EAN. Counting from the right, the digits
+// before the check digit are weighted 3, 1, 3, 1...
+func gtinCheck(code string) bool {
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		d := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10-sum%10)%10 == int(code[len(code)-1]-'0')
+}
+
+// barcodeCandidates is every form code could have been stored in. A scanner reading a UPC-A as an
+// EAN-13 adds a leading 0, and the other way around.
+func barcodeCandidates(code string) []string {
+	if allDigits(code) {
+		if len(code) == 13 && code[0] == '0' {
+			return []string{code, code[1:]}
+		}
+		if len(code) == 12 {
+			return []string{code, "0" + code}
+		}
+	}
+	return []string{code}
+}
+
+const selectBarcodes = "SELECT B.BarcodeID, B.Code, B.Symbology, P.SKU, B.DateCreated FROM Barcode B INNER JOIN Product P ON P.ProductID = B.ProductID"
+
+func scanBarcodes(rows *sql.Rows) ([]*Barcode, error) {
+	defer rows.Close()
+	codes := make([]*Barcode, 0)
+	for rows.Next() {
+		b := new(Barcode)
+		if err := rows.Scan(&b.BarcodeID, &b.Code, &b.Symbology, &b.SKU, &b.DateCreated); err != nil {
+			return nil, err
+		}
+		codes = append(codes, b)
+	}
+	return codes, rows.Err()
+}
+
+// findBarcode returns the barcode matching code. Only codes of products not flagged as deleted are
+// found unless archived is set, a code stays linked to its product when that is archived.
+func (s *SQLStore) findBarcode(tx *sql.Tx, code string, archived bool) (*Barcode, error) {
+	query := selectBarcodes + " WHERE B.Code = ? AND P.Deleted = 0"
+	if archived {
+		query = selectBarcodes + " WHERE B.Code = ?"
+	}
+	for _, c := range barcodeCandidates(code) {
+		rows, err := s.query(tx, query, c)
+		if err != nil {
+			return nil, err
+		}
+		codes, err := scanBarcodes(rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(codes) > 0 {
+			return codes[0], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// FindBarcode returns the barcode matching code, which may be a UPC-A scanned as an EAN-13 or the
+// other way around.
+func (s *SQLStore) FindBarcode(code string) (b *Barcode, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		b, err = s.findBarcode(tx, code, false)
+		return err
+	})
+	return b, err
+}
+
+// ListBarcodes returns the barcodes of the product with the given SKU.
+func (s *SQLStore) ListBarcodes(sku int) (codes []*Barcode, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.findProduct(tx, sku); err != nil {
+			return err
+		}
+		rows, err := s.query(tx, selectBarcodes+" WHERE P.SKU = ? AND P.Deleted = 0 ORDER BY B.BarcodeID", sku)
+		if err != nil {
+			return err
+		}
+		codes, err = scanBarcodes(rows)
+		return err
+	})
+	return codes, err
+}
+
+// AddBarcode links b to the product with the given SKU and fills in its BarcodeID.
+func (s *SQLStore) AddBarcode(sku int, b *Barcode) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		// Barcode.Code is unique, archived products included
+		if _, err := s.findBarcode(tx, b.Code, true); err != ErrNotFound {
+			if err == nil {
+				return ErrExists
+			}
+			return err
+		}
+		now := time.Now()
+		id, err := s.insert(tx, "INSERT INTO Barcode (Code, Symbology, ProductID, DateCreated) VALUES(?,?,?,?)", "BarcodeID", b.Code, b.Symbology, p.ProductID, now)
+		if err != nil {
+			return err
+		}
+		b.BarcodeID, b.SKU, b.DateCreated = int(id), sku, now.Format(time.RFC3339)
+		return nil
+	})
+}
+
+// DeleteBarcode unlinks code from its product, archived or not, so the code can be linked to another.
+func (s *SQLStore) DeleteBarcode(code string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		b, err := s.findBarcode(tx, code, true)
+		if err != nil {
+			return err
+		}
+		_, err = s.exec(tx, "DELETE FROM Barcode WHERE BarcodeID = ?", b.BarcodeID)
+		return err
+	})
+}
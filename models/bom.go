@@ -0,0 +1,161 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrBOMCycle is returned when a bill of materials would make a kit a component of itself, directly or
+// through another kit.
+var ErrBOMCycle = errors.New("a kit can't be a component of itself")
+
+// BOMComponent - one line of a bill of materials: Quantity of the component SKU go into each kit.
+type BOMComponent struct {
+	SKU         int    `json:"sku"`
+	ProductName string `json:"productname,omitempty"`
+	Quantity    int    `json:"quantity"`  // per kit
+	Available   int    `json:"available"` // on hand less reserved, over every location
+	Buildable   int    `json:"buildable"` // kits the component's available stock covers
+	Archived    bool   `json:"archived,omitempty"`
+	productID   int
+}
+
+// BOM - the bill of materials of a kit product. Buildable is how many kits could be assembled from the
+// components in stock, the least of the components' Buildable. A product without components isn't a kit
+// and can't be built.
+type BOM struct {
+	SKU         int             `json:"sku"`
+	ProductName string          `json:"productname"`
+	Components  []*BOMComponent `json:"components"`
+	Buildable   int             `json:"buildable"`
+}
+
+// findBOM returns the bill of materials of a product with the stock of each component. Archived
+// components are listed too, but none of them can be built with.
+func (s *SQLStore) findBOM(tx *sql.Tx, p *Product) (*BOM, error) {
+	rows, err := s.query(tx, "SELECT ComponentProductID, Quantity FROM BillOfMaterial WHERE ParentProductID = ?", p.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	perKit := make(map[int]int)
+	for rows.Next() {
+		var component, quantity int
+		if err := rows.Scan(&component, &quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		perKit[component] = quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	bom := &BOM{SKU: p.SKU, ProductName: p.ProductName, Components: make([]*BOMComponent, 0, len(perKit))}
+	if len(perKit) == 0 {
+		return bom, nil
+	}
+	rows, err = s.query(tx, selectProducts+" WHERE P.ProductID IN (SELECT ComponentProductID FROM BillOfMaterial WHERE ParentProductID = ?) ORDER BY P.SKU", now(), p.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	components, err := scanAllProducts(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range components {
+		line := &BOMComponent{SKU: c.SKU, ProductName: c.ProductName, Quantity: perKit[c.ProductID], Available: c.Available, Archived: c.Deleted != 0, productID: c.ProductID}
+		if line.Available > 0 && !line.Archived {
+			line.Buildable = line.Available / line.Quantity
+		}
+		if i == 0 || line.Buildable < bom.Buildable {
+			bom.Buildable = line.Buildable
+		}
+		bom.Components = append(bom.Components, line)
+	}
+	return bom, nil
+}
+
+// containsProduct reports whether the kit with the given ProductID has productID among its components,
+// at any depth.
+func (s *SQLStore) containsProduct(tx *sql.Tx, kitID int, productID int) (bool, error) {
+	seen := map[int]bool{kitID: true}
+	next := []int{kitID}
+	for len(next) > 0 {
+		kit := next[0]
+		next = next[1:]
+		rows, err := s.query(tx, "SELECT ComponentProductID FROM BillOfMaterial WHERE ParentProductID = ?", kit)
+		if err != nil {
+			return false, err
+		}
+		for rows.Next() {
+			var component int
+			if err := rows.Scan(&component); err != nil {
+				rows.Close()
+				return false, err
+			}
+			if component == productID {
+				rows.Close()
+				return true, nil
+			}
+			if !seen[component] {
+				seen[component] = true
+				next = append(next, component)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// GetBOM returns the bill of materials of the product with the given SKU.
+func (s *SQLStore) GetBOM(sku int) (bom *BOM, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		bom, err = s.findBOM(tx, p)
+		return err
+	})
+	return bom, err
+}
+
+// SetBOM replaces the bill of materials of the product with the given SKU with components. An empty
+// list takes the product's bill of materials away.
+func (s *SQLStore) SetBOM(sku int, components []*BOMComponent) (bom *BOM, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, "DELETE FROM BillOfMaterial WHERE ParentProductID = ?", p.ProductID); err != nil {
+			return err
+		}
+		for _, c := range components {
+			component, err := s.findProduct(tx, c.SKU)
+			if err != nil {
+				return err
+			}
+			if component.ProductID == p.ProductID {
+				return ErrBOMCycle
+			}
+			cycle, err := s.containsProduct(tx, component.ProductID, p.ProductID)
+			if err != nil {
+				return err
+			}
+			if cycle {
+				return ErrBOMCycle
+			}
+			if _, err := s.exec(tx, "INSERT INTO BillOfMaterial (ParentProductID, ComponentProductID, Quantity) VALUES(?,?,?)", p.ProductID, component.ProductID, c.Quantity); err != nil {
+				return err
+			}
+		}
+		bom, err = s.findBOM(tx, p)
+		return err
+	})
+	return bom, err
+}
@@ -0,0 +1,403 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrCategoryNotFound is returned when a change names a category that doesn't exist or has been deleted.
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrCategoryCycle is returned when a category would end up inside itself, directly or through one of
+// its subcategories.
+var ErrCategoryCycle = errors.New("a category can't be inside itself")
+
+// Category - a node in the tree products are sorted into, e.g. Outdoor / Swings. A product can be in
+// any number of categories.
+type Category struct {
+	CategoryID   int    `json:"categoryid"`
+	CategoryName string `json:"categoryname"`
+	ParentID     int    `json:"parentid,omitempty"` // 0 at the top level
+	Path         string `json:"path"`               // the names from the top level down, e.g. "Outdoor / Swings"
+	Deleted      int    `json:"deleted,omitempty"`
+}
+
+// Tag - a free-form label and how many products carry it
+type Tag struct {
+	Tag      string `json:"tag"`
+	Products int    `json:"products"`
+}
+
+// NormalizeTag is the form a tag is stored and matched in: trimmed, lower case, with single spaces.
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
+
+// loadCategories returns every category not flagged as deleted, with its path, ordered by path.
+func (s *SQLStore) loadCategories(tx *sql.Tx) ([]*Category, error) {
+	rows, err := s.query(tx, "SELECT CategoryID, CategoryName, ParentID, Deleted FROM Category")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := make([]*Category, 0)
+	byID := make(map[int]*Category)
+	for rows.Next() {
+		c := new(Category)
+		if err := rows.Scan(&c.CategoryID, &c.CategoryName, &c.ParentID, &c.Deleted); err != nil {
+			return nil, err
+		}
+		if c.Deleted == 0 {
+			list = append(list, c)
+			byID[c.CategoryID] = c
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range list {
+		names := []string{c.CategoryName}
+		seen := map[int]bool{c.CategoryID: true}
+		for parent := byID[c.ParentID]; parent != nil && !seen[parent.CategoryID]; parent = byID[parent.ParentID] {
+			seen[parent.CategoryID] = true
+			names = append([]string{parent.CategoryName}, names...)
+		}
+		c.Path = strings.Join(names, " / ")
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+// findCategory returns the category with the given ID from list, or ErrCategoryNotFound.
+func findCategory(list []*Category, id int) (*Category, error) {
+	for _, c := range list {
+		if c.CategoryID == id {
+			return c, nil
+		}
+	}
+	return nil, ErrCategoryNotFound
+}
+
+// subcategories returns the IDs of the category with the given ID and every category below it.
+func subcategories(list []*Category, id int) []int {
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		for _, c := range list {
+			if c.ParentID == ids[i] {
+				ids = append(ids, c.CategoryID)
+			}
+		}
+	}
+	return ids
+}
+
+// checkCategory checks that c can go where it is about to be saved: under a parent that exists and isn't
+// c itself or below it, without a sibling of the same name. id is 0 for a new category.
+func checkCategory(list []*Category, id int, c *Category) error {
+	if c.ParentID != 0 {
+		if _, err := findCategory(list, c.ParentID); err != nil {
+			return err
+		}
+		if id != 0 {
+			for _, below := range subcategories(list, id) {
+				if below == c.ParentID {
+					return ErrCategoryCycle
+				}
+			}
+		}
+	}
+	for _, sibling := range list {
+		if sibling.CategoryID != id && sibling.ParentID == c.ParentID && strings.EqualFold(sibling.CategoryName, c.CategoryName) {
+			return ErrExists
+		}
+	}
+	return nil
+}
+
+// ListCategories returns every category not flagged as deleted, ordered by path.
+func (s *SQLStore) ListCategories() (list []*Category, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		list, err = s.loadCategories(tx)
+		return err
+	})
+	return list, err
+}
+
+// GetCategory returns the category with the given ID.
+func (s *SQLStore) GetCategory(id int) (c *Category, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		list, err := s.loadCategories(tx)
+		if err != nil {
+			return err
+		}
+		c, err = findCategory(list, id)
+		return err
+	})
+	return c, err
+}
+
+// CreateCategory inserts the category and fills in its CategoryID and path.
+func (s *SQLStore) CreateCategory(c *Category) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		list, err := s.loadCategories(tx)
+		if err != nil {
+			return err
+		}
+		if err := checkCategory(list, 0, c); err != nil {
+			return err
+		}
+		id, err := s.insert(tx, "INSERT INTO Category (CategoryName, ParentID) VALUES(?,?)", "CategoryID", c.CategoryName, c.ParentID)
+		if err != nil {
+			return err
+		}
+		c.CategoryID, c.Path = int(id), c.CategoryName
+		if parent, err := findCategory(list, c.ParentID); err == nil {
+			c.Path = parent.Path + " / " + c.CategoryName
+		}
+		return nil
+	})
+}
+
+// UpdateCategory renames the category with the given ID and moves it under c.ParentID, taking its
+// subcategories and products along.
+func (s *SQLStore) UpdateCategory(id int, c *Category) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		list, err := s.loadCategories(tx)
+		if err != nil {
+			return err
+		}
+		if _, err := findCategory(list, id); err != nil {
+			return err
+		}
+		if err := checkCategory(list, id, c); err != nil {
+			return err
+		}
+		_, err = s.exec(tx, "UPDATE Category SET CategoryName = ?, ParentID = ? WHERE CategoryID = ?", c.CategoryName, c.ParentID, id)
+		c.CategoryID = id
+		return err
+	})
+}
+
+// DeleteCategory flags the category with the given ID as deleted and takes its products out of it. A
+// category with subcategories can't be deleted, they have to be moved or deleted first.
+func (s *SQLStore) DeleteCategory(id int) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		list, err := s.loadCategories(tx)
+		if err != nil {
+			return err
+		}
+		if _, err := findCategory(list, id); err != nil {
+			return err
+		}
+		if len(subcategories(list, id)) > 1 {
+			return ErrInUse
+		}
+		if _, err := s.exec(tx, "DELETE FROM ProductCategory WHERE CategoryID = ?", id); err != nil {
+			return err
+		}
+		_, err = s.exec(tx, "UPDATE Category SET Deleted = 1 WHERE CategoryID = ?", id)
+		return err
+	})
+}
+
+// productCategories returns the categories the product with the given ProductID is in, ordered by path.
+func (s *SQLStore) productCategories(tx *sql.Tx, productID int) ([]*Category, error) {
+	list, err := s.loadCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.query(tx, "SELECT CategoryID FROM ProductCategory WHERE ProductID = ?", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	in := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		in[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	cats := make([]*Category, 0, len(in))
+	for _, c := range list {
+		if in[c.CategoryID] {
+			cats = append(cats, c)
+		}
+	}
+	return cats, nil
+}
+
+// GetProductCategories returns the categories the product with the given SKU is in.
+func (s *SQLStore) GetProductCategories(sku int) (cats []*Category, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		cats, err = s.productCategories(tx, p.ProductID)
+		return err
+	})
+	return cats, err
+}
+
+// SetProductCategories replaces the categories the product with the given SKU is in with ids.
+func (s *SQLStore) SetProductCategories(sku int, ids []int) (cats []*Category, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		list, err := s.loadCategories(tx)
+		if err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, "DELETE FROM ProductCategory WHERE ProductID = ?", p.ProductID); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if _, err := findCategory(list, id); err != nil {
+				return err
+			}
+			if _, err := s.exec(tx, "INSERT INTO ProductCategory (ProductID, CategoryID) VALUES(?,?)", p.ProductID, id); err != nil {
+				return err
+			}
+		}
+		cats, err = s.productCategories(tx, p.ProductID)
+		return err
+	})
+	return cats, err
+}
+
+// productTags returns the tags of the product with the given ProductID in alphabetical order.
+func (s *SQLStore) productTags(tx *sql.Tx, productID int) ([]string, error) {
+	rows, err := s.query(tx, "SELECT Tag FROM ProductTag WHERE ProductID = ? ORDER BY Tag", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetProductTags returns the tags of the product with the given SKU.
+func (s *SQLStore) GetProductTags(sku int) (tags []string, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		tags, err = s.productTags(tx, p.ProductID)
+		return err
+	})
+	return tags, err
+}
+
+// SetProductTags replaces the tags of the product with the given SKU with tags, normalized.
+func (s *SQLStore) SetProductTags(sku int, tags []string) (saved []string, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, "DELETE FROM ProductTag WHERE ProductID = ?", p.ProductID); err != nil {
+			return err
+		}
+		seen := make(map[string]bool)
+		for _, tag := range tags {
+			tag = NormalizeTag(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			if _, err := s.exec(tx, "INSERT INTO ProductTag (ProductID, Tag) VALUES(?,?)", p.ProductID, tag); err != nil {
+				return err
+			}
+		}
+		saved, err = s.productTags(tx, p.ProductID)
+		return err
+	})
+	return saved, err
+}
+
+// ListTags returns every tag carried by a product that isn't archived, with the number of such products.
+func (s *SQLStore) ListTags() (tags []*Tag, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, "SELECT T.Tag, COUNT(*) FROM ProductTag T INNER JOIN Product P ON P.ProductID = T.ProductID WHERE P.Deleted = 0 GROUP BY T.Tag ORDER BY T.Tag")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		tags = make([]*Tag, 0)
+		for rows.Next() {
+			t := new(Tag)
+			if err := rows.Scan(&t.Tag, &t.Products); err != nil {
+				return err
+			}
+			tags = append(tags, t)
+		}
+		return rows.Err()
+	})
+	return tags, err
+}
+
+// RenameTag renames tag to to on every product carrying it, merging it into to where a product already
+// has both.
+func (s *SQLStore) RenameTag(tag string, to string) error {
+	tag, to = NormalizeTag(tag), NormalizeTag(to)
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := s.checkTag(tx, tag); err != nil {
+			return err
+		}
+		if tag == to {
+			return nil
+		}
+		// MySQL won't delete from a table it is reading in a subquery, unless that goes through a derived table
+		if _, err := s.exec(tx, "DELETE FROM ProductTag WHERE Tag = ? AND ProductID IN (SELECT ProductID FROM (SELECT ProductID FROM ProductTag WHERE Tag = ?) Merged)", tag, to); err != nil {
+			return err
+		}
+		_, err := s.exec(tx, "UPDATE ProductTag SET Tag = ? WHERE Tag = ?", to, tag)
+		return err
+	})
+}
+
+// DeleteTag takes tag off every product carrying it.
+func (s *SQLStore) DeleteTag(tag string) error {
+	tag = NormalizeTag(tag)
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := s.checkTag(tx, tag); err != nil {
+			return err
+		}
+		_, err := s.exec(tx, "DELETE FROM ProductTag WHERE Tag = ?", tag)
+		return err
+	})
+}
+
+// checkTag returns ErrNotFound unless some product carries tag.
+func (s *SQLStore) checkTag(tx *sql.Tx, tag string) error {
+	rows, err := s.query(tx, "SELECT ProductID FROM ProductTag WHERE Tag = ?", tag)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	return nil
+}
@@ -12,10 +12,6 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
-type Env struct {
-	Db *sql.DB
-}
-
 var dbConnection string
 
 func InitDBdefault() (*sql.DB, error) {
@@ -27,55 +23,69 @@ func InitDBdefault() (*sql.DB, error) {
 
 	var err error
 	//db, err = sql.Open("mysql", "fireadmin:FireFamily@1@tcp(165.227.17.104:3306)/Fire_Family")
-	NewDB("fireadmin:FireFamily@1@tcp(165.227.17.104:3306)/Fire_Family")
+	NewDB("mysql", "fireadmin:FireFamily@1@tcp(165.227.17.104:3306)/Fire_Family")
 	if err != nil {
 		//error handling here
-		log.Fatal("connection Error of %v", err)
+		log.Fatalf("connection Error of %v", err)
 		//fmt.Println("Conn")
 		//fmt.Println(err)
 	}
 	if err = Db.Ping(); err != nil {
 		//error handling here
-		log.Fatal("No Ping of Database %v", err)
+		log.Fatalf("No Ping of Database %v", err)
 	}
 
 	return Db, err
 }
-func InitDB(Dbdriver *app.Dbdriver) (*sql.DB, error) {
-	// Bootstrapping the setting
 
-	//"fireadmin:FireFamily@1@tcp(165.227.17.104:3306)/Fire_Family"
-	dbConnection = fmt.Sprintf("%v:%v@tcp(%v:)/%v", Dbdriver.Dbuser, Dbdriver.Dbpass, Dbdriver.Host, Dbdriver.Port, Dbdriver.Database)
-	//Trying DB things here
-
-	var err error
-	//db, err = sql.Open("mysql", "fireadmin:FireFamily@1@tcp(165.227.17.104:3306)/Fire_Family")
-	NewDB("fireadmin:FireFamily@1@tcp(165.227.17.104:3306)/Fire_Family")
+// InitDB opens the database selected by Dbdriver.Driver and checks that it answers.
+// "mysql" (the default) and "postgres" connect to the configured host, "sqlite" opens
+// Dbdriver.Database as a local file and brings its schema up to date, there is no one around
+// to run "migrate up" on a standalone install.
+func InitDB(Dbdriver *app.Dbdriver) (*sql.DB, error) {
+	db, err := OpenDB(Dbdriver)
 	if err != nil {
-		//error handling here
-		log.Fatal("connection Error of %v", err)
-		//fmt.Println("Conn")
-		//fmt.Println(err)
+		return nil, err
 	}
-	if err = Db.Ping(); err != nil {
-		//error handling here
-		log.Fatal("No Ping of Database %v", err)
+	if DialectFor(Dbdriver.Driver) == SQLite {
+		if _, err = MigrateUp(db, SQLite); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
+	return db, nil
+}
 
-	return Db, err
+// OpenDB opens the database selected by Dbdriver.Driver without touching its schema.
+func OpenDB(Dbdriver *app.Dbdriver) (*sql.DB, error) {
+	switch Dbdriver.Driver {
+	case "", "mysql":
+		port := Dbdriver.Port
+		if port == 0 {
+			port = 3306
+		}
+		dbConnection = fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", Dbdriver.Dbuser, Dbdriver.Dbpass, Dbdriver.Host, port, Dbdriver.Database)
+		return NewDB("mysql", dbConnection)
+	case "postgres", "postgresql":
+		return InitPostgres(Dbdriver)
+	case "sqlite", "sqlite3":
+		return InitSQLite(Dbdriver.Database)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", Dbdriver.Driver)
+	}
 }
 
 //SHOULD be a cross package global, isn't working, guess all db stuff is in routes now
 var Db *sql.DB
 
-func NewDB(dataSourceName string) (*sql.DB, error) {
+func NewDB(driverName string, dataSourceName string) (*sql.DB, error) {
 	var err error
-	Db, err = sql.Open("mysql", dataSourceName)
+	Db, err = sql.Open(driverName, dataSourceName)
 	if err != nil {
-		//error handling
+		return nil, err
 	}
 	if err = Db.Ping(); err != nil {
-		//error handling
+		return nil, err
 	}
 
 	return Db, err
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect is the flavour of SQL spoken by the database behind a SQLStore.
+type Dialect int
+
+const (
+	// MySQL uses ? placeholders and LastInsertId.
+	MySQL Dialect = iota
+	// SQLite is close enough to MySQL that the same queries work.
+	SQLite
+	// Postgres uses $1, $2... placeholders and RETURNING instead of LastInsertId.
+	Postgres
+)
+
+// DialectFor maps the driver name from the config onto its Dialect. Unknown names fall back to MySQL.
+func DialectFor(driver string) Dialect {
+	switch driver {
+	case "sqlite", "sqlite3":
+		return SQLite
+	case "postgres", "postgresql":
+		return Postgres
+	default:
+		return MySQL
+	}
+}
+
+// Rebind rewrites the ? placeholders in query into the dialect's own placeholder style.
+// Queries are written MySQL style throughout the models package.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// forUpdate is the row locking clause for a SELECT inside a write transaction. SQLite has no row
+// locks, its write transactions are started with BEGIN IMMEDIATE instead (see InitSQLite).
+func (d Dialect) forUpdate() string {
+	if d == SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
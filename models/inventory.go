@@ -1,11 +1,37 @@
 package models
 
-// Inventory - The inventory database model
+// Inventory - The inventory database model, one row per product per location
 type Inventory struct {
-	InventoryID     int    `json:"inventoryid,omitempty"`
-	Quantity        int    `json:"quantity"`
-	DateLastUpdated string `json:"datelastupdated, omitempty"`
-	ProductID       int    `json:"productid,omitempty"`
-	Deleted         int    `json:"deleted,omitempty"`
-	SKU             int    `json:"sku,omitempty"`
+	InventoryID     int       `json:"inventoryid,omitempty"`
+	Quantity        int       `json:"quantity"`
+	DateLastUpdated string    `json:"datelastupdated, omitempty"`
+	ProductID       int       `json:"productid,omitempty"`
+	Deleted         int       `json:"deleted,omitempty"`
+	SKU             int       `json:"sku,omitempty"`
+	LocationID      int       `json:"locationid,omitempty"`
+	Location        *Location `json:"location,omitempty"`
+	Reserved        int       `json:"reserved"`  // held by active reservations at the location
+	Available       int       `json:"available"` // Quantity less Reserved
+}
+
+// StockLevel - the inventory of one SKU across every location it is kept at. Stock in transit between
+// two locations is in neither of them and not part of the on hand total.
+type StockLevel struct {
+	SKU       int          `json:"sku"`
+	OnHand    int          `json:"onhand"`
+	Reserved  int          `json:"reserved"`
+	Available int          `json:"available"`
+	InTransit int          `json:"intransit"`
+	Locations []*Inventory `json:"locations"`
+}
+
+// NewStockLevel totals the inventory rows of one SKU.
+func NewStockLevel(sku int, inv []*Inventory) *StockLevel {
+	level := &StockLevel{SKU: sku, Locations: inv}
+	for _, i := range inv {
+		level.OnHand += i.Quantity
+		level.Reserved += i.Reserved
+	}
+	level.Available = level.OnHand - level.Reserved
+	return level
 }
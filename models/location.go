@@ -0,0 +1,169 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// DefaultLocation is the LocationID of the location created by the migration that introduced
+// locations. Every inventory row from before then lives there, and requests that don't name a location
+// use it.
+const DefaultLocation = 1
+
+// ErrLocationNotFound is returned when a change names a location that doesn't exist or has been deleted.
+var ErrLocationNotFound = errors.New("location not found")
+
+// ErrInUse is returned when deleting something that is still needed, e.g. a location with stock in it.
+var ErrInUse = errors.New("in use")
+
+// Location - somewhere stock is kept: a warehouse, optionally narrowed down to an aisle and a bin
+type Location struct {
+	LocationID int    `json:"locationid"`
+	Warehouse  string `json:"warehouse"`
+	Aisle      string `json:"aisle,omitempty"`
+	Bin        string `json:"bin,omitempty"`
+	Deleted    int    `json:"deleted,omitempty"`
+}
+
+// Code is the location written the way it is printed on shelf labels, e.g. MAIN-A3-07.
+func (l *Location) Code() string {
+	parts := []string{l.Warehouse}
+	for _, p := range []string{l.Aisle, l.Bin} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+const locationColumns = "LocationID, Warehouse, Aisle, Bin, Deleted"
+
+func scanLocations(rows *sql.Rows) ([]*Location, error) {
+	defer rows.Close()
+	locs := make([]*Location, 0)
+	for rows.Next() {
+		l := new(Location)
+		if err := rows.Scan(&l.LocationID, &l.Warehouse, &l.Aisle, &l.Bin, &l.Deleted); err != nil {
+			return nil, err
+		}
+		if l.Deleted == 0 {
+			locs = append(locs, l)
+		}
+	}
+	return locs, rows.Err()
+}
+
+// findLocation returns the location with the given ID, or ErrLocationNotFound.
+func (s *SQLStore) findLocation(tx *sql.Tx, id int) (*Location, error) {
+	rows, err := s.query(tx, "SELECT "+locationColumns+" FROM Location WHERE LocationID = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	locs, err := scanLocations(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(locs) == 0 {
+		return nil, ErrLocationNotFound
+	}
+	return locs[0], nil
+}
+
+// checkLocationFree returns ErrExists if another location already has l's warehouse, aisle and bin.
+func (s *SQLStore) checkLocationFree(tx *sql.Tx, l *Location, id int) error {
+	rows, err := s.query(tx, "SELECT "+locationColumns+" FROM Location WHERE Warehouse = ? AND Aisle = ? AND Bin = ? AND LocationID <> ?", l.Warehouse, l.Aisle, l.Bin, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		return ErrExists
+	}
+	return rows.Err()
+}
+
+// ListLocations returns every location not flagged as deleted.
+func (s *SQLStore) ListLocations() (locs []*Location, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, "SELECT "+locationColumns+" FROM Location ORDER BY Warehouse, Aisle, Bin")
+		if err != nil {
+			return err
+		}
+		locs, err = scanLocations(rows)
+		return err
+	})
+	return locs, err
+}
+
+// GetLocation returns the location with the given ID.
+func (s *SQLStore) GetLocation(id int) (l *Location, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		l, err = s.findLocation(tx, id)
+		return err
+	})
+	return l, err
+}
+
+// CreateLocation inserts the location and fills in its LocationID.
+func (s *SQLStore) CreateLocation(l *Location) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := s.checkLocationFree(tx, l, 0); err != nil {
+			return err
+		}
+		id, err := s.insert(tx, "INSERT INTO Location (Warehouse, Aisle, Bin) VALUES(?,?,?)", "LocationID", l.Warehouse, l.Aisle, l.Bin)
+		if err != nil {
+			return err
+		}
+		l.LocationID = int(id)
+		return nil
+	})
+}
+
+// UpdateLocation renames the location with the given ID. Its stock stays where it is.
+func (s *SQLStore) UpdateLocation(id int, l *Location) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.findLocation(tx, id); err != nil {
+			return err
+		}
+		if err := s.checkLocationFree(tx, l, id); err != nil {
+			return err
+		}
+		_, err := s.exec(tx, "UPDATE Location SET Warehouse = ?, Aisle = ?, Bin = ? WHERE LocationID = ?", l.Warehouse, l.Aisle, l.Bin, id)
+		l.LocationID = id
+		return err
+	})
+}
+
+// DeleteLocation flags the location with the given ID as deleted. A location still holding stock, with
+// transfers from or to it that haven't been received or reservations against it, and the default
+// location, can't be deleted.
+func (s *SQLStore) DeleteLocation(id int) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.findLocation(tx, id); err != nil {
+			return err
+		}
+		if id == DefaultLocation {
+			return ErrInUse
+		}
+		// Stock on its way in or out, and stock set aside there, would have nowhere to go
+		for _, q := range []struct {
+			query string
+			args  []interface{}
+		}{
+			{"SELECT InventoryID FROM Inventory WHERE LocationID = ? AND Quantity <> 0 AND Deleted = 0", []interface{}{id}},
+			{"SELECT TransferID FROM Transfer WHERE (Status = ? AND (FromLocationID = ? OR ToLocationID = ?)) OR (Status = ? AND ToLocationID = ?)", []interface{}{TransferRequested, id, id, TransferInTransit, id}},
+			{"SELECT ReservationID FROM Reservation WHERE LocationID = ? AND Status = ? AND ExpiresAt > ?", []interface{}{id, ReservationActive, now()}},
+		} {
+			n, err := s.count(tx, q.query, q.args...)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return ErrInUse
+			}
+		}
+		_, err := s.exec(tx, "UPDATE Location SET Deleted = 1 WHERE LocationID = ?", id)
+		return err
+	})
+}
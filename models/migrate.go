@@ -0,0 +1,245 @@
+package models
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations live in models/migrations as NNNN_name.up.sql / NNNN_name.down.sql pairs. They are
+// shared by every dialect, {{serial}} and {{datetime}} are swapped for the dialect's own types and
+// statements marked "-- mysql only" are left out of the others.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration together with when it was applied, if it has been.
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+const createSchemaMigration = `CREATE TABLE IF NOT EXISTS SchemaMigration (
+	Version   INT          NOT NULL PRIMARY KEY,
+	Name      VARCHAR(255) NOT NULL,
+	AppliedAt {{datetime}} NOT NULL
+)`
+
+// dropIndexOn matches MySQL's DROP INDEX name ON table, the other dialects don't take the table.
+var dropIndexOn = regexp.MustCompile(`(DROP INDEX \w+) ON \w+`)
+
+// mysqlOnly marks a statement in a migration file that only MySQL has, like its FULLTEXT indexes.
+const mysqlOnly = "-- mysql only"
+
+// ddl swaps the type placeholders used in the migration files for the dialect's column types.
+func (d Dialect) ddl(query string) string {
+	if d != MySQL {
+		statements := strings.Split(query, ";")
+		for i, s := range statements {
+			if strings.Contains(s, mysqlOnly) {
+				statements[i] = ""
+			}
+		}
+		query = dropIndexOn.ReplaceAllString(strings.Join(statements, ";"), "$1")
+	}
+	serial, datetime := "INT NOT NULL AUTO_INCREMENT PRIMARY KEY", "DATETIME"
+	switch d {
+	case SQLite:
+		serial = "INTEGER PRIMARY KEY AUTOINCREMENT"
+	case Postgres:
+		serial, datetime = "SERIAL PRIMARY KEY", "TIMESTAMP"
+	}
+	return strings.NewReplacer("{{serial}}", serial, "{{datetime}}", datetime).Replace(query)
+}
+
+// Migrations returns every embedded migration ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		sep := strings.Index(base, "_")
+		if sep < 1 {
+			return nil, fmt.Errorf("migration %v is not named NNNN_name", name)
+		}
+		version, err := strconv.Atoi(base[:sep])
+		if err != nil {
+			return nil, fmt.Errorf("migration %v is not named NNNN_name", name)
+		}
+
+		body, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: base[sep+1:]}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements breaks a migration file into single statements, not every driver runs several
+// statements in one Exec.
+func splitStatements(script string) []string {
+	statements := make([]string, 0)
+	for _, s := range strings.Split(script, ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			statements = append(statements, s)
+		}
+	}
+	return statements
+}
+
+// MigrationStatus lists every migration and whether it has been applied to db.
+func MigrationStatus(db *sql.DB, dialect Dialect) ([]MigrationState, error) {
+	if _, err := db.Exec(dialect.ddl(createSchemaMigration)); err != nil {
+		return nil, err
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT Version, AppliedAt FROM SchemaMigration")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		at, ok := applied[m.Version]
+		states = append(states, MigrationState{Migration: m, Applied: ok, AppliedAt: at})
+	}
+	return states, nil
+}
+
+// runMigration executes one direction of a migration and records it in SchemaMigration.
+func runMigration(db *sql.DB, dialect Dialect, m Migration, up bool) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			tx.Rollback()
+		}
+	}()
+
+	script := m.Down
+	if up {
+		script = m.Up
+	}
+	for _, statement := range splitStatements(dialect.ddl(script)) {
+		if _, err = tx.Exec(statement); err != nil {
+			return fmt.Errorf("migration %04d_%v: %v", m.Version, m.Name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(dialect.Rebind("INSERT INTO SchemaMigration (Version, Name, AppliedAt) VALUES (?, ?, ?)"), m.Version, m.Name, time.Now())
+	} else {
+		_, err = tx.Exec(dialect.Rebind("DELETE FROM SchemaMigration WHERE Version = ?"), m.Version)
+	}
+	return err
+}
+
+// MigrateUp applies every pending migration in order and returns the ones it applied.
+func MigrateUp(db *sql.DB, dialect Dialect) ([]Migration, error) {
+	states, err := MigrationStatus(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0)
+	for _, s := range states {
+		if s.Applied {
+			continue
+		}
+		if err := runMigration(db, dialect, s.Migration, true); err != nil {
+			return done, err
+		}
+		done = append(done, s.Migration)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and returns the ones it reverted.
+func MigrateDown(db *sql.DB, dialect Dialect, steps int) ([]Migration, error) {
+	states, err := MigrationStatus(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0)
+	for i := len(states) - 1; i >= 0 && len(done) < steps; i-- {
+		if !states[i].Applied {
+			continue
+		}
+		if err := runMigration(db, dialect, states[i].Migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, states[i].Migration)
+	}
+	return done, nil
+}
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS Product (
+	ProductID            {{serial}},
+	ProductName          VARCHAR(255)  NOT NULL,
+	NotificationQuantity INT           NOT NULL DEFAULT 0,
+	Color                VARCHAR(64)   NOT NULL DEFAULT '',
+	TrimColor            VARCHAR(64)   NOT NULL DEFAULT '',
+	Size                 VARCHAR(64)   NOT NULL DEFAULT '',
+	Price                DECIMAL(10,2) NOT NULL DEFAULT 0,
+	Dimensions           VARCHAR(255)  NOT NULL DEFAULT '',
+	SKU                  INT           NOT NULL,
+	Deleted              INT           NOT NULL DEFAULT 0,
+	UNIQUE (SKU)
+);
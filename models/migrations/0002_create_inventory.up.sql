@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS Inventory (
+	InventoryID     {{serial}},
+	Quantity        INT          NOT NULL DEFAULT 0,
+	DateLastUpdated {{datetime}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	Deleted         INT          NOT NULL DEFAULT 0,
+	ProductID       INT          NOT NULL,
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS InventoryMovement (
+	MovementID  {{serial}},
+	InventoryID INT          NOT NULL,
+	Delta       INT          NOT NULL,
+	Quantity    INT          NOT NULL,
+	Reason      VARCHAR(32)  NOT NULL,
+	Note        VARCHAR(255) NOT NULL DEFAULT '',
+	UserName    VARCHAR(64)  NOT NULL DEFAULT '',
+	DateCreated {{datetime}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (InventoryID) REFERENCES Inventory (InventoryID)
+);
+
+CREATE INDEX IX_InventoryMovement_InventoryID ON InventoryMovement (InventoryID, MovementID);
@@ -0,0 +1 @@
+ALTER TABLE Product ADD COLUMN NegativeStock VARCHAR(8) NOT NULL DEFAULT ''
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS Webhook (
+	WebhookID   {{serial}},
+	URL         VARCHAR(2048) NOT NULL,
+	Secret      VARCHAR(128)  NOT NULL,
+	Events      VARCHAR(255)  NOT NULL DEFAULT '',
+	Active      INT           NOT NULL DEFAULT 1,
+	Deleted     INT           NOT NULL DEFAULT 0,
+	DateCreated {{datetime}}  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS WebhookDelivery (
+	DeliveryID   {{serial}},
+	WebhookID    INT           NOT NULL,
+	EventID      VARCHAR(32)   NOT NULL,
+	Event        VARCHAR(64)   NOT NULL,
+	Payload      TEXT          NOT NULL,
+	Attempt      INT           NOT NULL,
+	StatusCode   INT           NOT NULL DEFAULT 0,
+	ErrorMessage VARCHAR(1024) NOT NULL DEFAULT '',
+	DateCreated  {{datetime}}  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (WebhookID) REFERENCES Webhook (WebhookID)
+);
+
+CREATE INDEX IX_WebhookDelivery_WebhookID ON WebhookDelivery (WebhookID, DeliveryID);
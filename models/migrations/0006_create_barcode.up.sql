@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS Barcode (
+	BarcodeID   {{serial}},
+	Code        VARCHAR(64)  NOT NULL UNIQUE,
+	Symbology   VARCHAR(16)  NOT NULL,
+	ProductID   INT          NOT NULL,
+	DateCreated {{datetime}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID)
+);
+
+CREATE INDEX IX_Barcode_ProductID ON Barcode (ProductID);
@@ -0,0 +1,15 @@
+-- Fold every location back into one inventory row per product
+CREATE TABLE InventoryMerge (
+	ProductID   INT NOT NULL,
+	InventoryID INT NOT NULL,
+	Quantity    INT NOT NULL
+);
+INSERT INTO InventoryMerge (ProductID, InventoryID, Quantity) SELECT ProductID, MIN(InventoryID), SUM(CASE WHEN Deleted = 0 THEN Quantity ELSE 0 END) FROM Inventory GROUP BY ProductID;
+UPDATE InventoryMovement SET InventoryID = (SELECT M.InventoryID FROM InventoryMerge M INNER JOIN Inventory I ON I.ProductID = M.ProductID WHERE I.InventoryID = InventoryMovement.InventoryID);
+UPDATE Inventory SET Quantity = (SELECT M.Quantity FROM InventoryMerge M WHERE M.InventoryID = Inventory.InventoryID), Deleted = 0 WHERE InventoryID IN (SELECT InventoryID FROM InventoryMerge);
+DELETE FROM Inventory WHERE InventoryID NOT IN (SELECT InventoryID FROM InventoryMerge);
+DROP TABLE InventoryMerge;
+
+DROP INDEX UX_Inventory_ProductID_LocationID ON Inventory;
+ALTER TABLE Inventory DROP COLUMN LocationID;
+DROP TABLE IF EXISTS Location
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS Location (
+	LocationID {{serial}},
+	Warehouse  VARCHAR(64) NOT NULL,
+	Aisle      VARCHAR(64) NOT NULL DEFAULT '',
+	Bin        VARCHAR(64) NOT NULL DEFAULT '',
+	Deleted    INT         NOT NULL DEFAULT 0,
+	UNIQUE (Warehouse, Aisle, Bin)
+);
+
+-- The default location, LocationID 1. Every existing inventory row moves here.
+INSERT INTO Location (Warehouse, Aisle, Bin) VALUES ('MAIN', '', '');
+
+-- A product could end up with several inventory rows, merge them into its oldest one
+CREATE TABLE InventoryMerge (
+	ProductID   INT NOT NULL,
+	InventoryID INT NOT NULL,
+	Quantity    INT NOT NULL
+);
+INSERT INTO InventoryMerge (ProductID, InventoryID, Quantity) SELECT ProductID, MIN(InventoryID), SUM(CASE WHEN Deleted = 0 THEN Quantity ELSE 0 END) FROM Inventory GROUP BY ProductID;
+UPDATE InventoryMovement SET InventoryID = (SELECT M.InventoryID FROM InventoryMerge M INNER JOIN Inventory I ON I.ProductID = M.ProductID WHERE I.InventoryID = InventoryMovement.InventoryID);
+UPDATE Inventory SET Quantity = (SELECT M.Quantity FROM InventoryMerge M WHERE M.InventoryID = Inventory.InventoryID), Deleted = 0 WHERE InventoryID IN (SELECT InventoryID FROM InventoryMerge);
+DELETE FROM Inventory WHERE InventoryID NOT IN (SELECT InventoryID FROM InventoryMerge);
+DROP TABLE InventoryMerge;
+
+-- No foreign key, SQLite can't add one to an existing table
+ALTER TABLE Inventory ADD COLUMN LocationID INT NOT NULL DEFAULT 1;
+CREATE UNIQUE INDEX UX_Inventory_ProductID_LocationID ON Inventory (ProductID, LocationID)
@@ -0,0 +1,2 @@
+-- Stock still in transit is in neither location, receive it before rolling back
+DROP TABLE IF EXISTS Transfer
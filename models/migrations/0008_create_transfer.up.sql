@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS Transfer (
+	TransferID     {{serial}},
+	ProductID      INT          NOT NULL,
+	FromLocationID INT          NOT NULL,
+	ToLocationID   INT          NOT NULL,
+	Quantity       INT          NOT NULL,
+	Status         VARCHAR(16)  NOT NULL,
+	Note           VARCHAR(255) NOT NULL DEFAULT '',
+	UserName       VARCHAR(64)  NOT NULL DEFAULT '',
+	DateCreated    {{datetime}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	DateShipped    {{datetime}} NULL,
+	DateReceived   {{datetime}} NULL,
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID),
+	FOREIGN KEY (FromLocationID) REFERENCES Location (LocationID),
+	FOREIGN KEY (ToLocationID) REFERENCES Location (LocationID)
+);
+
+CREATE INDEX IX_Transfer_Status ON Transfer (Status, ProductID);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS Reservation (
+	ReservationID {{serial}},
+	ProductID     INT          NOT NULL,
+	LocationID    INT          NOT NULL,
+	Quantity      INT          NOT NULL,
+	Status        VARCHAR(16)  NOT NULL,
+	Reference     VARCHAR(64)  NOT NULL DEFAULT '',
+	Note          VARCHAR(255) NOT NULL DEFAULT '',
+	UserName      VARCHAR(64)  NOT NULL DEFAULT '',
+	ExpiresAt     {{datetime}} NOT NULL,
+	DateCreated   {{datetime}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	DateClosed    {{datetime}} NULL,
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID),
+	FOREIGN KEY (LocationID) REFERENCES Location (LocationID)
+);
+
+CREATE INDEX IX_Reservation_ProductID ON Reservation (ProductID, Status, ExpiresAt);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS StocktakeCount;
+DROP TABLE IF EXISTS StocktakeProduct;
+DROP TABLE IF EXISTS Stocktake;
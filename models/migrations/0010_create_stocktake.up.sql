@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS Stocktake (
+	StocktakeID {{serial}},
+	LocationID  INT          NOT NULL DEFAULT 0,
+	Status      VARCHAR(16)  NOT NULL,
+	Note        VARCHAR(255) NOT NULL DEFAULT '',
+	UserName    VARCHAR(64)  NOT NULL DEFAULT '',
+	DateCreated {{datetime}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	DateClosed  {{datetime}} NULL
+);
+
+-- The products a stocktake is limited to, none means every product
+CREATE TABLE IF NOT EXISTS StocktakeProduct (
+	StocktakeID INT NOT NULL,
+	ProductID   INT NOT NULL,
+	PRIMARY KEY (StocktakeID, ProductID),
+	FOREIGN KEY (StocktakeID) REFERENCES Stocktake (StocktakeID),
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID)
+);
+
+CREATE TABLE IF NOT EXISTS StocktakeCount (
+	StocktakeID INT          NOT NULL,
+	ProductID   INT          NOT NULL,
+	LocationID  INT          NOT NULL,
+	Expected    INT          NOT NULL,
+	Counted     INT          NOT NULL,
+	UserName    VARCHAR(64)  NOT NULL DEFAULT '',
+	DateCounted {{datetime}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (StocktakeID, ProductID, LocationID),
+	FOREIGN KEY (StocktakeID) REFERENCES Stocktake (StocktakeID),
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID),
+	FOREIGN KEY (LocationID) REFERENCES Location (LocationID)
+);
@@ -0,0 +1,11 @@
+-- One row per component of a kit, Quantity is how many of the component go into one kit
+CREATE TABLE IF NOT EXISTS BillOfMaterial (
+	ParentProductID    INT NOT NULL,
+	ComponentProductID INT NOT NULL,
+	Quantity           INT NOT NULL,
+	PRIMARY KEY (ParentProductID, ComponentProductID),
+	FOREIGN KEY (ParentProductID) REFERENCES Product (ProductID),
+	FOREIGN KEY (ComponentProductID) REFERENCES Product (ProductID)
+);
+
+CREATE INDEX IX_BillOfMaterial_ComponentProductID ON BillOfMaterial (ComponentProductID);
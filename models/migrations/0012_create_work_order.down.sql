@@ -0,0 +1,3 @@
+-- Release or cancel open work orders first, their reservations are left behind otherwise
+DROP TABLE IF EXISTS WorkOrderComponent;
+DROP TABLE IF EXISTS WorkOrder;
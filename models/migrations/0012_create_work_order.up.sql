@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS WorkOrder (
+	WorkOrderID   {{serial}},
+	ProductID     INT          NOT NULL,
+	LocationID    INT          NOT NULL,
+	Quantity      INT          NOT NULL,
+	Status        VARCHAR(16)  NOT NULL,
+	Note          VARCHAR(255) NOT NULL DEFAULT '',
+	UserName      VARCHAR(64)  NOT NULL DEFAULT '',
+	DateCreated   {{datetime}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	DateReleased  {{datetime}} NULL,
+	DateCompleted {{datetime}} NULL,
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID),
+	FOREIGN KEY (LocationID) REFERENCES Location (LocationID)
+);
+
+CREATE INDEX IX_WorkOrder_Status ON WorkOrder (Status, ProductID);
+
+-- The bill of materials as it was when the work order was created, Quantity is for the whole order
+CREATE TABLE IF NOT EXISTS WorkOrderComponent (
+	WorkOrderID   INT NOT NULL,
+	ProductID     INT NOT NULL,
+	Quantity      INT NOT NULL,
+	ReservationID INT NULL,
+	PRIMARY KEY (WorkOrderID, ProductID),
+	FOREIGN KEY (WorkOrderID) REFERENCES WorkOrder (WorkOrderID),
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID)
+);
@@ -0,0 +1,4 @@
+DROP INDEX IX_Product_StyleID ON Product;
+ALTER TABLE Product DROP COLUMN StyleID;
+DROP TABLE IF EXISTS StyleOption;
+DROP TABLE IF EXISTS Style
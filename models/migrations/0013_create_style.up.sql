@@ -0,0 +1,24 @@
+-- A style is the parent of a family of products that only differ in color, trim color and size. The
+-- variants stay ordinary products pointing at their style, Product.StyleID is 0 for one without.
+CREATE TABLE IF NOT EXISTS Style (
+	StyleID              {{serial}},
+	StyleName            VARCHAR(255)  NOT NULL,
+	NotificationQuantity INT           NOT NULL DEFAULT 0,
+	Price                DECIMAL(10,2) NOT NULL DEFAULT 0,
+	Dimensions           VARCHAR(255)  NOT NULL DEFAULT '',
+	NegativeStock        VARCHAR(8)    NOT NULL DEFAULT '',
+	Deleted              INT           NOT NULL DEFAULT 0
+);
+
+-- The values a style comes in, Attribute is color, trimcolor or size and Position keeps them in order
+CREATE TABLE IF NOT EXISTS StyleOption (
+	StyleID   INT          NOT NULL,
+	Attribute VARCHAR(16)  NOT NULL,
+	Position  INT          NOT NULL,
+	Value     VARCHAR(64)  NOT NULL,
+	PRIMARY KEY (StyleID, Attribute, Position),
+	FOREIGN KEY (StyleID) REFERENCES Style (StyleID)
+);
+
+ALTER TABLE Product ADD COLUMN StyleID INT NOT NULL DEFAULT 0;
+CREATE INDEX IX_Product_StyleID ON Product (StyleID)
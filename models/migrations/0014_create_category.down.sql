@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ProductTag;
+DROP TABLE IF EXISTS ProductCategory;
+DROP TABLE IF EXISTS Category
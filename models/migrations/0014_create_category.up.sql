@@ -0,0 +1,26 @@
+-- Categories nest, ParentID is 0 for a top level category
+CREATE TABLE IF NOT EXISTS Category (
+	CategoryID   {{serial}},
+	CategoryName VARCHAR(255) NOT NULL,
+	ParentID     INT          NOT NULL DEFAULT 0,
+	Deleted      INT          NOT NULL DEFAULT 0
+);
+
+-- A product can be in any number of categories
+CREATE TABLE IF NOT EXISTS ProductCategory (
+	ProductID  INT NOT NULL,
+	CategoryID INT NOT NULL,
+	PRIMARY KEY (ProductID, CategoryID),
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID),
+	FOREIGN KEY (CategoryID) REFERENCES Category (CategoryID)
+);
+CREATE INDEX IX_ProductCategory_CategoryID ON ProductCategory (CategoryID);
+
+-- Tags are free-form, there is no table of them besides this one
+CREATE TABLE IF NOT EXISTS ProductTag (
+	ProductID INT         NOT NULL,
+	Tag       VARCHAR(64) NOT NULL,
+	PRIMARY KEY (ProductID, Tag),
+	FOREIGN KEY (ProductID) REFERENCES Product (ProductID)
+);
+CREATE INDEX IX_ProductTag_Tag ON ProductTag (Tag)
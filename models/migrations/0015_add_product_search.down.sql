@@ -0,0 +1,2 @@
+-- mysql only
+DROP INDEX FT_Product_Search ON Product
@@ -0,0 +1,5 @@
+-- GET /product/search finds its candidates on MySQL through this index. The ngram parser indexes every
+-- pair of letters, so a misspelt word still shares some with the right one. The other dialects search
+-- an index SQLStore keeps in memory instead.
+-- mysql only
+CREATE FULLTEXT INDEX FT_Product_Search ON Product (ProductName, Color, TrimColor, Size, Dimensions) WITH PARSER ngram
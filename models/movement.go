@@ -0,0 +1,44 @@
+package models
+
+// InventoryMovement - one row of the append-only inventory ledger, written alongside every quantity change
+type InventoryMovement struct {
+	MovementID  int    `json:"movementid"`
+	InventoryID int    `json:"inventoryid"`
+	SKU         int    `json:"sku"`
+	LocationID  int    `json:"locationid"`
+	Delta       int    `json:"delta"`
+	Quantity    int    `json:"quantity"`
+	Reason      string `json:"reason"`
+	Note        string `json:"note,omitempty"`
+	User        string `json:"user,omitempty"`
+	DateCreated string `json:"datecreated"`
+	// OnHand is the product's total over every location right after a change that took stock off, as
+	// the transaction making it saw it. It isn't kept in the ledger.
+	OnHand int `json:"-"`
+}
+
+// Reason codes for an InventoryMovement
+const (
+	ReasonReceive         = "receive"
+	ReasonSale            = "sale"
+	ReasonReturn          = "return"
+	ReasonDamage          = "damage"
+	ReasonCountCorrection = "count-correction"
+	ReasonAdjustment      = "adjustment"
+	// ReasonTransfer is written by the transfers themselves, once when the stock leaves the from
+	// location and once when it arrives at the to location. The note names the transfer.
+	ReasonTransfer = "transfer"
+	// ReasonAssembly is written by work orders, taking the components out of stock and putting the
+	// finished kits in. The note names the work order.
+	ReasonAssembly = "assembly"
+)
+
+// ValidReason reports whether reason is one of the known reason codes a request can give. Transfers
+// and assemblies are only recorded through /transfers and /workorders.
+func ValidReason(reason string) bool {
+	switch reason {
+	case ReasonReceive, ReasonSale, ReasonReturn, ReasonDamage, ReasonCountCorrection, ReasonAdjustment:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"../app"
+	// "github.com/Xero67/web-fire-family/app"
+
+	_ "github.com/lib/pq"
+)
+
+// InitPostgres connects to the PostgreSQL server described by Dbdriver.
+func InitPostgres(Dbdriver *app.Dbdriver) (*sql.DB, error) {
+	sslmode := Dbdriver.SSLMode
+	if sslmode == "" {
+		sslmode = "disable"
+	}
+	port := Dbdriver.Port
+	if port == 0 {
+		port = 5432
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(Dbdriver.Dbuser, Dbdriver.Dbpass),
+		Host:     fmt.Sprintf("%v:%v", Dbdriver.Host, port),
+		Path:     Dbdriver.Database,
+		RawQuery: "sslmode=" + url.QueryEscape(sslmode),
+	}
+	dbConnection = u.String()
+	return NewDB("postgres", dbConnection)
+}
@@ -12,5 +12,9 @@ type Product struct {
 	Dimensions           string  `json:"dimensions,omitempty"`
 	SKU                  int     `json:"sku,omitempty"`
 	Deleted              int     `json:"deleted,omitempty"`
-	Quantity        	 int     `json:"quantity"`
+	NegativeStock        string  `json:"negativestock,omitempty"` // reject, allow or clamp, empty for the store default
+	StyleID              int     `json:"styleid,omitempty"`       // the style this is a variant of, 0 for none
+	OnHand               int     `json:"onhand"`                  // total over every location
+	Reserved             int     `json:"reserved"`                // held by active reservations
+	Available            int     `json:"available"`               // on hand less reserved
 }
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// ProductQuery - one page of the product listing, narrowed down and sorted. A filter left at its zero
+// value doesn't narrow anything down.
+type ProductQuery struct {
+	Category   int      // includes its subcategories
+	Tags       []string // a product has to carry every one
+	Color      string   // the attributes match regardless of case
+	TrimColor  string
+	Size       string
+	StyleID    int
+	PriceMin   *float64
+	PriceMax   *float64
+	QuantityLt *int   // on hand, over every location
+	Sort       string // a field of Product, see ValidProductSort
+	Limit      int    // 0 for every match
+	Offset     int
+}
+
+// InventoryQuery - one page of the inventory listing, narrowed down and sorted like ProductQuery.
+type InventoryQuery struct {
+	SKU        int
+	LocationID int
+	Warehouse  string
+	QuantityLt *int
+	Sort       string // a field of Inventory, see ValidInventorySort
+	Limit      int    // 0 for every match
+	Offset     int
+}
+
+// The columns behind the fields listings can be sorted on
+var productSorts = map[string]string{
+	"productid":            "P.ProductID",
+	"productname":          "P.ProductName",
+	"notificationquantity": "P.NotificationQuantity",
+	"color":                "P.Color",
+	"trimcolor":            "P.TrimColor",
+	"size":                 "P.Size",
+	"price":                "P.Price",
+	"dimensions":           "P.Dimensions",
+	"sku":                  "P.SKU",
+	"styleid":              "P.StyleID",
+	"onhand":               "COALESCE(I.Quantity, 0)",
+	"reserved":             "COALESCE(R.Quantity, 0)",
+	"available":            "COALESCE(I.Quantity, 0) - COALESCE(R.Quantity, 0)",
+}
+
+var inventorySorts = map[string]string{
+	"inventoryid":     "I.InventoryID",
+	"quantity":        "I.Quantity",
+	"datelastupdated": "I.DateLastUpdated",
+	"productid":       "I.ProductID",
+	"sku":             "P.SKU",
+	"locationid":      "I.LocationID",
+	"warehouse":       "L.Warehouse",
+}
+
+// ValidProductSort reports whether the product listing can be sorted by sort: the JSON name of a Product
+// field, with a leading "-" for descending order. Empty sorts by SKU.
+func ValidProductSort(sort string) bool {
+	_, ok := productSorts[strings.TrimPrefix(sort, "-")]
+	return ok || sort == ""
+}
+
+// ValidInventorySort is ValidProductSort for the inventory listing. The reserved and available quantities
+// aren't stored, so they can't be sorted on. Empty sorts by InventoryID.
+func ValidInventorySort(sort string) bool {
+	_, ok := inventorySorts[strings.TrimPrefix(sort, "-")]
+	return ok || sort == ""
+}
+
+// orderBy is the ORDER BY clause for sort, with key breaking ties so pages don't overlap.
+func orderBy(columns map[string]string, sort string, key string) string {
+	direction := ""
+	if strings.HasPrefix(sort, "-") {
+		sort, direction = sort[1:], " DESC"
+	}
+	if column, ok := columns[sort]; ok {
+		return " ORDER BY " + column + direction + ", " + key
+	}
+	return " ORDER BY " + key
+}
+
+// limitClause is the LIMIT of a page with its arguments added to args, none when limit is 0.
+func limitClause(limit int, offset int, args []interface{}) (string, []interface{}) {
+	if limit == 0 {
+		return "", args
+	}
+	return " LIMIT ? OFFSET ?", append(args, limit, offset)
+}
+
+// count returns how many rows query returns.
+func (s *SQLStore) count(tx *sql.Tx, query string, args ...interface{}) (int, error) {
+	var n int
+	err := tx.QueryRow(s.Dialect.Rebind("SELECT COUNT(*) FROM ("+query+") Counted"), args...).Scan(&n)
+	return n, err
+}
+
+// QueryProducts returns the page of products not flagged as deleted that q asks for, and how many match
+// over every page.
+func (s *SQLStore) QueryProducts(q *ProductQuery) (prods []*Product, total int, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		where := []string{"P.Deleted = 0"}
+		args := []interface{}{now()}
+		if q.Category != 0 {
+			list, err := s.loadCategories(tx)
+			if err != nil {
+				return err
+			}
+			if _, err := findCategory(list, q.Category); err != nil {
+				return err
+			}
+			ids := subcategories(list, q.Category)
+			where = append(where, "P.ProductID IN (SELECT ProductID FROM ProductCategory WHERE CategoryID IN (?"+strings.Repeat(",?", len(ids)-1)+"))")
+			for _, id := range ids {
+				args = append(args, id)
+			}
+		}
+		for _, tag := range q.Tags {
+			where = append(where, "P.ProductID IN (SELECT ProductID FROM ProductTag WHERE Tag = ?)")
+			args = append(args, NormalizeTag(tag))
+		}
+		for column, value := range map[string]string{"P.Color": q.Color, "P.TrimColor": q.TrimColor, "P.Size": q.Size} {
+			if value != "" {
+				where = append(where, "LOWER("+column+") = ?")
+				args = append(args, strings.ToLower(value))
+			}
+		}
+		if q.StyleID != 0 {
+			where, args = append(where, "P.StyleID = ?"), append(args, q.StyleID)
+		}
+		// Price is a float32, SQLite stores 99.99 as a hair below it where MySQL rounds to the cent
+		if q.PriceMin != nil {
+			where, args = append(where, "ROUND(P.Price, 2) >= ?"), append(args, *q.PriceMin)
+		}
+		if q.PriceMax != nil {
+			where, args = append(where, "ROUND(P.Price, 2) <= ?"), append(args, *q.PriceMax)
+		}
+		if q.QuantityLt != nil {
+			where, args = append(where, "COALESCE(I.Quantity, 0) < ?"), append(args, *q.QuantityLt)
+		}
+
+		query := selectProducts + " WHERE " + strings.Join(where, " AND ")
+		if total, err = s.count(tx, query, args...); err != nil {
+			return err
+		}
+		page, args := limitClause(q.Limit, q.Offset, args)
+		rows, err := s.query(tx, query+orderBy(productSorts, q.Sort, "P.SKU")+page, args...)
+		if err != nil {
+			return err
+		}
+		prods, err = scanProducts(rows)
+		return err
+	})
+	return prods, total, err
+}
+
+// QueryInventories returns the page of inventory rows not flagged as deleted that q asks for, with their
+// reserved quantities, and how many match over every page.
+func (s *SQLStore) QueryInventories(q *InventoryQuery) (inv []*Inventory, total int, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		where := []string{"I.Deleted = 0"}
+		var args []interface{}
+		if q.SKU != 0 {
+			where, args = append(where, "P.SKU = ?"), append(args, q.SKU)
+		}
+		if q.LocationID != 0 {
+			where, args = append(where, "I.LocationID = ?"), append(args, q.LocationID)
+		}
+		if q.Warehouse != "" {
+			where, args = append(where, "L.Warehouse = ?"), append(args, q.Warehouse)
+		}
+		if q.QuantityLt != nil {
+			where, args = append(where, "I.Quantity < ?"), append(args, *q.QuantityLt)
+		}
+
+		query := selectInventories + " WHERE " + strings.Join(where, " AND ")
+		if total, err = s.count(tx, query, args...); err != nil {
+			return err
+		}
+		page, args := limitClause(q.Limit, q.Offset, args)
+		rows, err := s.query(tx, query+orderBy(inventorySorts, q.Sort, "I.InventoryID")+page, args...)
+		if err != nil {
+			return err
+		}
+		if inv, err = scanInventories(rows); err != nil {
+			return err
+		}
+		return s.fillReserved(tx, inv)
+	})
+	return inv, total, err
+}
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Reservation statuses. An active reservation holds its quantity until it is released, consumed or its
+// ExpiresAt passes, at which point it reads as expired and stops counting.
+const (
+	ReservationActive   = "active"
+	ReservationReleased = "released"
+	ReservationConsumed = "consumed"
+	ReservationExpired  = "expired"
+)
+
+// DefaultReservationExpiry is how long a reservation holds its stock when the request doesn't say.
+const DefaultReservationExpiry = 7 * 24 * time.Hour
+
+// ErrReservationClosed is returned when releasing or consuming a reservation that has already been
+// released, consumed or, for consume, has expired.
+var ErrReservationClosed = errors.New("reservation is no longer active")
+
+// Reservation - a quantity of one SKU at one location held for a customer order that hasn't shipped.
+// Reserved stock is still on hand, it just isn't available to anyone else.
+type Reservation struct {
+	ReservationID int    `json:"reservationid"`
+	SKU           int    `json:"sku"`
+	LocationID    int    `json:"locationid"`
+	Quantity      int    `json:"quantity"`
+	Status        string `json:"status"`
+	Reference     string `json:"reference,omitempty"` // e.g. the order number
+	Note          string `json:"note,omitempty"`
+	User          string `json:"user,omitempty"`
+	ExpiresAt     string `json:"expiresat"`
+	DateCreated   string `json:"datecreated"`
+	DateClosed    string `json:"dateclosed,omitempty"`
+}
+
+// ValidReservationStatus reports whether status is one of the reservation statuses.
+func ValidReservationStatus(status string) bool {
+	switch status {
+	case ReservationActive, ReservationReleased, ReservationConsumed, ReservationExpired:
+		return true
+	}
+	return false
+}
+
+// The status is worked out in the query so an active reservation past its expiry reads as expired
+// without anything having to go round and close it. The first argument is always the current time.
+const reservationStatus = "CASE WHEN R.Status = 'active' AND R.ExpiresAt <= ? THEN 'expired' ELSE R.Status END"
+const selectReservations = "SELECT R.ReservationID, P.SKU, R.LocationID, R.Quantity, " + reservationStatus + ", R.Reference, R.Note, R.UserName, R.ExpiresAt, R.DateCreated, R.DateClosed FROM Reservation R INNER JOIN Product P ON P.ProductID = R.ProductID"
+
+// activeReservations sums the quantity held by unexpired reservations per product and location. It
+// takes the current time as its argument.
+const activeReservations = "SELECT ProductID, LocationID, SUM(Quantity) AS Quantity FROM Reservation WHERE Status = 'active' AND ExpiresAt > ? GROUP BY ProductID, LocationID"
+
+// now is the time reservations are compared against. Expiry times are stored in UTC so they compare
+// correctly on every dialect, including SQLite's text timestamps.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func scanReservations(rows *sql.Rows) ([]*Reservation, error) {
+	defer rows.Close()
+	reservations := make([]*Reservation, 0)
+	for rows.Next() {
+		r := new(Reservation)
+		var closed sql.NullString
+		if err := rows.Scan(&r.ReservationID, &r.SKU, &r.LocationID, &r.Quantity, &r.Status, &r.Reference, &r.Note, &r.User, &r.ExpiresAt, &r.DateCreated, &closed); err != nil {
+			return nil, err
+		}
+		r.DateClosed = closed.String
+		reservations = append(reservations, r)
+	}
+	return reservations, rows.Err()
+}
+
+// findReservation returns the reservation with the given ID, locking it if the dialect can.
+func (s *SQLStore) findReservation(tx *sql.Tx, id int, lock bool) (*Reservation, error) {
+	query := selectReservations + " WHERE R.ReservationID = ?"
+	if lock {
+		query += s.Dialect.forUpdate()
+	}
+	rows, err := s.query(tx, query, now(), id)
+	if err != nil {
+		return nil, err
+	}
+	reservations, err := scanReservations(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(reservations) == 0 {
+		return nil, ErrNotFound
+	}
+	return reservations[0], nil
+}
+
+// reserved returns the quantity held by active reservations, by ProductID and LocationID. Only the
+// active ones are summed, so this stays small however long the history gets.
+func (s *SQLStore) reserved(tx *sql.Tx) (map[[2]int]int, error) {
+	rows, err := s.query(tx, activeReservations, now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	held := make(map[[2]int]int)
+	for rows.Next() {
+		var product, location, quantity int
+		if err := rows.Scan(&product, &location, &quantity); err != nil {
+			return nil, err
+		}
+		held[[2]int{product, location}] = quantity
+	}
+	return held, rows.Err()
+}
+
+// fillReserved sets Reserved and Available on inventory rows. It is a query of its own rather than a
+// join so the locking inventory queries stay simple.
+func (s *SQLStore) fillReserved(tx *sql.Tx, inv []*Inventory) error {
+	held, err := s.reserved(tx)
+	if err != nil {
+		return err
+	}
+	for _, i := range inv {
+		i.Reserved = held[[2]int{i.ProductID, i.LocationID}]
+		i.Available = i.Quantity - i.Reserved
+	}
+	return nil
+}
+
+// ListReservations returns the reservations of the given SKU, newest first, optionally only those with
+// the given status.
+func (s *SQLStore) ListReservations(sku int, status string) (reservations []*Reservation, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.findProduct(tx, sku); err != nil {
+			return err
+		}
+		query, args := selectReservations+" WHERE P.SKU = ?", []interface{}{now(), sku}
+		if status != "" {
+			query, args = query+" AND "+reservationStatus+" = ?", append(args, now(), status)
+		}
+		rows, err := s.query(tx, query+" ORDER BY R.ReservationID DESC", args...)
+		if err != nil {
+			return err
+		}
+		reservations, err = scanReservations(rows)
+		return err
+	})
+	return reservations, err
+}
+
+// GetReservation returns the reservation with the given ID.
+func (s *SQLStore) GetReservation(id int) (r *Reservation, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		r, err = s.findReservation(tx, id, false)
+		return err
+	})
+	return r, err
+}
+
+// CreateReservation holds r.Quantity of the SKU at r.LocationID. Only available stock can be reserved,
+// unless the product's negative stock policy is allow, so two orders can't be promised the same units.
+func (s *SQLStore) CreateReservation(r *Reservation, expires time.Time) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return s.reserve(tx, r, expires)
+	})
+}
+
+// reserve is CreateReservation inside a transaction the caller already holds, so one that reserves
+// several SKUs gets all of them or none.
+func (s *SQLStore) reserve(tx *sql.Tx, r *Reservation, expires time.Time) error {
+	// Locking the inventory row queues up reservations of the same stock
+	inv, err := s.lockInventory(tx, r.SKU, r.LocationID)
+	if err != nil {
+		return err
+	}
+	held, err := s.reserved(tx)
+	if err != nil {
+		return err
+	}
+	if available := inv.Quantity - held[[2]int{inv.ProductID, inv.LocationID}]; r.Quantity > available {
+		policy, err := s.stockPolicy(tx, inv.ProductID)
+		if err != nil {
+			return err
+		}
+		// A hold for less than the order is no use to it, so clamp refuses like reject does
+		if policy != NegativeStockAllow {
+			return &StockError{SKU: r.SKU, LocationID: inv.LocationID, Quantity: available, Requested: -r.Quantity}
+		}
+	}
+
+	created := time.Now()
+	expires = expires.UTC()
+	id, err := s.insert(tx, "INSERT INTO Reservation (ProductID, LocationID, Quantity, Status, Reference, Note, UserName, ExpiresAt, DateCreated) VALUES(?,?,?,?,?,?,?,?,?)", "ReservationID", inv.ProductID, inv.LocationID, r.Quantity, ReservationActive, r.Reference, r.Note, r.User, expires, created)
+	if err != nil {
+		return err
+	}
+	r.ReservationID, r.Status, r.ExpiresAt, r.DateCreated = int(id), ReservationActive, expires.Format(time.RFC3339), created.Format(time.RFC3339)
+	return nil
+}
+
+// closeReservation marks a reservation released or consumed.
+func (s *SQLStore) closeReservation(tx *sql.Tx, r *Reservation, status string) error {
+	closed := time.Now()
+	_, err := s.exec(tx, "UPDATE Reservation SET Status = ?, DateClosed = ? WHERE ReservationID = ?", status, closed, r.ReservationID)
+	r.Status, r.DateClosed = status, closed.Format(time.RFC3339)
+	return err
+}
+
+// ReleaseReservation hands the stock held by the reservation with the given ID back. An expired
+// reservation can still be released, which just records that it is done with.
+func (s *SQLStore) ReleaseReservation(id int) (r *Reservation, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		r, err = s.findReservation(tx, id, true)
+		if err != nil {
+			return err
+		}
+		if r.Status != ReservationActive && r.Status != ReservationExpired {
+			return ErrReservationClosed
+		}
+		return s.closeReservation(tx, r, ReservationReleased)
+	})
+	return r, err
+}
+
+// ConsumeReservation takes the reserved quantity out of stock, e.g. when the order ships, and records
+// m in the movement ledger. The reservation and the stock change together or not at all.
+func (s *SQLStore) ConsumeReservation(id int, m *InventoryMovement) (r *Reservation, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		r, err = s.findReservation(tx, id, true)
+		if err != nil {
+			return err
+		}
+		if r.Status != ReservationActive {
+			return ErrReservationClosed
+		}
+		if m.Note == "" {
+			m.Note = "reservation " + strconv.Itoa(r.ReservationID)
+		}
+		// Closed first, so the stock it holds counts as available to the change taking it
+		if err := s.closeReservation(tx, r, ReservationConsumed); err != nil {
+			return err
+		}
+		return s.adjustInventory(tx, r.SKU, r.LocationID, -r.Quantity, m)
+	})
+	return r, err
+}
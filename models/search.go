@@ -0,0 +1,278 @@
+package models
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ProductMatch - a product found by SearchProducts and how well it matched, the higher the better.
+type ProductMatch struct {
+	*Product
+	Score float64 `json:"score"`
+}
+
+// SearchHit - a product found in a SearchIndex, by ProductID.
+type SearchHit struct {
+	ProductID int
+	Score     float64
+}
+
+// How much a word counts depending on the field it is in, the name says the most about a product
+const (
+	nameWeight       = 3
+	colorWeight      = 2
+	sizeWeight       = 2
+	trimColorWeight  = 1
+	dimensionsWeight = 1
+)
+
+// searchCandidates caps how many products MySQL's FULLTEXT index hands over to be ranked
+const searchCandidates = 200
+
+// SearchIndex is an in-memory index of the words in the fields products are searched on: ProductName,
+// Color, TrimColor, Size and Dimensions. It doesn't change once built.
+type SearchIndex struct {
+	words map[string]map[int]float64 // word -> ProductID -> weight of the heaviest field it is in
+}
+
+// NewSearchIndex indexes prods.
+func NewSearchIndex(prods []*Product) *SearchIndex {
+	ix := &SearchIndex{words: make(map[string]map[int]float64)}
+	for _, p := range prods {
+		ix.add(p)
+	}
+	return ix
+}
+
+func (ix *SearchIndex) add(p *Product) {
+	fields := []struct {
+		text   string
+		weight float64
+	}{{p.ProductName, nameWeight}, {p.Color, colorWeight}, {p.TrimColor, trimColorWeight}, {p.Size, sizeWeight}, {p.Dimensions, dimensionsWeight}}
+	for _, f := range fields {
+		for _, word := range searchWords(f.text) {
+			ids, ok := ix.words[word]
+			if !ok {
+				ids = make(map[int]float64)
+				ix.words[word] = ids
+			}
+			if f.weight > ids[p.ProductID] {
+				ids[p.ProductID] = f.weight
+			}
+		}
+	}
+}
+
+// Search ranks the products matching any word of q, best first. Each word of q scores the weight of the
+// field its closest match is in times how close it is (see closeness), and the total is scaled by the
+// share of the words that matched, so a product matching all of them comes before one that only matches
+// some of them more often. Ties go to the lower ProductID.
+func (ix *SearchIndex) Search(q string) []SearchHit {
+	terms := searchWords(q)
+	scores := make(map[int]float64)
+	matched := make(map[int]int)
+	for _, term := range terms {
+		best := make(map[int]float64)
+		for word, ids := range ix.words {
+			c := closeness(term, word)
+			if c == 0 {
+				continue
+			}
+			for id, weight := range ids {
+				if c*weight > best[id] {
+					best[id] = c * weight
+				}
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+			matched[id]++
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(scores))
+	for id, score := range scores {
+		score *= float64(matched[id]) / float64(len(terms))
+		hits = append(hits, SearchHit{ProductID: id, Score: math.Round(score*100) / 100})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ProductID < hits[j].ProductID
+	})
+	return hits
+}
+
+// searchWords splits text into lower case words of letters and digits.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// closeness is how well the searched term matches word: 1 when they are the same, less when word only
+// starts with term or term is a typo or two away from it, and 0 when they don't match. Short terms
+// have to be spelled right, "red" is one typo away from too many words.
+func closeness(term string, word string) float64 {
+	if term == word {
+		return 1
+	}
+	if len(term) >= 2 && strings.HasPrefix(word, term) {
+		return 0.75
+	}
+	typos := 0
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		typos = 2
+	case n >= 4:
+		typos = 1
+	}
+	if d := editDistance([]rune(term), []rune(word), typos); d <= typos {
+		return 1 - 0.3*float64(d)
+	}
+	return 0
+}
+
+// editDistance counts the letters to insert, delete, replace or swap with their neighbour to turn a into
+// b. It gives up with max+1 once the difference in length alone is more than max.
+func editDistance(a []rune, b []rune, max int) int {
+	if len(a)-len(b) > max || len(b)-len(a) > max {
+		return max + 1
+	}
+	// d[i][j] is the distance between a[:i] and b[:j]
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// SearchProducts returns up to limit products not flagged as deleted that match q, best match first.
+func (s *SQLStore) SearchProducts(q string, limit int) ([]*ProductMatch, error) {
+	matches := make([]*ProductMatch, 0)
+	err := s.withTx(func(tx *sql.Tx) error {
+		ix, err := s.searchIndex(tx, q)
+		if err != nil {
+			return err
+		}
+		hits := ix.Search(q)
+		if len(hits) > limit {
+			hits = hits[:limit]
+		}
+		if len(hits) == 0 {
+			return nil
+		}
+
+		args := []interface{}{now()}
+		for _, h := range hits {
+			args = append(args, h.ProductID)
+		}
+		rows, err := s.query(tx, selectProducts+" WHERE P.Deleted = 0 AND P.ProductID IN (?"+strings.Repeat(",?", len(hits)-1)+")", args...)
+		if err != nil {
+			return err
+		}
+		prods, err := scanProducts(rows)
+		if err != nil {
+			return err
+		}
+		byID := make(map[int]*Product)
+		for _, p := range prods {
+			byID[p.ProductID] = p
+		}
+		for _, h := range hits {
+			if p, ok := byID[h.ProductID]; ok {
+				matches = append(matches, &ProductMatch{Product: p, Score: h.Score})
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// searchIndex returns the index to search for q in. On MySQL it only holds the products its FULLTEXT
+// index (see migration 0015) finds for q: that index is split into pairs of letters, so a misspelt word
+// still shares some with the right one. The other dialects have no such index and keep one of every
+// product instead.
+func (s *SQLStore) searchIndex(tx *sql.Tx, q string) (*SearchIndex, error) {
+	if s.Dialect == MySQL {
+		match := "MATCH (ProductName, Color, TrimColor, Size, Dimensions) AGAINST (?)"
+		return s.loadSearchIndex(tx, " AND "+match+" ORDER BY "+match+" DESC LIMIT ?", q, q, searchCandidates)
+	}
+
+	s.searchMu.Lock()
+	ix, gen := s.search, s.searchGen
+	s.searchMu.Unlock()
+	if ix != nil {
+		return ix, nil
+	}
+	ix, err := s.loadSearchIndex(tx, "")
+	if err != nil {
+		return nil, err
+	}
+	// Products that changed while it was loading may or may not be in it, keep it for the next search
+	// only if nothing did
+	s.searchMu.Lock()
+	if s.searchGen == gen {
+		s.search = ix
+	}
+	s.searchMu.Unlock()
+	return ix, nil
+}
+
+// loadSearchIndex indexes the products not flagged as deleted, narrowed down by clause.
+func (s *SQLStore) loadSearchIndex(tx *sql.Tx, clause string, args ...interface{}) (*SearchIndex, error) {
+	rows, err := s.query(tx, "SELECT ProductID, ProductName, Color, TrimColor, Size, Dimensions FROM Product WHERE Deleted = 0"+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ix := NewSearchIndex(nil)
+	for rows.Next() {
+		p := new(Product)
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.Color, &p.TrimColor, &p.Size, &p.Dimensions); err != nil {
+			return nil, err
+		}
+		ix.add(p)
+	}
+	return ix, rows.Err()
+}
+
+// withProductTx is withTx for changes to the fields products are searched on, dropping the search index
+// once they are committed.
+func (s *SQLStore) withProductTx(fn func(tx *sql.Tx) error) error {
+	err := s.withTx(fn)
+	if err == nil {
+		s.searchMu.Lock()
+		s.search = nil
+		s.searchGen++
+		s.searchMu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// InitSQLite opens (creating if needed) the SQLite database file at path.
+func InitSQLite(path string) (*sql.DB, error) {
+	// SQLite only allows one writer at a time. _txlock=immediate takes the write lock when a transaction
+	// begins rather than on its first write, so concurrent updates queue up (for up to _busy_timeout ms)
+	// instead of reading the same quantity or failing with "database is locked" half way through.
+	db, err := NewDB("sqlite3", "file:"+path+"?_busy_timeout=10000&_foreign_keys=on&_journal_mode=WAL&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
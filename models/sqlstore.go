@@ -0,0 +1,447 @@
+package models
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// SQLStore implements ProductStore and InventoryStore on top of a database/sql connection.
+// Queries are written with ? placeholders and rebound for the Dialect before they run.
+type SQLStore struct {
+	Db      *sql.DB
+	Dialect Dialect
+	// NegativeStock is the policy for products that don't set their own, see NegativeStockReject.
+	NegativeStock string
+
+	// search is the index SearchProducts keeps of every product on dialects without a FULLTEXT index,
+	// built on first use and dropped whenever products change. searchGen counts the changes.
+	searchMu  sync.Mutex
+	search    *SearchIndex
+	searchGen int
+}
+
+// NewSQLStore wraps an open database connection speaking the given dialect.
+func NewSQLStore(db *sql.DB, dialect Dialect) *SQLStore {
+	return &SQLStore{Db: db, Dialect: dialect}
+}
+
+// Column lists are spelled out so scans don't depend on the physical column order of the tables.
+const productColumns = "ProductID, ProductName, NotificationQuantity, Color, TrimColor, Size, Price, Dimensions, SKU, Deleted, NegativeStock, StyleID"
+const inventoryColumns = "I.InventoryID, I.Quantity, I.DateLastUpdated, I.Deleted, I.ProductID, I.LocationID"
+
+// A product's on hand quantity is the total over every location it is kept at, and its reserved quantity
+// the total of its active reservations. The query takes the current time as its first argument, for
+// telling which reservations have expired.
+const selectProducts = "SELECT P.ProductID, P.ProductName, P.NotificationQuantity, P.Color, P.TrimColor, P.Size, P.Price, P.Dimensions, P.SKU, P.Deleted, P.NegativeStock, P.StyleID, I.Quantity, R.Quantity FROM Product P LEFT JOIN (SELECT ProductID, SUM(Quantity) AS Quantity FROM Inventory WHERE Deleted = 0 GROUP BY ProductID) I ON P.ProductID = I.ProductID LEFT JOIN (SELECT ProductID, SUM(Quantity) AS Quantity FROM Reservation WHERE Status = 'active' AND ExpiresAt > ? GROUP BY ProductID) R ON P.ProductID = R.ProductID"
+const selectInventories = "SELECT " + inventoryColumns + ", P.SKU, L.Warehouse, L.Aisle, L.Bin FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID"
+
+func (s *SQLStore) query(tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Query(s.Dialect.Rebind(query), args...)
+}
+
+func (s *SQLStore) exec(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Exec(s.Dialect.Rebind(query), args...)
+}
+
+// insert runs an INSERT and returns the generated value of the key column.
+func (s *SQLStore) insert(tx *sql.Tx, query string, key string, args ...interface{}) (int64, error) {
+	if s.Dialect == Postgres {
+		// lib/pq doesn't support LastInsertId
+		var id int64
+		err := tx.QueryRow(s.Dialect.Rebind(query+" RETURNING "+key), args...).Scan(&id)
+		return id, err
+	}
+	res, err := s.exec(tx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// withTx runs fn inside a transaction, committing if fn returns nil and rolling back otherwise.
+func (s *SQLStore) withTx(fn func(tx *sql.Tx) error) (err error) {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			tx.Rollback()
+		}
+	}()
+
+	return fn(tx)
+}
+
+func scanProducts(rows *sql.Rows) ([]*Product, error) {
+	all, err := scanAllProducts(rows)
+	if err != nil {
+		return nil, err
+	}
+	prods := make([]*Product, 0, len(all))
+	for _, p := range all {
+		if p.Deleted == 0 {
+			prods = append(prods, p)
+		}
+	}
+	return prods, nil
+}
+
+// scanAllProducts is scanProducts keeping the products flagged as deleted.
+func scanAllProducts(rows *sql.Rows) ([]*Product, error) {
+	defer rows.Close()
+	prods := make([]*Product, 0)
+	for rows.Next() {
+		p := new(Product)
+		// The quantities come from LEFT JOINs and are NULL for products without inventory or reservations
+		var onHand, reserved sql.NullInt64
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted, &p.NegativeStock, &p.StyleID, &onHand, &reserved); err != nil {
+			return nil, err
+		}
+		p.OnHand, p.Reserved = int(onHand.Int64), int(reserved.Int64)
+		p.Available = p.OnHand - p.Reserved
+		prods = append(prods, p)
+	}
+	return prods, rows.Err()
+}
+
+func scanInventories(rows *sql.Rows) ([]*Inventory, error) {
+	defer rows.Close()
+	inv := make([]*Inventory, 0)
+	for rows.Next() {
+		i := &Inventory{Location: new(Location)}
+		if err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.LocationID, &i.SKU, &i.Location.Warehouse, &i.Location.Aisle, &i.Location.Bin); err != nil {
+			return nil, err
+		}
+		i.Location.LocationID = i.LocationID
+		if i.Deleted == 0 {
+			inv = append(inv, i)
+		}
+	}
+	return inv, rows.Err()
+}
+
+// findProduct looks up the bare Product row (without inventory) for a SKU.
+func (s *SQLStore) findProduct(tx *sql.Tx, sku int) (*Product, error) {
+	rows, err := s.query(tx, "SELECT "+productColumns+" FROM Product WHERE SKU = ?", sku)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p := new(Product)
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted, &p.NegativeStock, &p.StyleID); err != nil {
+			return nil, err
+		}
+		if p.Deleted == 0 {
+			return p, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotFound
+}
+
+func (s *SQLStore) findInventory(tx *sql.Tx, sku int) ([]*Inventory, error) {
+	rows, err := s.query(tx, selectInventories+" WHERE P.SKU = ? ORDER BY L.Warehouse, L.Aisle, L.Bin", sku)
+	if err != nil {
+		return nil, err
+	}
+	inv, err := scanInventories(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(inv) == 0 {
+		return nil, ErrNotFound
+	}
+	return inv, s.fillReserved(tx, inv)
+}
+
+// lockInventory returns the inventory row for a SKU at one location, for a transaction that is about
+// to change its quantity. The row stays locked until the transaction ends, so two scans of the same SKU
+// can't interleave. A product that has never been kept at the location gets an empty row there.
+func (s *SQLStore) lockInventory(tx *sql.Tx, sku int, location int) (*Inventory, error) {
+	// Only the Inventory row is locked, neither dialect locks the rows a subquery reads. Locking the
+	// joined Product and Location rows as well would queue every change at a location behind one lock.
+	var id int
+	err := tx.QueryRow(s.Dialect.Rebind("SELECT InventoryID FROM Inventory WHERE ProductID IN (SELECT ProductID FROM Product WHERE SKU = ?) AND LocationID = ?"+s.Dialect.forUpdate()), sku, location).Scan(&id)
+	if err == nil {
+		rows, err := s.query(tx, selectInventories+" WHERE I.InventoryID = ?", id)
+		if err != nil {
+			return nil, err
+		}
+		inv, err := scanInventories(rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(inv) == 0 {
+			return nil, ErrNotFound
+		}
+		return inv[0], nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	p, err := s.findProduct(tx, sku)
+	if err != nil {
+		return nil, err
+	}
+	l, err := s.findLocation(tx, location)
+	if err != nil {
+		return nil, err
+	}
+	newID, err := s.insert(tx, "INSERT INTO Inventory (Quantity, DateLastUpdated, Deleted, ProductID, LocationID) VALUES(?,?,?,?,?)", "InventoryID", 0, time.Now(), 0, p.ProductID, l.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	return &Inventory{InventoryID: int(newID), ProductID: p.ProductID, SKU: sku, LocationID: l.LocationID, Location: l}, nil
+}
+
+// ListProducts returns every product not flagged as deleted.
+func (s *SQLStore) ListProducts() (prods []*Product, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, selectProducts, now())
+		if err != nil {
+			return err
+		}
+		prods, err = scanProducts(rows)
+		return err
+	})
+	return prods, err
+}
+
+// ListLowStock returns every product with a NotificationQuantity whose quantity is at or below it.
+func (s *SQLStore) ListLowStock() (prods []*Product, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, selectProducts+" WHERE P.NotificationQuantity > 0 AND COALESCE(I.Quantity, 0) <= P.NotificationQuantity ORDER BY P.SKU", now())
+		if err != nil {
+			return err
+		}
+		prods, err = scanProducts(rows)
+		return err
+	})
+	return prods, err
+}
+
+// GetProduct returns the product with the given SKU.
+func (s *SQLStore) GetProduct(sku int) (p *Product, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, selectProducts+" WHERE P.SKU = ?", now(), sku)
+		if err != nil {
+			return err
+		}
+		prods, err := scanProducts(rows)
+		if err != nil {
+			return err
+		}
+		if len(prods) == 0 {
+			return ErrNotFound
+		}
+		p = prods[0]
+		return nil
+	})
+	return p, err
+}
+
+// CreateProduct inserts a new product row.
+func (s *SQLStore) CreateProduct(p *Product) (id int64, err error) {
+	err = s.withProductTx(func(tx *sql.Tx) error {
+		if err := s.checkStyle(tx, p.StyleID); err != nil {
+			return err
+		}
+		id, err = s.insertProduct(tx, p)
+		return err
+	})
+	return id, err
+}
+
+// insertProduct inserts the product row and its empty inventory.
+func (s *SQLStore) insertProduct(tx *sql.Tx, p *Product) (int64, error) {
+	id, err := s.insert(tx, "INSERT INTO Product (ProductName, NotificationQuantity, Color, TrimColor, Size, Price, Dimensions, SKU, NegativeStock, StyleID) VALUES(?,?,?,?,?,?,?,?,?,?)", "ProductID", p.ProductName, p.NotificationQuantity, p.Color, p.TrimColor, p.Size, p.Price, p.Dimensions, p.SKU, p.NegativeStock, p.StyleID)
+	if err != nil {
+		return 0, err
+	}
+	return id, s.createInventory(tx, id)
+}
+
+// createInventory gives a new product its empty inventory row at the default location. Rows at other
+// locations are created by the first change made there.
+func (s *SQLStore) createInventory(tx *sql.Tx, productID int64) error {
+	_, err := s.exec(tx, "INSERT INTO Inventory (Quantity, DateLastUpdated, Deleted, ProductID, LocationID) VALUES(?,?,?,?,?)", 0, time.Now(), 0, productID, DefaultLocation)
+	return err
+}
+
+// UpdateProduct overwrites the product with the given SKU.
+func (s *SQLStore) UpdateProduct(sku int, p *Product) error {
+	return s.withProductTx(func(tx *sql.Tx) error {
+		found, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		if err := s.checkStyle(tx, p.StyleID); err != nil {
+			return err
+		}
+		_, err = s.exec(tx, "UPDATE Product SET ProductName = ?, NotificationQuantity = ?, Color = ?, TrimColor = ?, Size = ?, Price = ?, Dimensions = ?, SKU = ?, NegativeStock = ?, StyleID = ? WHERE ProductID = ?", p.ProductName, p.NotificationQuantity, p.Color, p.TrimColor, p.Size, p.Price, p.Dimensions, p.SKU, p.NegativeStock, p.StyleID, found.ProductID)
+		return err
+	})
+}
+
+// ArchiveProduct flags the product with the given SKU as deleted.
+func (s *SQLStore) ArchiveProduct(sku int) error {
+	return s.withProductTx(func(tx *sql.Tx) error {
+		found, err := s.findProduct(tx, sku)
+		if err != nil {
+			return err
+		}
+		_, err = s.exec(tx, "UPDATE Product SET Deleted = 1 WHERE ProductID = ?", found.ProductID)
+		return err
+	})
+}
+
+// ListInventories returns every inventory row not flagged as deleted, with its reserved quantity.
+func (s *SQLStore) ListInventories() (inv []*Inventory, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, selectInventories)
+		if err != nil {
+			return err
+		}
+		inv, err = scanInventories(rows)
+		if err != nil {
+			return err
+		}
+		return s.fillReserved(tx, inv)
+	})
+	return inv, err
+}
+
+// GetInventory returns the inventory rows for the given SKU, one per location.
+func (s *SQLStore) GetInventory(sku int) (inv []*Inventory, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		inv, err = s.findInventory(tx, sku)
+		return err
+	})
+	return inv, err
+}
+
+// SetInventory overwrites the quantity for the given SKU at location. A negative quantity is subject to
+// the negative stock policy like any other change.
+func (s *SQLStore) SetInventory(sku int, location int, quantity int, m *InventoryMovement) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		inv, err := s.lockInventory(tx, sku, location)
+		if err != nil {
+			return err
+		}
+		// A count says what is there, reserved or not, so only going below zero is refused
+		delta, err := s.checkStock(tx, inv, quantity-inv.Quantity, 0)
+		if err != nil {
+			return err
+		}
+		quantity = inv.Quantity + delta
+		_, err = s.exec(tx, "UPDATE Inventory SET Quantity = ?, DateLastUpdated = ? WHERE InventoryID = ?", quantity, time.Now(), inv.InventoryID)
+		if err != nil {
+			return err
+		}
+		return s.recordMovement(tx, inv, delta, quantity, m)
+	})
+}
+
+// AdjustInventory adds delta to the quantity for the given SKU at location. If the result would be
+// negative the product's negative stock policy decides whether the change is refused, applied or cut
+// short at zero.
+func (s *SQLStore) AdjustInventory(sku int, location int, delta int, m *InventoryMovement) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return s.adjustInventory(tx, sku, location, delta, m)
+	})
+}
+
+// adjustInventory is AdjustInventory inside a transaction the caller already holds, so one that moves
+// stock between locations can make both changes or neither.
+func (s *SQLStore) adjustInventory(tx *sql.Tx, sku int, location int, delta int, m *InventoryMovement) error {
+	inv, err := s.lockInventory(tx, sku, location)
+	if err != nil {
+		return err
+	}
+	held := 0
+	if delta < 0 {
+		if held, err = s.heldAt(tx, inv.ProductID, inv.LocationID); err != nil {
+			return err
+		}
+	}
+	delta, err = s.checkStock(tx, inv, delta, held)
+	if err != nil {
+		return err
+	}
+	// The row is locked, but let the database do the arithmetic anyway so the statement is
+	// safe on its own
+	_, err = s.exec(tx, "UPDATE Inventory SET Quantity = Quantity + ?, DateLastUpdated = ? WHERE InventoryID = ?", delta, time.Now(), inv.InventoryID)
+	if err != nil {
+		return err
+	}
+	return s.recordMovement(tx, inv, delta, inv.Quantity+delta, m)
+}
+
+// recordMovement appends a row to the movement ledger and fills in the rest of m. It must run in the
+// same transaction as the quantity change it describes.
+func (s *SQLStore) recordMovement(tx *sql.Tx, inv *Inventory, delta int, quantity int, m *InventoryMovement) error {
+	now := time.Now()
+	id, err := s.insert(tx, "INSERT INTO InventoryMovement (InventoryID, Delta, Quantity, Reason, Note, UserName, DateCreated) VALUES(?,?,?,?,?,?,?)", "MovementID", inv.InventoryID, delta, quantity, m.Reason, m.Note, m.User, now)
+	if err != nil {
+		return err
+	}
+	m.MovementID = int(id)
+	m.InventoryID, m.SKU, m.LocationID, m.Delta, m.Quantity, m.DateCreated = inv.InventoryID, inv.SKU, inv.LocationID, delta, quantity, now.Format(time.RFC3339)
+	if delta >= 0 {
+		return nil
+	}
+	// Low stock alerts compare the totals either side of the change, reading them after the commit
+	// would let two changes that commit together see the same total
+	return tx.QueryRow(s.Dialect.Rebind("SELECT COALESCE(SUM(Quantity), 0) FROM Inventory WHERE ProductID = ? AND Deleted = 0"), inv.ProductID).Scan(&m.OnHand)
+}
+
+const selectMovements = "SELECT M.MovementID, M.InventoryID, P.SKU, I.LocationID, M.Delta, M.Quantity, M.Reason, M.Note, M.UserName, M.DateCreated FROM InventoryMovement M INNER JOIN Inventory I ON I.InventoryID = M.InventoryID INNER JOIN Product P ON P.ProductID = I.ProductID"
+
+func scanMovements(rows *sql.Rows) ([]*InventoryMovement, error) {
+	defer rows.Close()
+	moves := make([]*InventoryMovement, 0)
+	for rows.Next() {
+		m := new(InventoryMovement)
+		if err := rows.Scan(&m.MovementID, &m.InventoryID, &m.SKU, &m.LocationID, &m.Delta, &m.Quantity, &m.Reason, &m.Note, &m.User, &m.DateCreated); err != nil {
+			return nil, err
+		}
+		moves = append(moves, m)
+	}
+	return moves, rows.Err()
+}
+
+// ListMovements returns a page of the movement ledger for the given SKU, newest first.
+func (s *SQLStore) ListMovements(sku int, limit int, offset int) (moves []*InventoryMovement, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, selectMovements+" WHERE P.SKU = ? ORDER BY M.MovementID DESC LIMIT ? OFFSET ?", sku, limit, offset)
+		if err != nil {
+			return err
+		}
+		moves, err = scanMovements(rows)
+		return err
+	})
+	return moves, err
+}
+
+// MovementsSince returns up to limit movements after the given MovementID, oldest first.
+func (s *SQLStore) MovementsSince(id int, limit int) (moves []*InventoryMovement, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, selectMovements+" WHERE M.MovementID > ? ORDER BY M.MovementID LIMIT ?", id, limit)
+		if err != nil {
+			return err
+		}
+		moves, err = scanMovements(rows)
+		return err
+	})
+	return moves, err
+}
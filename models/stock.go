@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Negative stock policies. A product can pick its own with Product.NegativeStock, otherwise the
+// store wide SQLStore.NegativeStock applies, and reject if that isn't set either.
+const (
+	// NegativeStockReject refuses any change that would take the quantity below zero.
+	NegativeStockReject = "reject"
+	// NegativeStockAllow lets the quantity go negative, e.g. for backorders.
+	NegativeStockAllow = "allow"
+	// NegativeStockClamp applies as much of the change as there is stock for and stops at zero.
+	NegativeStockClamp = "clamp"
+)
+
+// ValidNegativeStock reports whether policy is a known negative stock policy. The empty string is
+// valid and means "use the default".
+func ValidNegativeStock(policy string) bool {
+	switch policy {
+	case "", NegativeStockReject, NegativeStockAllow, NegativeStockClamp:
+		return true
+	}
+	return false
+}
+
+// StockError is returned when a change would oversell a product whose policy is reject. Quantity is
+// what is available at the location the change was made at, on hand less what reservations hold, or
+// what is on hand for an overwrite.
+type StockError struct {
+	SKU        int `json:"sku"`
+	LocationID int `json:"locationid,omitempty"`
+	Quantity   int `json:"quantity"`
+	Requested  int `json:"requested"`
+}
+
+func (e *StockError) Error() string {
+	return fmt.Sprintf("insufficient stock for sku %v: %v on hand, change of %v requested", e.SKU, e.Quantity, e.Requested)
+}
+
+// stockPolicy returns the negative stock policy that applies to the given product.
+func (s *SQLStore) stockPolicy(tx *sql.Tx, productID int) (string, error) {
+	var policy string
+	err := tx.QueryRow(s.Dialect.Rebind("SELECT NegativeStock FROM Product WHERE ProductID = ?"), productID).Scan(&policy)
+	if err != nil {
+		return "", err
+	}
+	if policy == "" {
+		policy = s.NegativeStock
+	}
+	if policy == "" {
+		policy = NegativeStockReject
+	}
+	return policy, nil
+}
+
+// checkStock applies the negative stock policy to a change of delta on the locked row inv and
+// returns the delta that should actually be applied. held is the part of the quantity active
+// reservations hold, reject and clamp don't take those units either. The policy is only looked up
+// when the change would leave less than held.
+func (s *SQLStore) checkStock(tx *sql.Tx, inv *Inventory, delta int, held int) (int, error) {
+	available := inv.Quantity - held
+	if delta >= 0 || available+delta >= 0 {
+		return delta, nil
+	}
+
+	policy, err := s.stockPolicy(tx, inv.ProductID)
+	if err != nil {
+		return 0, err
+	}
+	switch policy {
+	case NegativeStockAllow:
+		return delta, nil
+	case NegativeStockClamp:
+		if available < 0 {
+			return 0, nil
+		}
+		return -available, nil
+	default:
+		return 0, &StockError{SKU: inv.SKU, LocationID: inv.LocationID, Quantity: available, Requested: delta}
+	}
+}
+
+// heldAt returns the quantity active reservations hold of a product at a location.
+func (s *SQLStore) heldAt(tx *sql.Tx, productID int, location int) (int, error) {
+	var held int
+	err := tx.QueryRow(s.Dialect.Rebind("SELECT COALESCE(SUM(Quantity), 0) FROM Reservation WHERE ProductID = ? AND LocationID = ? AND Status = 'active' AND ExpiresAt > ?"), productID, location, now()).Scan(&held)
+	return held, err
+}
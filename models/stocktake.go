@@ -0,0 +1,388 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Stocktake statuses. Counts can only be recorded while a stocktake is open, posting it applies the
+// variances to inventory and cancelling it throws the counts away.
+const (
+	StocktakeOpen      = "open"
+	StocktakePosted    = "posted"
+	StocktakeCancelled = "cancelled"
+)
+
+// ErrStocktakeClosed is returned when counting, posting or cancelling a stocktake that has already been
+// posted or cancelled.
+var ErrStocktakeClosed = errors.New("stocktake is no longer open")
+
+// ErrNotInStocktake is returned when a count is for a product or location outside the stocktake's scope.
+var ErrNotInStocktake = errors.New("product or location is not part of the stocktake")
+
+// Stocktake - a count of the stock on the shelves, limited to one location and/or a list of SKUs or
+// covering everything. The counts are kept apart from inventory until the stocktake is posted.
+type Stocktake struct {
+	StocktakeID int    `json:"stocktakeid"`
+	LocationID  int    `json:"locationid,omitempty"` // 0 counts every location
+	SKUs        []int  `json:"skus,omitempty"`       // empty counts every product
+	Status      string `json:"status"`
+	Note        string `json:"note,omitempty"`
+	User        string `json:"user,omitempty"`
+	DateCreated string `json:"datecreated"`
+	DateClosed  string `json:"dateclosed,omitempty"`
+}
+
+// StocktakeCount - the counted quantity of one SKU at one location. Expected is what inventory said
+// was there when the SKU was first counted, the difference between the two is what posting applies.
+type StocktakeCount struct {
+	SKU         int    `json:"sku"`
+	LocationID  int    `json:"locationid"`
+	Counted     int    `json:"counted"`
+	Expected    int    `json:"expected"`
+	User        string `json:"user,omitempty"`
+	DateCounted string `json:"datecounted,omitempty"`
+}
+
+// StocktakeLine - one line of a variance report.
+type StocktakeLine struct {
+	SKU         int     `json:"sku"`
+	ProductName string  `json:"productname"`
+	LocationID  int     `json:"locationid"`
+	Expected    int     `json:"expected"`
+	Counted     int     `json:"counted"`
+	Variance    int     `json:"variance"` // Counted less Expected
+	Value       float32 `json:"value"`    // Variance at the product's price
+	Uncounted   bool    `json:"uncounted,omitempty"`
+}
+
+// VarianceReport - the counted lines of a stocktake against inventory. While the stocktake is open it
+// also lists the stock in its scope that hasn't been counted yet, posting leaves those alone.
+type VarianceReport struct {
+	Stocktake *Stocktake       `json:"stocktake"`
+	Lines     []*StocktakeLine `json:"lines"`
+	Counted   int              `json:"counted"`   // lines counted
+	Uncounted int              `json:"uncounted"` // lines with stock that haven't been
+	Variance  int              `json:"variance"`  // net units over the counted lines
+	Value     float32          `json:"value"`
+}
+
+// ValidStocktakeStatus reports whether status is one of the stocktake statuses.
+func ValidStocktakeStatus(status string) bool {
+	switch status {
+	case StocktakeOpen, StocktakePosted, StocktakeCancelled:
+		return true
+	}
+	return false
+}
+
+const selectStocktakes = "SELECT StocktakeID, LocationID, Status, Note, UserName, DateCreated, DateClosed FROM Stocktake"
+
+func scanStocktakes(rows *sql.Rows) ([]*Stocktake, error) {
+	defer rows.Close()
+	stocktakes := make([]*Stocktake, 0)
+	for rows.Next() {
+		st := new(Stocktake)
+		var closed sql.NullString
+		if err := rows.Scan(&st.StocktakeID, &st.LocationID, &st.Status, &st.Note, &st.User, &st.DateCreated, &closed); err != nil {
+			return nil, err
+		}
+		st.DateClosed = closed.String
+		stocktakes = append(stocktakes, st)
+	}
+	return stocktakes, rows.Err()
+}
+
+// findStocktake returns the stocktake with the given ID and its SKUs, locking it if the dialect can so
+// counts and posting queue up behind each other.
+func (s *SQLStore) findStocktake(tx *sql.Tx, id int, lock bool) (*Stocktake, error) {
+	query := selectStocktakes + " WHERE StocktakeID = ?"
+	if lock {
+		query += s.Dialect.forUpdate()
+	}
+	rows, err := s.query(tx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	stocktakes, err := scanStocktakes(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(stocktakes) == 0 {
+		return nil, ErrNotFound
+	}
+	st := stocktakes[0]
+
+	rows, err = s.query(tx, "SELECT P.SKU FROM StocktakeProduct S INNER JOIN Product P ON P.ProductID = S.ProductID WHERE S.StocktakeID = ? ORDER BY P.SKU", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sku int
+		if err := rows.Scan(&sku); err != nil {
+			return nil, err
+		}
+		st.SKUs = append(st.SKUs, sku)
+	}
+	return st, rows.Err()
+}
+
+// covers reports whether the stocktake's scope takes in the SKU at location.
+func (st *Stocktake) covers(sku int, location int) bool {
+	if st.LocationID != 0 && st.LocationID != location {
+		return false
+	}
+	if len(st.SKUs) == 0 {
+		return true
+	}
+	for _, s := range st.SKUs {
+		if s == sku {
+			return true
+		}
+	}
+	return false
+}
+
+// ListStocktakes returns a page of stocktakes, newest first. An empty status matches every stocktake.
+func (s *SQLStore) ListStocktakes(status string, limit int, offset int) (stocktakes []*Stocktake, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		query, args := selectStocktakes, []interface{}{}
+		if status != "" {
+			query, args = query+" WHERE Status = ?", append(args, status)
+		}
+		rows, err := s.query(tx, query+" ORDER BY StocktakeID DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+		if err != nil {
+			return err
+		}
+		stocktakes, err = scanStocktakes(rows)
+		return err
+	})
+	return stocktakes, err
+}
+
+// GetStocktake returns the stocktake with the given ID.
+func (s *SQLStore) GetStocktake(id int) (st *Stocktake, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		st, err = s.findStocktake(tx, id, false)
+		return err
+	})
+	return st, err
+}
+
+// CreateStocktake opens a stocktake with the scope of st and fills in the rest of it.
+func (s *SQLStore) CreateStocktake(st *Stocktake) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if st.LocationID != 0 {
+			if _, err := s.findLocation(tx, st.LocationID); err != nil {
+				return err
+			}
+		}
+		productIDs := make(map[int]bool)
+		for _, sku := range st.SKUs {
+			p, err := s.findProduct(tx, sku)
+			if err != nil {
+				return err
+			}
+			productIDs[p.ProductID] = true
+		}
+
+		now := time.Now()
+		id, err := s.insert(tx, "INSERT INTO Stocktake (LocationID, Status, Note, UserName, DateCreated) VALUES(?,?,?,?,?)", "StocktakeID", st.LocationID, StocktakeOpen, st.Note, st.User, now)
+		if err != nil {
+			return err
+		}
+		for productID := range productIDs {
+			if _, err := s.exec(tx, "INSERT INTO StocktakeProduct (StocktakeID, ProductID) VALUES(?,?)", id, productID); err != nil {
+				return err
+			}
+		}
+		st.StocktakeID, st.Status, st.DateCreated = int(id), StocktakeOpen, now.Format(time.RFC3339)
+		return nil
+	})
+}
+
+// RecordCounts records counts against the open stocktake with the given ID, in one transaction. A count
+// replaces an earlier one of the same SKU and location, and the expected quantity is taken from
+// inventory again, unless add is set, in which case it is added to the earlier count the way scans
+// are. Each count is filled in with the resulting line.
+func (s *SQLStore) RecordCounts(id int, counts []*StocktakeCount, add bool) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		st, err := s.findStocktake(tx, id, true)
+		if err != nil {
+			return err
+		}
+		if st.Status != StocktakeOpen {
+			return ErrStocktakeClosed
+		}
+		for _, c := range counts {
+			if !st.covers(c.SKU, c.LocationID) {
+				return ErrNotInStocktake
+			}
+			// Also checks the SKU and location exist, and gives a product that has never been kept at
+			// the location an empty row there to correct
+			inv, err := s.lockInventory(tx, c.SKU, c.LocationID)
+			if err != nil {
+				return err
+			}
+			var counted, expected int
+			err = tx.QueryRow(s.Dialect.Rebind("SELECT Counted, Expected FROM StocktakeCount WHERE StocktakeID = ? AND ProductID = ? AND LocationID = ?"), id, inv.ProductID, inv.LocationID).Scan(&counted, &expected)
+			found := err == nil
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+
+			now := time.Now()
+			if !found || !add {
+				expected = inv.Quantity
+			}
+			if add {
+				c.Counted += counted
+			}
+			if found {
+				_, err = s.exec(tx, "UPDATE StocktakeCount SET Counted = ?, Expected = ?, UserName = ?, DateCounted = ? WHERE StocktakeID = ? AND ProductID = ? AND LocationID = ?", c.Counted, expected, c.User, now, id, inv.ProductID, inv.LocationID)
+			} else {
+				_, err = s.exec(tx, "INSERT INTO StocktakeCount (StocktakeID, ProductID, LocationID, Expected, Counted, UserName, DateCounted) VALUES(?,?,?,?,?,?,?)", id, inv.ProductID, inv.LocationID, expected, c.Counted, c.User, now)
+			}
+			if err != nil {
+				return err
+			}
+			c.Expected, c.DateCounted = expected, now.Format(time.RFC3339)
+		}
+		return nil
+	})
+}
+
+// countedLines returns the counted lines of a stocktake in SKU and location order.
+func (s *SQLStore) countedLines(tx *sql.Tx, id int) ([]*StocktakeLine, error) {
+	rows, err := s.query(tx, "SELECT P.SKU, P.ProductName, P.Price, C.LocationID, C.Expected, C.Counted FROM StocktakeCount C INNER JOIN Product P ON P.ProductID = C.ProductID WHERE C.StocktakeID = ? ORDER BY P.SKU, C.LocationID", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := make([]*StocktakeLine, 0)
+	for rows.Next() {
+		l := new(StocktakeLine)
+		var price float32
+		if err := rows.Scan(&l.SKU, &l.ProductName, &price, &l.LocationID, &l.Expected, &l.Counted); err != nil {
+			return nil, err
+		}
+		l.Variance = l.Counted - l.Expected
+		l.Value = float32(l.Variance) * price
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// uncountedLines returns the inventory rows in the scope of an open stocktake that hold stock but
+// haven't been counted.
+func (s *SQLStore) uncountedLines(tx *sql.Tx, st *Stocktake) ([]*StocktakeLine, error) {
+	query := "SELECT P.SKU, P.ProductName, I.LocationID, I.Quantity FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID WHERE I.Deleted = 0 AND P.Deleted = 0 AND I.Quantity <> 0 AND NOT EXISTS (SELECT 1 FROM StocktakeCount C WHERE C.StocktakeID = ? AND C.ProductID = I.ProductID AND C.LocationID = I.LocationID)"
+	args := []interface{}{st.StocktakeID}
+	if st.LocationID != 0 {
+		query, args = query+" AND I.LocationID = ?", append(args, st.LocationID)
+	}
+	if len(st.SKUs) > 0 {
+		query, args = query+" AND I.ProductID IN (SELECT ProductID FROM StocktakeProduct WHERE StocktakeID = ?)", append(args, st.StocktakeID)
+	}
+	rows, err := s.query(tx, query+" ORDER BY P.SKU, I.LocationID", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := make([]*StocktakeLine, 0)
+	for rows.Next() {
+		l := &StocktakeLine{Uncounted: true}
+		if err := rows.Scan(&l.SKU, &l.ProductName, &l.LocationID, &l.Expected); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// Variance returns the variance report of the stocktake with the given ID.
+func (s *SQLStore) Variance(id int) (report *VarianceReport, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		st, err := s.findStocktake(tx, id, false)
+		if err != nil {
+			return err
+		}
+		lines, err := s.countedLines(tx, id)
+		if err != nil {
+			return err
+		}
+		report = &VarianceReport{Stocktake: st, Lines: lines, Counted: len(lines)}
+		for _, l := range lines {
+			report.Variance += l.Variance
+			report.Value += l.Value
+		}
+		if st.Status != StocktakeOpen {
+			return nil
+		}
+		uncounted, err := s.uncountedLines(tx, st)
+		if err != nil {
+			return err
+		}
+		report.Lines, report.Uncounted = append(report.Lines, uncounted...), len(uncounted)
+		return nil
+	})
+	return report, err
+}
+
+// closeStocktake marks a stocktake posted or cancelled.
+func (s *SQLStore) closeStocktake(tx *sql.Tx, st *Stocktake, status string) error {
+	closed := time.Now()
+	_, err := s.exec(tx, "UPDATE Stocktake SET Status = ?, DateClosed = ? WHERE StocktakeID = ?", status, closed, st.StocktakeID)
+	st.Status, st.DateClosed = status, closed.Format(time.RFC3339)
+	return err
+}
+
+// PostStocktake applies the variance of every counted line to inventory as a count-correction, all in
+// one transaction, and closes the stocktake. Applying the variance rather than overwriting the quantity
+// keeps any sales made since a line was counted. The movements are returned in SKU and location order.
+func (s *SQLStore) PostStocktake(id int, user string) (st *Stocktake, moves []*InventoryMovement, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		st, err = s.findStocktake(tx, id, true)
+		if err != nil {
+			return err
+		}
+		if st.Status != StocktakeOpen {
+			return ErrStocktakeClosed
+		}
+		lines, err := s.countedLines(tx, id)
+		if err != nil {
+			return err
+		}
+		moves = make([]*InventoryMovement, 0)
+		for _, l := range lines {
+			if l.Variance == 0 {
+				continue
+			}
+			m := &InventoryMovement{Reason: ReasonCountCorrection, Note: "stocktake " + strconv.Itoa(id), User: user}
+			if err := s.adjustInventory(tx, l.SKU, l.LocationID, l.Variance, m); err != nil {
+				return err
+			}
+			moves = append(moves, m)
+		}
+		return s.closeStocktake(tx, st, StocktakePosted)
+	})
+	return st, moves, err
+}
+
+// CancelStocktake closes the open stocktake with the given ID without touching inventory.
+func (s *SQLStore) CancelStocktake(id int) (st *Stocktake, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		st, err = s.findStocktake(tx, id, true)
+		if err != nil {
+			return err
+		}
+		if st.Status != StocktakeOpen {
+			return ErrStocktakeClosed
+		}
+		return s.closeStocktake(tx, st, StocktakeCancelled)
+	})
+	return st, err
+}
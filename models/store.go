@@ -0,0 +1,276 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by a store when the requested row does not exist or has been archived.
+var ErrNotFound = errors.New("not found")
+
+// ErrExists is returned by a store when a row with the same unique key is already there.
+var ErrExists = errors.New("already exists")
+
+// ProductStore is the storage behind the /product routes. Products are addressed by SKU.
+type ProductStore interface {
+	// ListProducts returns every product not flagged as deleted, with its inventory quantity.
+	ListProducts() ([]*Product, error)
+	// ListLowStock returns the products whose quantity is at or below their NotificationQuantity.
+	// Products with a NotificationQuantity of 0 are never low.
+	ListLowStock() ([]*Product, error)
+	// GetProduct returns the product with the given SKU, or ErrNotFound.
+	GetProduct(sku int) (*Product, error)
+	// CreateProduct inserts the product and returns its new ProductID. A store that keeps styles returns
+	// ErrStyleNotFound if StyleID names one it doesn't have.
+	CreateProduct(p *Product) (int64, error)
+	// UpdateProduct overwrites every field of the product with the given SKU, StyleID included.
+	UpdateProduct(sku int, p *Product) error
+	// ArchiveProduct flags the product with the given SKU as deleted.
+	ArchiveProduct(sku int) error
+	// FindBarcode returns the barcode matching code, or ErrNotFound.
+	FindBarcode(code string) (*Barcode, error)
+	// ListBarcodes returns the barcodes of the product with the given SKU, or ErrNotFound.
+	ListBarcodes(sku int) ([]*Barcode, error)
+	// AddBarcode links b to the product with the given SKU. It returns ErrExists if the code is already
+	// linked to a product, archived ones included.
+	AddBarcode(sku int, b *Barcode) error
+	// DeleteBarcode unlinks code from its product, or returns ErrNotFound.
+	DeleteBarcode(code string) error
+}
+
+// BOMStore is the storage behind the /product/{sku}/bom routes. A product store that can keep kits
+// implements it alongside ProductStore.
+type BOMStore interface {
+	// GetBOM returns the bill of materials of the product with the given SKU, with the stock of each
+	// component and how many kits it covers, or ErrNotFound.
+	GetBOM(sku int) (*BOM, error)
+	// SetBOM replaces the bill of materials of the product with the given SKU in one transaction and
+	// returns the result. It returns ErrNotFound for an unknown SKU, either the kit's or a component's,
+	// and ErrBOMCycle if the kit would end up inside itself.
+	SetBOM(sku int, components []*BOMComponent) (*BOM, error)
+}
+
+// StyleStore is the storage behind the /styles routes and the grouped product listing. A product store
+// that can keep product variants implements it alongside ProductStore.
+type StyleStore interface {
+	// ListStyles returns every style not flagged as deleted, without its variants.
+	ListStyles() ([]*Style, error)
+	// GetStyle returns the style with the given ID and its variants, or ErrStyleNotFound.
+	GetStyle(id int) (*Style, error)
+	// CreateStyle inserts the style with its colors, trim colors and sizes and fills in its StyleID.
+	CreateStyle(st *Style) error
+	// UpdateStyle overwrites the style with the given ID, or returns ErrStyleNotFound.
+	UpdateStyle(id int, st *Style) error
+	// DeleteStyle flags the style with the given ID as deleted. It returns ErrInUse while the style
+	// still has variants.
+	DeleteStyle(id int) error
+	// GenerateVariants creates a product, with the next free SKU, for every combination of the style's
+	// colors, trim colors and sizes that it has no variant for, in one transaction. It returns the new
+	// products, or ErrStyleNotFound.
+	GenerateVariants(id int) ([]*Product, error)
+}
+
+// CategoryStore is the storage behind the /categories and /tags routes.
+// A product store that can sort products into categories and tag them implements it alongside
+// ProductStore.
+type CategoryStore interface {
+	// ListCategories returns every category not flagged as deleted, ordered by path.
+	ListCategories() ([]*Category, error)
+	// GetCategory returns the category with the given ID, or ErrCategoryNotFound.
+	GetCategory(id int) (*Category, error)
+	// CreateCategory inserts the category and fills in its CategoryID and path. It returns
+	// ErrCategoryNotFound for an unknown parent and ErrExists if the parent already has a category of
+	// that name.
+	CreateCategory(c *Category) error
+	// UpdateCategory renames and moves the category with the given ID like CreateCategory checks a new
+	// one, and returns ErrCategoryCycle if it would end up below itself.
+	UpdateCategory(id int, c *Category) error
+	// DeleteCategory flags the category with the given ID as deleted and takes its products out of it.
+	// It returns ErrInUse while the category has subcategories.
+	DeleteCategory(id int) error
+	// GetProductCategories returns the categories of the product with the given SKU, or ErrNotFound.
+	GetProductCategories(sku int) ([]*Category, error)
+	// SetProductCategories replaces the categories of the product with the given SKU in one transaction
+	// and returns the result. It returns ErrNotFound for an unknown SKU and ErrCategoryNotFound for an
+	// unknown category.
+	SetProductCategories(sku int, ids []int) ([]*Category, error)
+	// ListTags returns every tag in use and how many products carry it.
+	ListTags() ([]*Tag, error)
+	// GetProductTags returns the tags of the product with the given SKU, or ErrNotFound.
+	GetProductTags(sku int) ([]string, error)
+	// SetProductTags replaces the tags of the product with the given SKU and returns them normalized, see
+	// NormalizeTag. It returns ErrNotFound for an unknown SKU.
+	SetProductTags(sku int, tags []string) ([]string, error)
+	// RenameTag renames a tag on every product carrying it, or returns ErrNotFound if none does.
+	RenameTag(tag string, to string) error
+	// DeleteTag takes a tag off every product carrying it, or returns ErrNotFound if none does.
+	DeleteTag(tag string) error
+}
+
+// ProductQueryStore is the storage behind the paged product listing. A product store that can page,
+// sort and filter products in the database implements it alongside ProductStore and CategoryStore.
+type ProductQueryStore interface {
+	// QueryProducts returns the page of products not flagged as deleted that q asks for, every match
+	// with a q.Limit of 0, and how many match it over every page. It returns ErrCategoryNotFound if q
+	// names an unknown category.
+	QueryProducts(q *ProductQuery) ([]*Product, int, error)
+}
+
+// SearchStore is the storage behind /product/search. A product store that can rank products by how well
+// they match some words implements it alongside ProductStore.
+type SearchStore interface {
+	// SearchProducts returns up to limit products not flagged as deleted whose name, color, trim color,
+	// size or dimensions match the words of q, allowing for typos, best match first.
+	SearchProducts(q string, limit int) ([]*ProductMatch, error)
+}
+
+// InventoryStore is the storage behind the /inventory and /locations routes. Inventories are addressed
+// by product SKU and LocationID, a SKU has one row per location it is kept at.
+type InventoryStore interface {
+	LocationStore
+	// ListInventories returns every inventory row not flagged as deleted.
+	ListInventories() ([]*Inventory, error)
+	// GetInventory returns the inventory rows for the given SKU, one per location, or ErrNotFound.
+	GetInventory(sku int) ([]*Inventory, error)
+	// SetInventory overwrites the quantity for the given SKU at location. m supplies the reason, note and
+	// user recorded in the movement ledger, the store fills in the rest (including the resulting
+	// quantity). It returns ErrLocationNotFound if the location doesn't exist.
+	SetInventory(sku int, location int, quantity int, m *InventoryMovement) error
+	// AdjustInventory adds delta (which may be negative) to the quantity for the given SKU at location and
+	// records m. A change that would oversell a product whose negative stock policy is reject returns a
+	// *StockError.
+	AdjustInventory(sku int, location int, delta int, m *InventoryMovement) error
+	// ListMovements returns a page of the movement ledger for the given SKU, newest first.
+	ListMovements(sku int, limit int, offset int) ([]*InventoryMovement, error)
+	// MovementsSince returns up to limit movements of any SKU with a MovementID above id, oldest first.
+	MovementsSince(id int, limit int) ([]*InventoryMovement, error)
+}
+
+// InventoryQueryStore is the storage behind the paged inventory listing. An inventory store that can
+// page, sort and filter inventory rows in the database implements it alongside InventoryStore.
+type InventoryQueryStore interface {
+	// QueryInventories returns the page of inventory rows not flagged as deleted that q asks for, every
+	// match with a q.Limit of 0, and how many match it over every page.
+	QueryInventories(q *InventoryQuery) ([]*Inventory, int, error)
+}
+
+// LocationStore is the storage behind the /locations routes.
+type LocationStore interface {
+	// ListLocations returns every location not flagged as deleted.
+	ListLocations() ([]*Location, error)
+	// GetLocation returns the location with the given ID, or ErrLocationNotFound.
+	GetLocation(id int) (*Location, error)
+	// CreateLocation inserts the location and fills in its LocationID. It returns ErrExists if there is
+	// already a location with the same warehouse, aisle and bin.
+	CreateLocation(l *Location) error
+	// UpdateLocation overwrites the location with the given ID, or returns ErrLocationNotFound.
+	UpdateLocation(id int, l *Location) error
+	// DeleteLocation flags the location with the given ID as deleted. It returns ErrInUse for the
+	// default location and for one that still has stock, transfers not yet received or active reservations.
+	DeleteLocation(id int) error
+}
+
+// TransferStore is the storage behind the /transfers routes. A store that moves stock between locations
+// implements it alongside InventoryStore.
+type TransferStore interface {
+	// ListTransfers returns a page of transfers, newest first, optionally only those with the given
+	// status or SKU.
+	ListTransfers(status string, sku int, limit int, offset int) ([]*Transfer, error)
+	// GetTransfer returns the transfer with the given ID, or ErrNotFound.
+	GetTransfer(id int) (*Transfer, error)
+	// InTransit returns the quantity of the given SKU in transfers that have shipped but not arrived.
+	InTransit(sku int) (int, error)
+	// CreateTransfer records t and moves it on to t.Status (requested if empty) in one transaction,
+	// returning the movements that made. It returns ErrNotFound or ErrLocationNotFound for an unknown
+	// SKU or location, and a *StockError if the from location can't cover it.
+	CreateTransfer(t *Transfer) ([]*InventoryMovement, error)
+	// UpdateTransferStatus moves the transfer with the given ID on to status in one transaction: shipping
+	// takes the stock out of the from location, receiving puts it into the to location. It returns
+	// ErrTransferStatus if the transfer can't reach status from where it is.
+	UpdateTransferStatus(id int, status string, user string) (*Transfer, []*InventoryMovement, error)
+}
+
+// ReservationStore is the storage behind the reservation routes. A store that can hold stock for
+// orders implements it alongside InventoryStore.
+type ReservationStore interface {
+	// ListReservations returns the reservations of the given SKU, newest first, optionally only those
+	// with the given status. It returns ErrNotFound for an unknown SKU.
+	ListReservations(sku int, status string) ([]*Reservation, error)
+	// GetReservation returns the reservation with the given ID, or ErrNotFound.
+	GetReservation(id int) (*Reservation, error)
+	// CreateReservation holds r.Quantity of r.SKU at r.LocationID until expires and fills in the rest of
+	// r. It returns a *StockError if that much isn't available, ErrNotFound or ErrLocationNotFound for
+	// an unknown SKU or location.
+	CreateReservation(r *Reservation, expires time.Time) error
+	// ReleaseReservation hands the held stock back, or returns ErrReservationClosed if the reservation
+	// was already released or consumed.
+	ReleaseReservation(id int) (*Reservation, error)
+	// ConsumeReservation takes the held quantity out of stock and records m, in one transaction. It
+	// returns ErrReservationClosed unless the reservation is active.
+	ConsumeReservation(id int, m *InventoryMovement) (*Reservation, error)
+}
+
+// WorkOrderStore is the storage behind the /workorders routes. A store that can build kits from their
+// bills of materials implements it alongside InventoryStore and BOMStore.
+type WorkOrderStore interface {
+	// ListWorkOrders returns a page of work orders, newest first, optionally only those with the given
+	// status or SKU.
+	ListWorkOrders(status string, sku int, limit int, offset int) ([]*WorkOrder, error)
+	// GetWorkOrder returns the work order with the given ID and its components, or ErrNotFound.
+	GetWorkOrder(id int) (*WorkOrder, error)
+	// CreateWorkOrder records o for o.Quantity kits of o.SKU at o.LocationID, copying its components from
+	// the SKU's bill of materials, and moves it on to o.Status (draft if empty) in one transaction,
+	// returning the movements that made. It returns ErrNoBOM if the SKU has no bill of materials,
+	// ErrComponentArchived if one of its components is archived, and ErrNotFound or ErrLocationNotFound
+	// for an unknown SKU or location.
+	CreateWorkOrder(o *WorkOrder) ([]*InventoryMovement, error)
+	// UpdateWorkOrderStatus moves the work order with the given ID on to status in one transaction:
+	// releasing reserves the components, completing takes them out of stock and puts the kits in, and
+	// cancelling hands the reserved components back. It returns the movements made, a *StockError if the
+	// components aren't there, or ErrWorkOrderStatus if the work order can't reach status from where it is.
+	UpdateWorkOrderStatus(id int, status string, user string) (*WorkOrder, []*InventoryMovement, error)
+}
+
+// StocktakeStore is the storage behind the /stocktakes routes. A store that can count stock in sessions
+// implements it alongside InventoryStore.
+type StocktakeStore interface {
+	// ListStocktakes returns a page of stocktakes, newest first, optionally only those with the given
+	// status.
+	ListStocktakes(status string, limit int, offset int) ([]*Stocktake, error)
+	// GetStocktake returns the stocktake with the given ID, or ErrNotFound.
+	GetStocktake(id int) (*Stocktake, error)
+	// CreateStocktake opens a stocktake limited to st.LocationID and st.SKUs (0 and empty for all) and
+	// fills in the rest of st. It returns ErrNotFound or ErrLocationNotFound for an unknown SKU or
+	// location.
+	CreateStocktake(st *Stocktake) error
+	// RecordCounts records counts against an open stocktake in one transaction, replacing earlier counts
+	// of the same SKU and location or, if add is set, adding to them. It returns ErrStocktakeClosed once
+	// the stocktake is posted or cancelled and ErrNotInStocktake for a count outside its scope.
+	RecordCounts(id int, counts []*StocktakeCount, add bool) error
+	// Variance returns the counted lines of the stocktake against inventory, or ErrNotFound.
+	Variance(id int) (*VarianceReport, error)
+	// PostStocktake applies the variances of an open stocktake to inventory in one transaction, returning
+	// the movements that made. A variance the negative stock policy refuses returns a *StockError and
+	// nothing is applied.
+	PostStocktake(id int, user string) (*Stocktake, []*InventoryMovement, error)
+	// CancelStocktake closes an open stocktake without changing inventory.
+	CancelStocktake(id int) (*Stocktake, error)
+}
+
+// WebhookStore is the storage behind the /webhooks routes and the delivery log.
+type WebhookStore interface {
+	// ListWebhooks returns every webhook not flagged as deleted.
+	ListWebhooks() ([]*Webhook, error)
+	// GetWebhook returns the webhook with the given ID, or ErrNotFound.
+	GetWebhook(id int) (*Webhook, error)
+	// CreateWebhook inserts the webhook and fills in its WebhookID.
+	CreateWebhook(h *Webhook) error
+	// UpdateWebhook overwrites the webhook with the given ID, or returns ErrNotFound.
+	UpdateWebhook(id int, h *Webhook) error
+	// DeleteWebhook flags the webhook with the given ID as deleted, or returns ErrNotFound.
+	DeleteWebhook(id int) error
+	// RecordDelivery appends one delivery attempt to the log.
+	RecordDelivery(d *WebhookDelivery) error
+	// ListDeliveries returns a page of the delivery log for the given webhook, newest first.
+	ListDeliveries(id int, limit int, offset int) ([]*WebhookDelivery, error)
+}
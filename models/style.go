@@ -0,0 +1,293 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrStyleNotFound is returned when a change names a style that doesn't exist or has been deleted.
+var ErrStyleNotFound = errors.New("style not found")
+
+// The attributes a style's variants differ in, as stored in StyleOption.Attribute
+const (
+	AttributeColor     = "color"
+	AttributeTrimColor = "trimcolor"
+	AttributeSize      = "size"
+)
+
+// Style - the parent of a family of products that only differ in color, trim color and size, e.g. a swing
+// that comes in 3 colors, 2 trims and 2 sizes. Each combination is a variant, an ordinary product with
+// its own SKU and stock whose StyleID points back here.
+type Style struct {
+	StyleID              int        `json:"styleid"`
+	StyleName            string     `json:"stylename"`
+	NotificationQuantity int        `json:"notificationquantity,omitempty"`
+	Price                float32    `json:"price,omitempty"`
+	Dimensions           string     `json:"dimensions,omitempty"`
+	NegativeStock        string     `json:"negativestock,omitempty"`
+	Colors               []string   `json:"colors"`
+	TrimColors           []string   `json:"trimcolors"`
+	Sizes                []string   `json:"sizes"`
+	Deleted              int        `json:"deleted,omitempty"`
+	Variants             []*Product `json:"variants,omitempty"`
+}
+
+// options returns the values of the given attribute, in order.
+func (st *Style) options(attribute string) *[]string {
+	switch attribute {
+	case AttributeColor:
+		return &st.Colors
+	case AttributeTrimColor:
+		return &st.TrimColors
+	}
+	return &st.Sizes
+}
+
+// VariantName is the product name given to the style's variant in the given color, trim color and size,
+// e.g. "Swing (Red, Black, Large)".
+func (st *Style) VariantName(color string, trimColor string, size string) string {
+	var parts []string
+	for _, v := range []string{color, trimColor, size} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	if len(parts) == 0 {
+		return st.StyleName
+	}
+	return st.StyleName + " (" + strings.Join(parts, ", ") + ")"
+}
+
+const styleColumns = "StyleID, StyleName, NotificationQuantity, Price, Dimensions, NegativeStock, Deleted"
+
+// scanStyles reads the style rows and fills in their options.
+func (s *SQLStore) scanStyles(tx *sql.Tx, rows *sql.Rows) ([]*Style, error) {
+	list := make([]*Style, 0)
+	byID := make(map[int]*Style)
+	for rows.Next() {
+		st := &Style{Colors: []string{}, TrimColors: []string{}, Sizes: []string{}}
+		if err := rows.Scan(&st.StyleID, &st.StyleName, &st.NotificationQuantity, &st.Price, &st.Dimensions, &st.NegativeStock, &st.Deleted); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if st.Deleted == 0 {
+			list = append(list, st)
+			byID[st.StyleID] = st
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return list, nil
+	}
+
+	rows, err := s.query(tx, "SELECT StyleID, Attribute, Value FROM StyleOption ORDER BY StyleID, Attribute, Position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var attribute, value string
+		if err := rows.Scan(&id, &attribute, &value); err != nil {
+			return nil, err
+		}
+		if st := byID[id]; st != nil {
+			values := st.options(attribute)
+			*values = append(*values, value)
+		}
+	}
+	return list, rows.Err()
+}
+
+// findStyle returns the style with the given ID and its options, or ErrStyleNotFound.
+func (s *SQLStore) findStyle(tx *sql.Tx, id int) (*Style, error) {
+	rows, err := s.query(tx, "SELECT "+styleColumns+" FROM Style WHERE StyleID = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.scanStyles(tx, rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrStyleNotFound
+	}
+	return list[0], nil
+}
+
+// checkStyle returns ErrStyleNotFound unless id is 0 or a style that exists.
+func (s *SQLStore) checkStyle(tx *sql.Tx, id int) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := s.findStyle(tx, id)
+	return err
+}
+
+// findVariants returns the products that are variants of the style with the given ID, by SKU.
+func (s *SQLStore) findVariants(tx *sql.Tx, id int) ([]*Product, error) {
+	rows, err := s.query(tx, selectProducts+" WHERE P.StyleID = ? ORDER BY P.SKU", now(), id)
+	if err != nil {
+		return nil, err
+	}
+	return scanProducts(rows)
+}
+
+// setStyleOptions replaces the options of the style with the given ID with st's.
+func (s *SQLStore) setStyleOptions(tx *sql.Tx, id int, st *Style) error {
+	if _, err := s.exec(tx, "DELETE FROM StyleOption WHERE StyleID = ?", id); err != nil {
+		return err
+	}
+	for _, attribute := range []string{AttributeColor, AttributeTrimColor, AttributeSize} {
+		for i, value := range *st.options(attribute) {
+			if _, err := s.exec(tx, "INSERT INTO StyleOption (StyleID, Attribute, Position, Value) VALUES(?,?,?,?)", id, attribute, i, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListStyles returns every style not flagged as deleted, without its variants.
+func (s *SQLStore) ListStyles() (list []*Style, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, "SELECT "+styleColumns+" FROM Style ORDER BY StyleName, StyleID")
+		if err != nil {
+			return err
+		}
+		list, err = s.scanStyles(tx, rows)
+		return err
+	})
+	return list, err
+}
+
+// GetStyle returns the style with the given ID and its variants.
+func (s *SQLStore) GetStyle(id int) (st *Style, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		st, err = s.findStyle(tx, id)
+		if err != nil {
+			return err
+		}
+		st.Variants, err = s.findVariants(tx, id)
+		return err
+	})
+	return st, err
+}
+
+// CreateStyle inserts the style and its options and fills in its StyleID.
+func (s *SQLStore) CreateStyle(st *Style) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		id, err := s.insert(tx, "INSERT INTO Style (StyleName, NotificationQuantity, Price, Dimensions, NegativeStock) VALUES(?,?,?,?,?)", "StyleID", st.StyleName, st.NotificationQuantity, st.Price, st.Dimensions, st.NegativeStock)
+		if err != nil {
+			return err
+		}
+		st.StyleID = int(id)
+		return s.setStyleOptions(tx, st.StyleID, st)
+	})
+}
+
+// UpdateStyle overwrites the style with the given ID and its options. Its variants are left as they are,
+// a value taken off the style keeps its products.
+func (s *SQLStore) UpdateStyle(id int, st *Style) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.findStyle(tx, id); err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, "UPDATE Style SET StyleName = ?, NotificationQuantity = ?, Price = ?, Dimensions = ?, NegativeStock = ? WHERE StyleID = ?", st.StyleName, st.NotificationQuantity, st.Price, st.Dimensions, st.NegativeStock, id); err != nil {
+			return err
+		}
+		st.StyleID = id
+		return s.setStyleOptions(tx, id, st)
+	})
+}
+
+// DeleteStyle flags the style with the given ID as deleted. A style that still has variants can't be
+// deleted, archive them first.
+func (s *SQLStore) DeleteStyle(id int) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.findStyle(tx, id); err != nil {
+			return err
+		}
+		variants, err := s.findVariants(tx, id)
+		if err != nil {
+			return err
+		}
+		if len(variants) > 0 {
+			return ErrInUse
+		}
+		_, err = s.exec(tx, "UPDATE Style SET Deleted = 1 WHERE StyleID = ?", id)
+		return err
+	})
+}
+
+// GenerateVariants creates a product for every combination of the style's colors, trim colors and sizes
+// it doesn't have a variant for yet, copying the style's price, dimensions, notification quantity and
+// negative stock policy. An attribute without values doesn't split the style. The new products get the
+// SKUs following the highest one in use, in the order colors, then trim colors, then sizes.
+func (s *SQLStore) GenerateVariants(id int) (created []*Product, err error) {
+	err = s.withProductTx(func(tx *sql.Tx) error {
+		st, err := s.findStyle(tx, id)
+		if err != nil {
+			return err
+		}
+		variants, err := s.findVariants(tx, id)
+		if err != nil {
+			return err
+		}
+		exists := make(map[[3]string]bool)
+		for _, v := range variants {
+			exists[[3]string{v.Color, v.TrimColor, v.Size}] = true
+		}
+
+		// Archived products keep their SKUs, so they count too
+		var sku sql.NullInt64
+		if err := tx.QueryRow("SELECT MAX(SKU) FROM Product").Scan(&sku); err != nil {
+			return err
+		}
+		next := int(sku.Int64) + 1
+
+		created = make([]*Product, 0)
+		for _, color := range orNone(st.Colors) {
+			for _, trimColor := range orNone(st.TrimColors) {
+				for _, size := range orNone(st.Sizes) {
+					if exists[[3]string{color, trimColor, size}] {
+						continue
+					}
+					p := &Product{
+						ProductName:          st.VariantName(color, trimColor, size),
+						NotificationQuantity: st.NotificationQuantity,
+						Color:                color,
+						TrimColor:            trimColor,
+						Size:                 size,
+						Price:                st.Price,
+						Dimensions:           st.Dimensions,
+						SKU:                  next,
+						NegativeStock:        st.NegativeStock,
+						StyleID:              id,
+					}
+					productID, err := s.insertProduct(tx, p)
+					if err != nil {
+						return err
+					}
+					p.ProductID = int(productID)
+					created = append(created, p)
+					next++
+				}
+			}
+		}
+		return nil
+	})
+	return created, err
+}
+
+// orNone is values, or a single empty value for an attribute the style doesn't vary in.
+func orNone(values []string) []string {
+	if len(values) == 0 {
+		return []string{""}
+	}
+	return values
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Transfer statuses. A transfer is requested, then in-transit once the stock has left the from location,
+// then received once it is in the to location. Only a requested transfer can be cancelled, nothing has
+// moved yet.
+const (
+	TransferRequested = "requested"
+	TransferInTransit = "in-transit"
+	TransferReceived  = "received"
+	TransferCancelled = "cancelled"
+)
+
+// ErrTransferStatus is returned when a transfer is asked to move to a status it can't reach from the one
+// it is in, e.g. receiving it twice.
+var ErrTransferStatus = errors.New("invalid transfer status change")
+
+// Transfer - a quantity of one SKU on its way from one location to another. While it is in-transit the
+// stock is in neither location, the transfer is what accounts for it.
+type Transfer struct {
+	TransferID   int    `json:"transferid"`
+	SKU          int    `json:"sku"`
+	From         int    `json:"from"`
+	To           int    `json:"to"`
+	Quantity     int    `json:"quantity"`
+	Status       string `json:"status"`
+	Note         string `json:"note,omitempty"`
+	User         string `json:"user,omitempty"`
+	DateCreated  string `json:"datecreated"`
+	DateShipped  string `json:"dateshipped,omitempty"`
+	DateReceived string `json:"datereceived,omitempty"`
+}
+
+// ValidTransferStatus reports whether status is one of the transfer statuses.
+func ValidTransferStatus(status string) bool {
+	switch status {
+	case TransferRequested, TransferInTransit, TransferReceived, TransferCancelled:
+		return true
+	}
+	return false
+}
+
+const selectTransfers = "SELECT T.TransferID, P.SKU, T.FromLocationID, T.ToLocationID, T.Quantity, T.Status, T.Note, T.UserName, T.DateCreated, T.DateShipped, T.DateReceived FROM Transfer T INNER JOIN Product P ON P.ProductID = T.ProductID"
+
+func scanTransfers(rows *sql.Rows) ([]*Transfer, error) {
+	defer rows.Close()
+	transfers := make([]*Transfer, 0)
+	for rows.Next() {
+		t := new(Transfer)
+		var shipped, received sql.NullString
+		if err := rows.Scan(&t.TransferID, &t.SKU, &t.From, &t.To, &t.Quantity, &t.Status, &t.Note, &t.User, &t.DateCreated, &shipped, &received); err != nil {
+			return nil, err
+		}
+		t.DateShipped, t.DateReceived = shipped.String, received.String
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+// findTransfer returns the transfer with the given ID, or ErrNotFound.
+func (s *SQLStore) findTransfer(tx *sql.Tx, id int) (*Transfer, error) {
+	return s.scanTransfer(tx, selectTransfers+" WHERE T.TransferID = ?", id)
+}
+
+// lockTransfer is findTransfer for a transaction about to change the transfer. It stays locked until
+// the transaction ends so it can't be shipped or received twice at once.
+func (s *SQLStore) lockTransfer(tx *sql.Tx, id int) (*Transfer, error) {
+	return s.scanTransfer(tx, selectTransfers+" WHERE T.TransferID = ?"+s.Dialect.forUpdate(), id)
+}
+
+func (s *SQLStore) scanTransfer(tx *sql.Tx, query string, id int) (*Transfer, error) {
+	rows, err := s.query(tx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := scanTransfers(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(transfers) == 0 {
+		return nil, ErrNotFound
+	}
+	return transfers[0], nil
+}
+
+// advanceTransfer moves t on to status, taking the stock out of the from location when it ships and
+// putting it into the to location when it is received. Going straight from requested to received does
+// both. The movements are returned in the order they were made.
+func (s *SQLStore) advanceTransfer(tx *sql.Tx, t *Transfer, status string, user string) ([]*InventoryMovement, error) {
+	moves := make([]*InventoryMovement, 0)
+	switch {
+	case status == TransferCancelled && t.Status == TransferRequested:
+		_, err := s.exec(tx, "UPDATE Transfer SET Status = ? WHERE TransferID = ?", status, t.TransferID)
+		t.Status = status
+		return moves, err
+	case status == TransferInTransit && t.Status == TransferRequested,
+		status == TransferReceived && (t.Status == TransferRequested || t.Status == TransferInTransit):
+	default:
+		return nil, ErrTransferStatus
+	}
+	note := "transfer " + strconv.Itoa(t.TransferID)
+
+	if t.Status == TransferRequested {
+		m := &InventoryMovement{Reason: ReasonTransfer, Note: note, User: user}
+		if err := s.adjustInventory(tx, t.SKU, t.From, -t.Quantity, m); err != nil {
+			return nil, err
+		}
+		// A transfer ships what was asked for or nothing, so clamp refuses like reject does
+		if -m.Delta < t.Quantity {
+			return nil, &StockError{SKU: t.SKU, LocationID: t.From, Quantity: -m.Delta, Requested: -t.Quantity}
+		}
+		now := time.Now()
+		t.Status, t.DateShipped = TransferInTransit, now.Format(time.RFC3339)
+		_, err := s.exec(tx, "UPDATE Transfer SET Status = ?, DateShipped = ? WHERE TransferID = ?", t.Status, now, t.TransferID)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, m)
+	}
+
+	if status == TransferReceived {
+		m := &InventoryMovement{Reason: ReasonTransfer, Note: note, User: user}
+		if err := s.adjustInventory(tx, t.SKU, t.To, t.Quantity, m); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		t.Status, t.DateReceived = TransferReceived, now.Format(time.RFC3339)
+		_, err := s.exec(tx, "UPDATE Transfer SET Status = ?, DateReceived = ? WHERE TransferID = ?", t.Status, now, t.TransferID)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+// ListTransfers returns a page of transfers, newest first. An empty status or a sku of 0 matches every
+// transfer.
+func (s *SQLStore) ListTransfers(status string, sku int, limit int, offset int) (transfers []*Transfer, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		query, args := selectTransfers+" WHERE 1 = 1", []interface{}{}
+		if status != "" {
+			query, args = query+" AND T.Status = ?", append(args, status)
+		}
+		if sku != 0 {
+			query, args = query+" AND P.SKU = ?", append(args, sku)
+		}
+		rows, err := s.query(tx, query+" ORDER BY T.TransferID DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+		if err != nil {
+			return err
+		}
+		transfers, err = scanTransfers(rows)
+		return err
+	})
+	return transfers, err
+}
+
+// GetTransfer returns the transfer with the given ID.
+func (s *SQLStore) GetTransfer(id int) (t *Transfer, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		t, err = s.findTransfer(tx, id)
+		return err
+	})
+	return t, err
+}
+
+// InTransit returns how much of the given SKU is on its way between locations.
+func (s *SQLStore) InTransit(sku int) (quantity int, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		var total sql.NullInt64
+		err := tx.QueryRow(s.Dialect.Rebind("SELECT SUM(T.Quantity) FROM Transfer T INNER JOIN Product P ON P.ProductID = T.ProductID WHERE T.Status = ? AND P.SKU = ?"), TransferInTransit, sku).Scan(&total)
+		quantity = int(total.Int64)
+		return err
+	})
+	return quantity, err
+}
+
+// CreateTransfer records t as requested and then, in the same transaction, moves it on to t.Status.
+func (s *SQLStore) CreateTransfer(t *Transfer) (moves []*InventoryMovement, err error) {
+	status := t.Status
+	if status == "" {
+		status = TransferRequested
+	}
+	err = s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, t.SKU)
+		if err != nil {
+			return err
+		}
+		for _, id := range []int{t.From, t.To} {
+			if _, err := s.findLocation(tx, id); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		id, err := s.insert(tx, "INSERT INTO Transfer (ProductID, FromLocationID, ToLocationID, Quantity, Status, Note, UserName, DateCreated) VALUES(?,?,?,?,?,?,?,?)", "TransferID", p.ProductID, t.From, t.To, t.Quantity, TransferRequested, t.Note, t.User, now)
+		if err != nil {
+			return err
+		}
+		t.TransferID, t.Status, t.DateCreated = int(id), TransferRequested, now.Format(time.RFC3339)
+		if status == TransferRequested {
+			moves = make([]*InventoryMovement, 0)
+			return nil
+		}
+		moves, err = s.advanceTransfer(tx, t, status, t.User)
+		return err
+	})
+	return moves, err
+}
+
+// UpdateTransferStatus moves the transfer with the given ID on to status.
+func (s *SQLStore) UpdateTransferStatus(id int, status string, user string) (t *Transfer, moves []*InventoryMovement, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		t, err = s.lockTransfer(tx, id)
+		if err != nil {
+			return err
+		}
+		moves, err = s.advanceTransfer(tx, t, status, user)
+		return err
+	})
+	return t, moves, err
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Webhook - a URL that is sent signed JSON payloads when products or inventory change
+type Webhook struct {
+	WebhookID   int      `json:"webhookid,omitempty"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	Events      []string `json:"events"` // empty for every event
+	Active      bool     `json:"active"`
+	DateCreated string   `json:"datecreated,omitempty"`
+}
+
+// Wants reports whether the webhook is subscribed to event.
+func (h *Webhook) Wants(event string) bool {
+	if !h.Active {
+		return false
+	}
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery - one attempt at delivering an event to a webhook. Retries of the same event share
+// its EventID.
+type WebhookDelivery struct {
+	DeliveryID  int    `json:"deliveryid"`
+	WebhookID   int    `json:"webhookid"`
+	EventID     string `json:"eventid"`
+	Event       string `json:"event"`
+	Payload     string `json:"payload"`
+	Attempt     int    `json:"attempt"`
+	StatusCode  int    `json:"statuscode,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DateCreated string `json:"datecreated"`
+}
+
+const webhookColumns = "WebhookID, URL, Secret, Events, Active, DateCreated"
+
+func scanWebhooks(rows *sql.Rows) ([]*Webhook, error) {
+	defer rows.Close()
+	hooks := make([]*Webhook, 0)
+	for rows.Next() {
+		h := new(Webhook)
+		var events string
+		var active int
+		if err := rows.Scan(&h.WebhookID, &h.URL, &h.Secret, &events, &active, &h.DateCreated); err != nil {
+			return nil, err
+		}
+		h.Events = splitEvents(events)
+		h.Active = active == 1
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+func splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ListWebhooks returns every webhook not flagged as deleted.
+func (s *SQLStore) ListWebhooks() (hooks []*Webhook, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, "SELECT "+webhookColumns+" FROM Webhook WHERE Deleted = 0 ORDER BY WebhookID")
+		if err != nil {
+			return err
+		}
+		hooks, err = scanWebhooks(rows)
+		return err
+	})
+	return hooks, err
+}
+
+// GetWebhook returns the webhook with the given ID.
+func (s *SQLStore) GetWebhook(id int) (h *Webhook, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, "SELECT "+webhookColumns+" FROM Webhook WHERE WebhookID = ? AND Deleted = 0", id)
+		if err != nil {
+			return err
+		}
+		hooks, err := scanWebhooks(rows)
+		if err != nil {
+			return err
+		}
+		if len(hooks) == 0 {
+			return ErrNotFound
+		}
+		h = hooks[0]
+		return nil
+	})
+	return h, err
+}
+
+// CreateWebhook inserts a new webhook and fills in its WebhookID.
+func (s *SQLStore) CreateWebhook(h *Webhook) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		now := time.Now()
+		id, err := s.insert(tx, "INSERT INTO Webhook (URL, Secret, Events, Active, Deleted, DateCreated) VALUES(?,?,?,?,?,?)", "WebhookID", h.URL, h.Secret, strings.Join(h.Events, ","), boolInt(h.Active), 0, now)
+		if err != nil {
+			return err
+		}
+		h.WebhookID, h.DateCreated = int(id), now.Format(time.RFC3339)
+		return nil
+	})
+}
+
+// UpdateWebhook overwrites the URL, secret, events and active flag of the webhook with the given ID.
+func (s *SQLStore) UpdateWebhook(id int, h *Webhook) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := s.checkWebhook(tx, id); err != nil {
+			return err
+		}
+		_, err := s.exec(tx, "UPDATE Webhook SET URL = ?, Secret = ?, Events = ?, Active = ? WHERE WebhookID = ?", h.URL, h.Secret, strings.Join(h.Events, ","), boolInt(h.Active), id)
+		return err
+	})
+}
+
+// DeleteWebhook flags the webhook with the given ID as deleted, its delivery log is kept.
+func (s *SQLStore) DeleteWebhook(id int) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := s.checkWebhook(tx, id); err != nil {
+			return err
+		}
+		_, err := s.exec(tx, "UPDATE Webhook SET Deleted = 1, Active = 0 WHERE WebhookID = ?", id)
+		return err
+	})
+}
+
+// checkWebhook returns ErrNotFound unless the webhook with the given ID exists and isn't deleted. MySQL
+// reports an UPDATE that changes nothing as affecting no rows, so that can't tell.
+func (s *SQLStore) checkWebhook(tx *sql.Tx, id int) error {
+	err := tx.QueryRow(s.Dialect.Rebind("SELECT WebhookID FROM Webhook WHERE WebhookID = ? AND Deleted = 0"+s.Dialect.forUpdate()), id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// RecordDelivery appends an attempt to the delivery log and fills in its DeliveryID.
+func (s *SQLStore) RecordDelivery(d *WebhookDelivery) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		now := time.Now()
+		id, err := s.insert(tx, "INSERT INTO WebhookDelivery (WebhookID, EventID, Event, Payload, Attempt, StatusCode, ErrorMessage, DateCreated) VALUES(?,?,?,?,?,?,?,?)", "DeliveryID", d.WebhookID, d.EventID, d.Event, d.Payload, d.Attempt, d.StatusCode, d.Error, now)
+		if err != nil {
+			return err
+		}
+		d.DeliveryID, d.DateCreated = int(id), now.Format(time.RFC3339)
+		return nil
+	})
+}
+
+// ListDeliveries returns a page of the delivery log for the given webhook, newest first.
+func (s *SQLStore) ListDeliveries(id int, limit int, offset int) (deliveries []*WebhookDelivery, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		rows, err := s.query(tx, "SELECT DeliveryID, WebhookID, EventID, Event, Payload, Attempt, StatusCode, ErrorMessage, DateCreated FROM WebhookDelivery WHERE WebhookID = ? ORDER BY DeliveryID DESC LIMIT ? OFFSET ?", id, limit, offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		deliveries = make([]*WebhookDelivery, 0)
+		for rows.Next() {
+			d := new(WebhookDelivery)
+			if err := rows.Scan(&d.DeliveryID, &d.WebhookID, &d.EventID, &d.Event, &d.Payload, &d.Attempt, &d.StatusCode, &d.Error, &d.DateCreated); err != nil {
+				return err
+			}
+			deliveries = append(deliveries, d)
+		}
+		return rows.Err()
+	})
+	return deliveries, err
+}
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Work order statuses. A work order is created as a draft, released once its components have been
+// reserved for it, then completed when the kits have been built. A draft or released work order can be
+// cancelled, which hands any reserved components back.
+const (
+	WorkOrderDraft     = "draft"
+	WorkOrderReleased  = "released"
+	WorkOrderCompleted = "completed"
+	WorkOrderCancelled = "cancelled"
+)
+
+// ErrWorkOrderStatus is returned when a work order is asked to move to a status it can't reach from the
+// one it is in, e.g. completing it twice.
+var ErrWorkOrderStatus = errors.New("invalid work order status change")
+
+// ErrNoBOM is returned when creating a work order for a product without a bill of materials.
+var ErrNoBOM = errors.New("product has no bill of materials")
+
+// ErrComponentArchived is returned when creating a work order for a kit with an archived component.
+var ErrComponentArchived = errors.New("a component of the kit is archived")
+
+// The reservations a work order makes are closed by the work order, not by time.
+var workOrderExpiry = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// WorkOrder - an order to build Quantity kits of SKU at one location from the components in its bill of
+// materials.
+type WorkOrder struct {
+	WorkOrderID   int                   `json:"workorderid"`
+	SKU           int                   `json:"sku"`
+	LocationID    int                   `json:"locationid"`
+	Quantity      int                   `json:"quantity"`
+	Status        string                `json:"status"`
+	Note          string                `json:"note,omitempty"`
+	User          string                `json:"user,omitempty"`
+	Components    []*WorkOrderComponent `json:"components"`
+	DateCreated   string                `json:"datecreated"`
+	DateReleased  string                `json:"datereleased,omitempty"`
+	DateCompleted string                `json:"datecompleted,omitempty"`
+}
+
+// WorkOrderComponent - the quantity of one component the whole work order takes, and the reservation
+// holding it once the work order is released.
+type WorkOrderComponent struct {
+	SKU           int `json:"sku"`
+	Quantity      int `json:"quantity"`
+	ReservationID int `json:"reservationid,omitempty"`
+	productID     int
+}
+
+// ValidWorkOrderStatus reports whether status is one of the work order statuses.
+func ValidWorkOrderStatus(status string) bool {
+	switch status {
+	case WorkOrderDraft, WorkOrderReleased, WorkOrderCompleted, WorkOrderCancelled:
+		return true
+	}
+	return false
+}
+
+const selectWorkOrders = "SELECT W.WorkOrderID, P.SKU, W.LocationID, W.Quantity, W.Status, W.Note, W.UserName, W.DateCreated, W.DateReleased, W.DateCompleted FROM WorkOrder W INNER JOIN Product P ON P.ProductID = W.ProductID"
+
+func scanWorkOrders(rows *sql.Rows) ([]*WorkOrder, error) {
+	defer rows.Close()
+	orders := make([]*WorkOrder, 0)
+	for rows.Next() {
+		o := &WorkOrder{Components: make([]*WorkOrderComponent, 0)}
+		var released, completed sql.NullString
+		if err := rows.Scan(&o.WorkOrderID, &o.SKU, &o.LocationID, &o.Quantity, &o.Status, &o.Note, &o.User, &o.DateCreated, &released, &completed); err != nil {
+			return nil, err
+		}
+		o.DateReleased, o.DateCompleted = released.String, completed.String
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// findWorkOrder returns the work order with the given ID and its components, locking it if the dialect
+// can so it can't be released or completed twice at once.
+func (s *SQLStore) findWorkOrder(tx *sql.Tx, id int, lock bool) (*WorkOrder, error) {
+	query := selectWorkOrders + " WHERE W.WorkOrderID = ?"
+	if lock {
+		query += s.Dialect.forUpdate()
+	}
+	rows, err := s.query(tx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := scanWorkOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, ErrNotFound
+	}
+	o := orders[0]
+
+	rows, err = s.query(tx, "SELECT P.SKU, C.ProductID, C.Quantity, C.ReservationID FROM WorkOrderComponent C INNER JOIN Product P ON P.ProductID = C.ProductID WHERE C.WorkOrderID = ? ORDER BY P.SKU", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := new(WorkOrderComponent)
+		var reservation sql.NullInt64
+		if err := rows.Scan(&c.SKU, &c.productID, &c.Quantity, &reservation); err != nil {
+			return nil, err
+		}
+		c.ReservationID = int(reservation.Int64)
+		o.Components = append(o.Components, c)
+	}
+	return o, rows.Err()
+}
+
+// releaseWorkOrder reserves every component of a draft work order at its location.
+func (s *SQLStore) releaseWorkOrder(tx *sql.Tx, o *WorkOrder, user string) error {
+	for _, c := range o.Components {
+		r := &Reservation{SKU: c.SKU, LocationID: o.LocationID, Quantity: c.Quantity, Reference: "work order " + strconv.Itoa(o.WorkOrderID), User: user}
+		if err := s.reserve(tx, r, workOrderExpiry); err != nil {
+			return err
+		}
+		c.ReservationID = r.ReservationID
+		if _, err := s.exec(tx, "UPDATE WorkOrderComponent SET ReservationID = ? WHERE WorkOrderID = ? AND ProductID = ?", c.ReservationID, o.WorkOrderID, c.productID); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	o.Status, o.DateReleased = WorkOrderReleased, now.Format(time.RFC3339)
+	_, err := s.exec(tx, "UPDATE WorkOrder SET Status = ?, DateReleased = ? WHERE WorkOrderID = ?", o.Status, now, o.WorkOrderID)
+	return err
+}
+
+// closeWorkOrderReservations closes the reservations of a released work order that are still active,
+// marking them consumed or released.
+func (s *SQLStore) closeWorkOrderReservations(tx *sql.Tx, o *WorkOrder, status string) error {
+	for _, c := range o.Components {
+		if c.ReservationID == 0 {
+			continue
+		}
+		r, err := s.findReservation(tx, c.ReservationID, true)
+		if err != nil {
+			return err
+		}
+		// someone may have released it by hand, the work order goes ahead regardless
+		if r.Status != ReservationActive {
+			continue
+		}
+		if err := s.closeReservation(tx, r, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advanceWorkOrder moves o on to status. Completing takes every component out of stock and puts the
+// kits in, releasing the work order first if it hasn't been. The movements are returned in the order
+// they were made, the components in SKU order and then the kits.
+func (s *SQLStore) advanceWorkOrder(tx *sql.Tx, o *WorkOrder, status string, user string) ([]*InventoryMovement, error) {
+	moves := make([]*InventoryMovement, 0)
+	switch {
+	case status == WorkOrderReleased && o.Status == WorkOrderDraft:
+		return moves, s.releaseWorkOrder(tx, o, user)
+	case status == WorkOrderCancelled && (o.Status == WorkOrderDraft || o.Status == WorkOrderReleased):
+		if err := s.closeWorkOrderReservations(tx, o, ReservationReleased); err != nil {
+			return nil, err
+		}
+		o.Status = WorkOrderCancelled
+		_, err := s.exec(tx, "UPDATE WorkOrder SET Status = ? WHERE WorkOrderID = ?", o.Status, o.WorkOrderID)
+		return moves, err
+	case status == WorkOrderCompleted && (o.Status == WorkOrderDraft || o.Status == WorkOrderReleased):
+	default:
+		return nil, ErrWorkOrderStatus
+	}
+
+	if o.Status == WorkOrderDraft {
+		if err := s.releaseWorkOrder(tx, o, user); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.closeWorkOrderReservations(tx, o, ReservationConsumed); err != nil {
+		return nil, err
+	}
+	note := "work order " + strconv.Itoa(o.WorkOrderID)
+	for _, c := range o.Components {
+		m := &InventoryMovement{Reason: ReasonAssembly, Note: note, User: user}
+		if err := s.adjustInventory(tx, c.SKU, o.LocationID, -c.Quantity, m); err != nil {
+			return nil, err
+		}
+		// A kit can't be built from part of a component, so clamp refuses like reject does
+		if -m.Delta < c.Quantity {
+			return nil, &StockError{SKU: c.SKU, LocationID: o.LocationID, Quantity: -m.Delta, Requested: -c.Quantity}
+		}
+		moves = append(moves, m)
+	}
+	m := &InventoryMovement{Reason: ReasonAssembly, Note: note, User: user}
+	if err := s.adjustInventory(tx, o.SKU, o.LocationID, o.Quantity, m); err != nil {
+		return nil, err
+	}
+	moves = append(moves, m)
+
+	now := time.Now()
+	o.Status, o.DateCompleted = WorkOrderCompleted, now.Format(time.RFC3339)
+	_, err := s.exec(tx, "UPDATE WorkOrder SET Status = ?, DateCompleted = ? WHERE WorkOrderID = ?", o.Status, now, o.WorkOrderID)
+	return moves, err
+}
+
+// ListWorkOrders returns a page of work orders, newest first, without their components. An empty
+// status or a sku of 0 matches every work order.
+func (s *SQLStore) ListWorkOrders(status string, sku int, limit int, offset int) (orders []*WorkOrder, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		query, args := selectWorkOrders+" WHERE 1 = 1", []interface{}{}
+		if status != "" {
+			query, args = query+" AND W.Status = ?", append(args, status)
+		}
+		if sku != 0 {
+			query, args = query+" AND P.SKU = ?", append(args, sku)
+		}
+		rows, err := s.query(tx, query+" ORDER BY W.WorkOrderID DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+		if err != nil {
+			return err
+		}
+		orders, err = scanWorkOrders(rows)
+		return err
+	})
+	return orders, err
+}
+
+// GetWorkOrder returns the work order with the given ID.
+func (s *SQLStore) GetWorkOrder(id int) (o *WorkOrder, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		o, err = s.findWorkOrder(tx, id, false)
+		return err
+	})
+	return o, err
+}
+
+// CreateWorkOrder records o as a draft with the components of its product's bill of materials, scaled
+// up to o.Quantity kits, and then, in the same transaction, moves it on to o.Status.
+func (s *SQLStore) CreateWorkOrder(o *WorkOrder) (moves []*InventoryMovement, err error) {
+	status := o.Status
+	if status == "" {
+		status = WorkOrderDraft
+	}
+	err = s.withTx(func(tx *sql.Tx) error {
+		p, err := s.findProduct(tx, o.SKU)
+		if err != nil {
+			return err
+		}
+		if _, err := s.findLocation(tx, o.LocationID); err != nil {
+			return err
+		}
+		bom, err := s.findBOM(tx, p)
+		if err != nil {
+			return err
+		}
+		if len(bom.Components) == 0 {
+			return ErrNoBOM
+		}
+		for _, c := range bom.Components {
+			if c.Archived {
+				return ErrComponentArchived
+			}
+		}
+
+		now := time.Now()
+		id, err := s.insert(tx, "INSERT INTO WorkOrder (ProductID, LocationID, Quantity, Status, Note, UserName, DateCreated) VALUES(?,?,?,?,?,?,?)", "WorkOrderID", p.ProductID, o.LocationID, o.Quantity, WorkOrderDraft, o.Note, o.User, now)
+		if err != nil {
+			return err
+		}
+		o.WorkOrderID, o.Status, o.DateCreated = int(id), WorkOrderDraft, now.Format(time.RFC3339)
+		o.Components = make([]*WorkOrderComponent, 0, len(bom.Components))
+		for _, b := range bom.Components {
+			c := &WorkOrderComponent{SKU: b.SKU, Quantity: b.Quantity * o.Quantity, productID: b.productID}
+			if _, err := s.exec(tx, "INSERT INTO WorkOrderComponent (WorkOrderID, ProductID, Quantity) VALUES(?,?,?)", id, c.productID, c.Quantity); err != nil {
+				return err
+			}
+			o.Components = append(o.Components, c)
+		}
+		if status == WorkOrderDraft {
+			moves = make([]*InventoryMovement, 0)
+			return nil
+		}
+		moves, err = s.advanceWorkOrder(tx, o, status, o.User)
+		return err
+	})
+	return moves, err
+}
+
+// UpdateWorkOrderStatus moves the work order with the given ID on to status.
+func (s *SQLStore) UpdateWorkOrderStatus(id int, status string, user string) (o *WorkOrder, moves []*InventoryMovement, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		o, err = s.findWorkOrder(tx, id, true)
+		if err != nil {
+			return err
+		}
+		moves, err = s.advanceWorkOrder(tx, o, status, user)
+		return err
+	})
+	return o, moves, err
+}
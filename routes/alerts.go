@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	// "github.com/Xero67/web-fire-family/alerts"
+	// "github.com/Xero67/web-fire-family/models"
+	"../alerts"
+	"../models"
+)
+
+// Returns every product at or below its notification quantity in JSON format
+func getLowStockAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	prods, err := products.ListLowStock()
+	if err != nil {
+		fmt.Println("alerts.go - getLowStockAlerts - ListLowStock error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load low stock products"))
+		return
+	}
+	json.NewEncoder(w).Encode(prods)
+}
+
+// checkLowStock sends an alert if the change m crossed its product's notification quantity on the way
+// down. Scanning out the rest of a low product doesn't send one per scan. Transfers only move stock
+// between locations, the part in transit is still the product's.
+func checkLowStock(m *models.InventoryMovement) {
+	if m.Delta >= 0 || m.Reason == models.ReasonTransfer {
+		return
+	}
+	p, err := products.GetProduct(m.SKU)
+	if err != nil {
+		fmt.Println("alerts.go - checkLowStock - error selecting product sku: " + strconv.Itoa(m.SKU))
+		fmt.Println(err)
+		return
+	}
+	// m.Quantity is what is left at one location, the threshold is for the total on hand as the change
+	// left it
+	if !alerts.Crossed(p.NotificationQuantity, m.OnHand-m.Delta, m.OnHand) {
+		return
+	}
+
+	a := alerts.LowStock{SKU: p.SKU, ProductName: p.ProductName, Quantity: m.OnHand, Threshold: p.NotificationQuantity, DateCreated: time.Now().Format(time.RFC3339)}
+	n := notifier
+	// Webhooks and mail can be slow, don't keep the scanner waiting on them
+	go func() {
+		if err := n.Notify(a); err != nil {
+			fmt.Println("alerts.go - checkLowStock - notify error for sku: " + strconv.Itoa(a.SKU))
+			fmt.Println(err)
+		}
+	}()
+}
@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/mux"
+)
+
+// Returns the barcodes linked to the product with the given SKU in JSON format
+func getProductBarcodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	params := mux.Vars(r)
+	sku := params["sku"]
+
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+
+	codes, err := products.ListBarcodes(productSKU)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("barcode.go - getProductBarcodes - ListBarcodes error for sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load barcodes"))
+		return
+	}
+	json.NewEncoder(w).Encode(codes)
+}
+
+// Returns the barcode matching the given code, including the SKU it belongs to
+func getBarcode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	code := mux.Vars(r)["code"]
+
+	b, err := products.FindBarcode(code)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Barcode not found"))
+		return
+	}
+	json.NewEncoder(w).Encode(b)
+}
+
+// Links a barcode to a product from the passed in JSON {"code", "symbology", "sku"}. The symbology is
+// worked out from the code when it is left out, and the check digit is always verified.
+func createBarcode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	var b models.Barcode
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+	b.Code = strings.TrimSpace(b.Code)
+	if b.SKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+	detected := b.Symbology == ""
+	if detected {
+		b.Symbology = models.DetectSymbology(b.Code)
+	}
+	if err := models.ValidateBarcode(b.Symbology, b.Code); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if detected && b.Symbology != models.Code128 {
+			w.Write([]byte("400 - " + err.Error() + `, send "symbology":"code128" if it isn't a UPC or EAN`))
+			return
+		}
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+
+	err := products.AddBarcode(b.SKU, &b)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(b)
+	case models.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+	case models.ErrExists:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Barcode is already linked to a product, archived ones included"))
+	default:
+		fmt.Println("barcode.go - createBarcode - AddBarcode error for code: " + b.Code)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save barcode"))
+	}
+}
+
+// Unlinks the given barcode from its product
+func deleteBarcode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	code := mux.Vars(r)["code"]
+
+	if err := products.DeleteBarcode(code); err != nil {
+		fmt.Println("barcode.go - deleteBarcode - DeleteBarcode error for code: " + code)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Barcode not found"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"deleted": "true"}`))
+}
+
+// Increases the quantity of the product with the scanned barcode by one
+func scanIncrement(w http.ResponseWriter, r *http.Request) {
+	scanStep(w, r, 1, models.ReasonReceive)
+}
+
+// Decreases the quantity of the product with the scanned barcode by one
+func scanDecrement(w http.ResponseWriter, r *http.Request) {
+	scanStep(w, r, -1, models.ReasonSale)
+}
+
+// scanStep resolves the scanned code to a SKU and then works exactly like increment and decrement
+func scanStep(w http.ResponseWriter, r *http.Request, delta int, reason string) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	code := mux.Vars(r)["code"]
+
+	sku, err := resolveCode(code)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - " + err.Error()))
+		return
+	}
+	stepInventory(w, r, sku, delta, reason)
+}
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/mux"
+)
+
+// bomsEnabled writes a 404 when the product store can't keep bills of materials
+func bomsEnabled(w http.ResponseWriter) bool {
+	if boms == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Bills of materials are not enabled"))
+		return false
+	}
+	return true
+}
+
+// Returns the bill of materials of a product: its components, how many of each go into one kit, the
+// stock of each and how many kits could be built from it.
+func getProductBOM(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !bomsEnabled(w) {
+		return
+	}
+	sku := mux.Vars(r)["sku"]
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+
+	bom, err := boms.GetBOM(productSKU)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("bom.go - getProductBOM - GetBOM error for sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load bill of materials"))
+		return
+	}
+	json.NewEncoder(w).Encode(bom)
+}
+
+// Replaces the bill of materials of a product from the passed in JSON {"components": [{"sku", "quantity"}]},
+// making it a kit. An empty list of components makes it an ordinary product again.
+func updateProductBOM(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !bomsEnabled(w) {
+		return
+	}
+	sku := mux.Vars(r)["sku"]
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+	var body struct {
+		Components []*models.BOMComponent `json:"components"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+	seen := make(map[int]bool)
+	for _, c := range body.Components {
+		if c == nil || c.SKU < 1 || c.Quantity < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Every component needs a sku and a quantity of at least 1."))
+			return
+		}
+		if seen[c.SKU] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Component " + strconv.Itoa(c.SKU) + " is listed twice."))
+			return
+		}
+		seen[c.SKU] = true
+	}
+
+	bom, err := boms.SetBOM(productSKU, body.Components)
+	switch err {
+	case nil:
+	case models.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	case models.ErrBOMCycle:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - A kit can't be a component of itself."))
+		return
+	default:
+		fmt.Println("bom.go - updateProductBOM - SetBOM error for sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save bill of materials"))
+		return
+	}
+	json.NewEncoder(w).Encode(bom)
+}
@@ -0,0 +1,418 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/mux"
+)
+
+// categoriesEnabled writes a 404 when the product store can't keep categories and tags
+func categoriesEnabled(w http.ResponseWriter) bool {
+	if categories == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Categories and tags are not enabled"))
+		return false
+	}
+	return true
+}
+
+// categoryID reads the {id} route variable, writing a 400 if it isn't a valid ID
+func categoryID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid category ID."))
+		return 0, false
+	}
+	return id, true
+}
+
+// categoryFromRequest decodes and checks the category in the request body
+func categoryFromRequest(r *http.Request) (*models.Category, error) {
+	c := new(models.Category)
+	if err := json.NewDecoder(r.Body).Decode(c); err != nil {
+		return nil, fmt.Errorf("Invalid JSON body.")
+	}
+	c.CategoryName = strings.TrimSpace(c.CategoryName)
+	if c.CategoryName == "" {
+		return nil, fmt.Errorf("Please include a categoryname.")
+	}
+	if c.ParentID < 0 {
+		return nil, fmt.Errorf("Invalid parentid.")
+	}
+	return c, nil
+}
+
+// writeCategoryResult writes the response for a failed category change, reporting whether it was saved
+func writeCategoryResult(w http.ResponseWriter, c *models.Category, err error, caller string) bool {
+	switch err {
+	case nil:
+		return true
+	case models.ErrCategoryNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Category not found"))
+	case models.ErrCategoryCycle:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - A category can't be inside itself."))
+	case models.ErrExists:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Category " + c.CategoryName + " already exists there"))
+	default:
+		fmt.Println("category.go - " + caller + " error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save category"))
+	}
+	return false
+}
+
+// Returns every category with its parent and path in JSON format, ordered by path
+func getCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	list, err := categories.ListCategories()
+	if err != nil {
+		fmt.Println("category.go - getCategories - ListCategories error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load categories"))
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// Returns a specific category in JSON format
+func getCategoryByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	id, ok := categoryID(w, r)
+	if !ok {
+		return
+	}
+	c, err := categories.GetCategory(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Category not found"))
+		return
+	}
+	json.NewEncoder(w).Encode(c)
+}
+
+// Adds a category from the passed in JSON {"categoryname", "parentid"} and returns it with its new ID.
+// Leave parentid out for a top level category.
+func createCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	c, err := categoryFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+	if !writeCategoryResult(w, c, categories.CreateCategory(c), "createCategory - CreateCategory") {
+		return
+	}
+	json.NewEncoder(w).Encode(c)
+}
+
+// Renames the category with the given ID and moves it under parentid. Its subcategories and products go
+// with it.
+func updateCategoryByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	id, ok := categoryID(w, r)
+	if !ok {
+		return
+	}
+	c, err := categoryFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+	if !writeCategoryResult(w, c, categories.UpdateCategory(id, c), "updateCategoryByID - UpdateCategory") {
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Removes the category with the given ID, its products stay but aren't in it anymore. A category with
+// subcategories can't go.
+func deleteCategoryByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	id, ok := categoryID(w, r)
+	if !ok {
+		return
+	}
+
+	err := categories.DeleteCategory(id)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"deleted": "true"}`))
+	case models.ErrCategoryNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Category not found"))
+	case models.ErrInUse:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Category still has subcategories"))
+	default:
+		fmt.Println("category.go - deleteCategoryByID - DeleteCategory error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to delete category"))
+	}
+}
+
+// Returns the categories a product is in
+func getProductCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	sku := mux.Vars(r)["sku"]
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+
+	cats, err := categories.GetProductCategories(productSKU)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("category.go - getProductCategories - GetProductCategories error for sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load categories"))
+		return
+	}
+	json.NewEncoder(w).Encode(cats)
+}
+
+// Replaces the categories a product is in from the passed in JSON {"categories": [id, ...]}. An empty
+// list takes it out of every category.
+func updateProductCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	sku := mux.Vars(r)["sku"]
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+	var body struct {
+		Categories []int `json:"categories"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+	seen := make(map[int]bool)
+	for _, id := range body.Categories {
+		if id < 1 || seen[id] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Every category has to be a valid ID, listed once."))
+			return
+		}
+		seen[id] = true
+	}
+
+	cats, err := categories.SetProductCategories(productSKU, body.Categories)
+	switch err {
+	case nil:
+	case models.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	case models.ErrCategoryNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Category not found"))
+		return
+	default:
+		fmt.Println("category.go - updateProductCategories - SetProductCategories error for sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save categories"))
+		return
+	}
+	json.NewEncoder(w).Encode(cats)
+}
+
+// Returns every tag in use and how many products carry it in JSON format
+func getTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	tags, err := categories.ListTags()
+	if err != nil {
+		fmt.Println("category.go - getTags - ListTags error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load tags"))
+		return
+	}
+	json.NewEncoder(w).Encode(tags)
+}
+
+// Renames a tag on every product that carries it to the passed in JSON {"tag"}
+func renameTag(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	var body struct {
+		Tag string `json:"tag"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+	to := models.NormalizeTag(body.Tag)
+	if to == "" || len(to) > 64 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - A tag can't be blank or longer than 64 characters."))
+		return
+	}
+
+	err := categories.RenameTag(mux.Vars(r)["tag"], to)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Tag not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("category.go - renameTag - RenameTag error for tag: " + mux.Vars(r)["tag"])
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to rename tag"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Takes a tag off every product that carries it
+func deleteTag(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	err := categories.DeleteTag(mux.Vars(r)["tag"])
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Tag not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("category.go - deleteTag - DeleteTag error for tag: " + mux.Vars(r)["tag"])
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to delete tag"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"deleted": "true"}`))
+}
+
+// Returns the tags of a product
+func getProductTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	sku := mux.Vars(r)["sku"]
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+
+	tags, err := categories.GetProductTags(productSKU)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("category.go - getProductTags - GetProductTags error for sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load tags"))
+		return
+	}
+	json.NewEncoder(w).Encode(tags)
+}
+
+// Replaces the tags of a product from the passed in JSON {"tags": ["outdoor", ...]}. Tags are stored
+// lower case with single spaces, so "Gift Idea" and "gift  idea" are the same tag.
+func updateProductTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !categoriesEnabled(w) {
+		return
+	}
+	sku := mux.Vars(r)["sku"]
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+	for _, tag := range body.Tags {
+		if tag = models.NormalizeTag(tag); tag == "" || len(tag) > 64 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - A tag can't be blank or longer than 64 characters."))
+			return
+		}
+	}
+
+	tags, err := categories.SetProductTags(productSKU, body.Tags)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("category.go - updateProductTags - SetProductTags error for sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save tags"))
+		return
+	}
+	json.NewEncoder(w).Encode(tags)
+}
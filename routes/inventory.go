@@ -1,790 +1,322 @@
 package routes
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
-	"time"
 
 	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/webhooks"
 	"../models"
+	"../webhooks"
 	"github.com/gorilla/mux"
 )
 
+// Returns every inventory row in JSON format, one per product per location. With any of ?limit=, ?offset=,
+// ?sort= or the filters (?sku=, ?location=, ?warehouse=, ?quantity_lt=) it returns one page of them
+// instead, see inventoryQueryFromRequest.
 func getInventories(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID"); err != nil {
+	q, ok := inventoryQueryFromRequest(w, r)
+	if !ok {
 		return
 	}
 
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("routes.go - getInventory - rows.Scan error")
-			fmt.Println(err)
+	var inv []*models.Inventory
+	var err error
+	if q == nil {
+		inv, err = inventories.ListInventories()
+	} else {
+		if !inventoryQueriesEnabled(w) {
+			return
 		}
-		if i.Deleted == 0 {
-			inv = append(inv, i)
+		var total int
+		inv, total, err = inventoryQueries.QueryInventories(q)
+		if err == nil {
+			writePageHeaders(w, r, total, q.Limit, q.Offset)
 		}
 	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("3")
-		fmt.Println(err)
-	}
-	json.NewEncoder(w).Encode(inv)
-}
-
-func getInventory(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	params := mux.Vars(r)
-	id := params["id"]
-	found := -1
-
-	//new stuff can easily change to work off of SKU
-	inventoryID, err := strconv.Atoi(id)
-	if inventoryID < 1 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid inventory ID."))
-		return
-	}
-
-	tx, err := db.Begin()
 	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID WHERE I.ProductID = ?", id); err != nil {
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory id: " + id)
+		fmt.Println("inventory.go - getInventories - ListInventories error")
 		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load inventories"))
 		return
 	}
-	defer rows.Close()
-
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if i.Deleted == 1 {
-			inv = append(inv, i)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Inventory not found"))
-			return
-		}
-		found = inventoryID
-		inv = append(inv, i)
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("inventory.go - getInventory - rows.Err()")
-		fmt.Println(err)
-	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
-		return
-	}
-	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(inv)
 }
 
-// Returns a specific inventory from the database in JSON format
+// Returns the inventory of a specific SKU in JSON format, its quantity at each location, the total and
+// what is in transit between locations
 func getInventoryBySKU(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	params := mux.Vars(r)
 	sku := params["sku"]
-	found := -1
 
-	productSKU, err := strconv.Atoi(sku)
+	productSKU, _ := strconv.Atoi(sku)
 	if productSKU < 1 {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("400 - Invalid product SKU."))
 		return
 	}
 
-	tx, err := db.Begin()
+	inv, err := inventories.GetInventory(productSKU)
 	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	// former fancy join line, rows, err = tx.Query("SELECT * FROM Inventory INNER JOIN Product ON Inventory.ProductID = Product.ProductID WHERE SKU = ?", sku); err != nil
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P ON I.ProductID = P.ProductID WHERE P.SKU = ?", sku); err != nil {
-		fmt.Println("inventory.go - getInventoryBySKU - tx.Query error selecting inventory sku: " + sku)
-		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
-		return
-	}
-	defer rows.Close()
-
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if i.Deleted == 1 {
-			inv = append(inv, i)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
-			return
-		}
-		found = productSKU
-		inv = append(inv, i)
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("inventory.go - getInventoryBySKU - rows.Err()")
+		fmt.Println("inventory.go - getInventoryBySKU - error selecting inventory sku: " + sku)
 		fmt.Println(err)
-	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("404 - Inventory not found"))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(inv)
-}
-
-// Updates the inventory value for the inventory item
-func updateInventory(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	params := mux.Vars(r)
-	id := params["id"]
-	found := -1
-
-	//new stuff can easily change to work off of SKU
-	inventoryID, err := strconv.Atoi(id)
-	if inventoryID < 1 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid inventory ID."))
-		return
-	}
-
-	var inventory models.Inventory
-	err = json.NewDecoder(r.Body).Decode(&inventory)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P ON I.ProductID = P.ProductID WHERE I.InventoryID = ?", id); err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory id: " + id)
-		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
-		return
-	}
-	defer rows.Close()
-
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
+	level := models.NewStockLevel(productSKU, inv)
+	if transfers != nil {
+		if level.InTransit, err = transfers.InTransit(productSKU); err != nil {
+			fmt.Println("inventory.go - getInventoryBySKU - error totalling transfers sku: " + sku)
 			fmt.Println(err)
 		}
-		if i.Deleted == 1 {
-			inv = append(inv, i)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
-			return
-		}
-		found = inventoryID
-		inv = append(inv, i)
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("inventory.go - getInventory - rows.Err()")
-		fmt.Println(err)
-	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
-		return
 	}
-
-	res, err := tx.Exec("UPDATE Inventory SET InventoryID = ?, Quantity = ?, DateLastUpdated = ?, Deleted = ?, ProductID = ? WHERE InventoryID = ?", inv[0].InventoryID, params["quantity"], time.Now(), inv[0].Deleted, inv[0].ProductID, id)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Println("1")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400"))
-		return
-	}
-	rowCnt, err := res.RowsAffected()
-	if err != nil {
-		fmt.Println("4")
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Insert failed"))
-		return
-	}
-	defer rows.Close()
-
-	fmt.Printf("update affected = %d\n", rowCnt)
-
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(level)
 }
 
+// Sets the quantity of the inventory for the given SKU at one location
 func updateInventoryBySKU(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	params := mux.Vars(r)
 	sku := params["sku"]
-	found := -1
 
-	//new stuff can easily change to work off of SKU
-	inventoryID, err := strconv.Atoi(sku)
-	if inventoryID < 1 {
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("400 - Invalid inventory ID."))
 		return
 	}
 
-	var inventory models.Inventory
-	err = json.NewDecoder(r.Body).Decode(&inventory)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	tx, err := db.Begin()
+	quantity, err := strconv.Atoi(params["quantity"])
 	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid quantity."))
 		return
 	}
 
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID WHERE P.SKU = ?", sku); err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory sku: " + sku)
-		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
-		return
-	}
-	defer rows.Close()
-
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if i.Deleted == 1 {
-			inv = append(inv, i)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
-			return
-		}
-		found = inventoryID
-		inv = append(inv, i)
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("inventory.go - getInventory - rows.Err()")
-		fmt.Println(err)
-	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
-		return
-	}
-
-	res, err := tx.Exec("UPDATE Inventory SET InventoryID = ?, Quantity = ?, DateLastUpdated = ?, Deleted = ?, ProductID = ? WHERE InventoryID = ?", inv[0].InventoryID, params["quantity"], time.Now(), inv[0].Deleted, inv[0].ProductID, inv[0].InventoryID)
+	m, err := movementFromRequest(r, models.ReasonCountCorrection)
 	if err != nil {
-		fmt.Println(err)
-		fmt.Println("1")
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid"))
+		w.Write([]byte("400 - " + err.Error()))
 		return
 	}
-	rowCnt, err := res.RowsAffected()
-	if err != nil {
-		fmt.Println("4")
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Insert failed"))
-		return
+
+	err = inventories.SetInventory(productSKU, m.LocationID, quantity, m)
+	if err == nil {
+		inventoryChanged(m)
 	}
-	defer rows.Close()
+	writeInventoryResult(w, sku, err)
+}
 
-	fmt.Printf("update affected = %d\n", rowCnt)
+// Increases the quantity of the inventory for the given SKU by one
+func incrementInventoryBySKU(w http.ResponseWriter, r *http.Request) {
+	stepInventoryBySKU(w, r, 1, models.ReasonReceive)
+}
 
-	w.WriteHeader(http.StatusOK)
+// Decreases the quantity of the inventory for the given SKU by one
+func decrementInventoryBySKU(w http.ResponseWriter, r *http.Request) {
+	stepInventoryBySKU(w, r, -1, models.ReasonSale)
 }
 
-func incrementInventory(w http.ResponseWriter, r *http.Request) {
+// stepInventoryBySKU applies a fixed delta, used by the scanner friendly increment and decrement routes
+func stepInventoryBySKU(w http.ResponseWriter, r *http.Request, delta int, reason string) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	params := mux.Vars(r)
-	id := params["id"]
-	found := -1
+	sku := params["sku"]
 
-	//new stuff can easily change to work off of SKU
-	inventoryID, err := strconv.Atoi(id)
-	if inventoryID < 1 {
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("400 - Invalid inventory ID."))
 		return
 	}
 
-	var inventory models.Inventory
-	err = json.NewDecoder(r.Body).Decode(&inventory)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P on P.ProductID = I.ProductID WHERE I.InventoryID = ?", id); err != nil {
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory id: " + id)
-		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
-		return
-	}
-	defer rows.Close()
-
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if i.Deleted == 1 {
-			inv = append(inv, i)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
-			return
-		}
-		found = inventoryID
-		inv = append(inv, i)
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("inventory.go - getInventory - rows.Err()")
-		fmt.Println(err)
-	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
-		return
-	}
+	stepInventory(w, r, productSKU, delta, reason)
+}
 
-	res, err := tx.Exec("UPDATE Inventory SET InventoryID = ?, Quantity = ?, DateLastUpdated = ?, Deleted = ?, ProductID = ? WHERE InventoryID = ?", inv[0].InventoryID, inv[0].Quantity+1, time.Now(), inv[0].Deleted, inv[0].ProductID, id)
-	if err != nil {
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory id: " + id)
-		fmt.Println(err)
-		fmt.Println("1")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400"))
-		return
-	}
-	rowCnt, err := res.RowsAffected()
+// stepInventory applies delta to the given SKU with the movement details from the request body
+func stepInventory(w http.ResponseWriter, r *http.Request, sku int, delta int, reason string) {
+	m, err := movementFromRequest(r, reason)
 	if err != nil {
-		fmt.Println("4")
-		fmt.Println(err)
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Insert failed"))
+		w.Write([]byte("400 - " + err.Error()))
 		return
 	}
-	defer rows.Close()
 
-	fmt.Printf("update affected = %d\n", rowCnt)
-
-	w.WriteHeader(http.StatusOK)
+	err = inventories.AdjustInventory(sku, m.LocationID, delta, m)
+	if err == nil {
+		inventoryChanged(m)
+	}
+	writeInventoryResult(w, strconv.Itoa(sku), err)
 }
 
-func incrementInventoryBySKU(w http.ResponseWriter, r *http.Request) {
+// Adds the signed delta from the JSON body to the quantity of the inventory for the given SKU in one
+// step, e.g. receiving a pallet. Responds with the recorded movement, including the new quantity.
+func adjustInventoryBySKU(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	params := mux.Vars(r)
 	sku := params["sku"]
-	found := -1
 
-	//new stuff can easily change to work off of SKU
-	inventoryID, err := strconv.Atoi(sku)
-	if inventoryID < 1 {
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("400 - Invalid inventory ID."))
 		return
 	}
 
-	var inventory models.Inventory
-	err = json.NewDecoder(r.Body).Decode(&inventory)
+	m, err := movementFromRequest(r, models.ReasonAdjustment)
 	if err != nil {
-		fmt.Println(err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P on P.ProductID = I.ProductID WHERE P.SKU = ?", sku); err != nil {
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory id: " + sku)
-		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
-		return
-	}
-	defer rows.Close()
-
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if i.Deleted == 1 {
-			inv = append(inv, i)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
-			return
-		}
-		found = inventoryID
-		inv = append(inv, i)
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("inventory.go - getInventory - rows.Err()")
-		fmt.Println(err)
-	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
 		return
 	}
-
-	res, err := tx.Exec("UPDATE Inventory SET InventoryID = ?, Quantity = ?, DateLastUpdated = ?, Deleted = ?, ProductID = ? WHERE InventoryID = ?", inv[0].InventoryID, inv[0].Quantity+1, time.Now(), inv[0].Deleted, inv[0].ProductID, inv[0].InventoryID)
-	if err != nil {
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory by sku: " + sku)
-		fmt.Println(err)
-		fmt.Println("1")
+	if m.Delta == 0 {
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400"))
+		w.Write([]byte("400 - Please include a non-zero delta."))
 		return
 	}
-	rowCnt, err := res.RowsAffected()
+
+	err = inventories.AdjustInventory(productSKU, m.LocationID, m.Delta, m)
 	if err != nil {
-		fmt.Println("4")
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Insert failed"))
+		writeInventoryResult(w, sku, err)
 		return
 	}
-	defer rows.Close()
-
-	fmt.Printf("update affected = %d\n", rowCnt)
-
+	inventoryChanged(m)
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(m)
 }
 
-func decrementInventory(w http.ResponseWriter, r *http.Request) {
+// Returns a page of the movement ledger for the given SKU, newest first. ?limit= (default 50, at most
+// 500) and ?offset= page through the history.
+func getInventoryMovementsBySKU(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	params := mux.Vars(r)
-	id := params["id"]
-	found := -1
+	sku := params["sku"]
 
-	//new stuff can easily change to work off of SKU
-	inventoryID, err := strconv.Atoi(id)
-	if inventoryID < 1 {
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid inventory ID."))
+		w.Write([]byte("400 - Invalid product SKU."))
 		return
 	}
 
-	var inventory models.Inventory
-	err = json.NewDecoder(r.Body).Decode(&inventory)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
+	limit, offset, ok := pageFromRequest(w, r)
+	if !ok {
 		return
 	}
 
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P on P.ProductID = I.ProductID WHERE I.InventoryID = ?", id); err != nil {
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory id: " + id)
+	moves, err := inventories.ListMovements(productSKU, limit, offset)
+	if err != nil {
+		fmt.Println("inventory.go - getInventoryMovementsBySKU - error selecting movements sku: " + sku)
 		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load movements"))
 		return
 	}
-	defer rows.Close()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(moves)
+}
 
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
+// pageFromRequest reads ?limit= (default 50, at most 500) and ?offset= for the paged ledgers, writing a
+// 400 if either is invalid
+func pageFromRequest(w http.ResponseWriter, r *http.Request) (limit int, offset int, ok bool) {
+	limit = 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - limit must be between 1 and 500."))
+			return 0, 0, false
 		}
-		if i.Deleted == 1 {
-			inv = append(inv, i)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
-			return
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid offset."))
+			return 0, 0, false
 		}
-		found = inventoryID
-		inv = append(inv, i)
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("inventory.go - getInventory - rows.Err()")
-		fmt.Println(err)
+		offset = n
 	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
-		return
-	}
-
-	res, err := tx.Exec("UPDATE Inventory SET InventoryID = ?, Quantity = ?, DateLastUpdated = ?, Deleted = ?, ProductID = ? WHERE InventoryID = ?", inv[0].InventoryID, inv[0].Quantity-1, time.Now(), inv[0].Deleted, inv[0].ProductID, id)
-	fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory id: " + id)
-	//res, err := tx.Exec("UPDATE Inventory SET InventoryID = ?, Quantity = ?, DateLastUpdated = ?, ProductID = ?, Deleted = ? WHERE InventoryID = ?", inv[0].InventoryID, inv[0].Quantity-1, time.Now(), inv[0].ProductID, inv[0].Deleted, id)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Println("1")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400"))
-		return
-	}
-	rowCnt, err := res.RowsAffected()
-	if err != nil {
-		fmt.Println("4")
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Insert failed"))
-		return
-	}
-	defer rows.Close()
-
-	fmt.Printf("update affected = %d\n", rowCnt)
-
-	w.WriteHeader(http.StatusOK)
-
+	return limit, offset, true
 }
 
-func decrementInventoryBySKU(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	params := mux.Vars(r)
-	sku := params["sku"]
-	found := -1
-
-	//new stuff can easily change to work off of SKU
-	inventoryID, err := strconv.Atoi(sku)
-	if inventoryID < 1 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid inventory ID."))
-		return
-	}
+// inventoryChanged runs after every successful quantity change with the recorded movement
+func inventoryChanged(m *models.InventoryMovement) {
+	changes.publish(m)
+	publish(webhooks.InventoryChanged, m)
+	checkLowStock(m)
+}
 
-	var inventory models.Inventory
-	err = json.NewDecoder(r.Body).Decode(&inventory)
-	if err != nil {
-		fmt.Println(err)
+// movementFromRequest reads the optional {"delta", "reason", "note", "user", "location"} body sent with
+// an inventory change. Any field left out keeps its default, the reason falls back to defaultReason and
+// the location to ?location=, then the default location.
+func movementFromRequest(r *http.Request, defaultReason string) (*models.InventoryMovement, error) {
+	var body struct {
+		Delta    int    `json:"delta"`
+		Reason   string `json:"reason"`
+		Note     string `json:"note"`
+		User     string `json:"user"`
+		Location int    `json:"location"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("Invalid JSON body.")
+		}
 	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		return
+	if body.Reason == "" {
+		body.Reason = defaultReason
 	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT I.*, P.SKU FROM Inventory I INNER JOIN Product P on P.ProductID = I.ProductID WHERE P.SKU = ?", sku); err != nil {
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory id: " + sku)
-		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
-		return
+	if !models.ValidReason(body.Reason) {
+		return nil, fmt.Errorf("Unknown reason %q.", body.Reason)
 	}
-	defer rows.Close()
-
-	inv := make([]*models.Inventory, 0)
-	for rows.Next() {
-		i := new(models.Inventory)
-		err := rows.Scan(&i.InventoryID, &i.Quantity, &i.DateLastUpdated, &i.Deleted, &i.ProductID, &i.SKU)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if i.Deleted == 1 {
-			inv = append(inv, i)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
-			return
+	// Scanners that can't send a body name the location in the URL instead
+	if v := r.URL.Query().Get("location"); body.Location == 0 && v != "" {
+		body.Location, _ = strconv.Atoi(v)
+		if body.Location < 1 {
+			return nil, fmt.Errorf("Invalid location.")
 		}
-		found = inventoryID
-		inv = append(inv, i)
 	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("inventory.go - getInventory - rows.Err()")
-		fmt.Println(err)
+	if body.Location == 0 {
+		body.Location = models.DefaultLocation
 	}
+	return &models.InventoryMovement{Delta: body.Delta, Reason: body.Reason, Note: body.Note, User: body.User, LocationID: body.Location}, nil
+}
 
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
+// writeInventoryResult maps the error from an inventory update onto the response
+func writeInventoryResult(w http.ResponseWriter, sku string, err error) {
+	if err == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	fmt.Println("inventory.go - error updating inventory sku: " + sku)
+	fmt.Println(err)
+	if err == models.ErrNotFound {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("404 - Product not found"))
 		return
 	}
-
-	res, err := tx.Exec("UPDATE Inventory SET InventoryID = ?, Quantity = ?, DateLastUpdated = ?, Deleted = ?, ProductID = ? WHERE InventoryID = ?", inv[0].InventoryID, inv[0].Quantity-1, time.Now(), inv[0].Deleted, inv[0].ProductID, inv[0].InventoryID)
-	if err != nil {
-		fmt.Println("inventory.go - getInventory - tx.Query error selecting inventory by sku: " + sku)
-		fmt.Println(err)
-		fmt.Println("1")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid"))
+	if err == models.ErrLocationNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Location not found"))
 		return
 	}
-	rowCnt, err := res.RowsAffected()
-	if err != nil {
-		fmt.Println("4")
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Insert failed"))
+	var stockErr *models.StockError
+	if errors.As(err, &stockErr) {
+		// Tell the caller what is actually on hand so the scanner can show it
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(stockErr)
 		return
 	}
-	defer rows.Close()
-
-	fmt.Printf("update affected = %d\n", rowCnt)
-
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte("400 - Invalid"))
 }
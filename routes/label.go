@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	// "github.com/Xero67/web-fire-family/labels"
+	"../labels"
+	"github.com/gorilla/mux"
+)
+
+// Renders a shelf label for the product with the given SKU. ?format= is png (the default), svg or zpl,
+// ?barcode= is code128 (the default) or qr.
+func getProductLabel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	params := mux.Vars(r)
+	sku := params["sku"]
+
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	contentType := map[string]string{"": "image/png", "png": "image/png", "svg": "image/svg+xml", "zpl": "application/x-zpl"}[format]
+	if contentType == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - format must be png, svg or zpl."))
+		return
+	}
+
+	p, err := products.GetProduct(productSKU)
+	if err != nil {
+		fmt.Println("label.go - getProductLabel - error selecting product sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	}
+	label, err := labels.FromProduct(p, r.URL.Query().Get("barcode"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	switch format {
+	case "svg":
+		err = label.SVG(w)
+	case "zpl":
+		err = label.ZPL(w)
+	default:
+		err = label.PNG(w)
+	}
+	if err != nil {
+		fmt.Println("label.go - getProductLabel - error rendering label for sku: " + sku)
+		fmt.Println(err)
+	}
+}
+
+// maxSheetLabels caps how many labels one /labels/sheet request can print
+const maxSheetLabels = 5000
+
+// Lays out labels for a list of SKUs on Avery style sheets and returns them as a PDF. Takes a JSON body
+// {"items": [{"sku", "copies"}], "template", "barcode", "skip"} plus any of the labels.Sheet fields to
+// override the template, e.g. {"template": "5160", "topmargin": 0.55} for a printer that feeds a little
+// high. copies defaults to 1.
+func createLabelSheet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+
+	var body struct {
+		labels.Sheet
+		Template string `json:"template"`
+		Barcode  string `json:"barcode"`
+		Skip     int    `json:"skip"`
+		Items    []struct {
+			SKU    int `json:"sku"`
+			Copies int `json:"copies"`
+		} `json:"items"`
+	}
+	// Read the template name first, then decode again over the top of it so any field in the body
+	// overrides the template's
+	if err := json.Unmarshal(raw, &body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+	if body.Template == "" {
+		body.Template = labels.DefaultTemplate
+	}
+	sheet, ok := labels.Templates[body.Template]
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Unknown template " + strconv.Quote(body.Template) + "."))
+		return
+	}
+	body.Sheet = sheet
+	json.Unmarshal(raw, &body)
+	if err := body.Sheet.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error() + "."))
+		return
+	}
+	if body.Skip < 0 || body.Skip >= body.Sheet.PerPage() {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - skip must be between 0 and " + strconv.Itoa(body.Sheet.PerPage()-1) + "."))
+		return
+	}
+	if len(body.Items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Please include at least one item."))
+		return
+	}
+
+	sheetLabels := make([]*labels.Label, 0)
+	for _, item := range body.Items {
+		if item.Copies == 0 {
+			item.Copies = 1
+		}
+		if item.SKU < 1 || item.Copies < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid item, please include a SKU and a positive number of copies."))
+			return
+		}
+		if len(sheetLabels)+item.Copies > maxSheetLabels {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - At most " + strconv.Itoa(maxSheetLabels) + " labels can be printed at once."))
+			return
+		}
+
+		p, err := products.GetProduct(item.SKU)
+		if err != nil {
+			fmt.Println("label.go - createLabelSheet - error selecting product sku: " + strconv.Itoa(item.SKU))
+			fmt.Println(err)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404 - Product not found: SKU " + strconv.Itoa(item.SKU)))
+			return
+		}
+		label, err := labels.FromProduct(p, body.Barcode)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - " + err.Error()))
+			return
+		}
+		for i := 0; i < item.Copies; i++ {
+			sheetLabels = append(sheetLabels, label)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="labels.pdf"`)
+	if err := body.Sheet.PDF(w, sheetLabels, body.Skip); err != nil {
+		fmt.Println("label.go - createLabelSheet - error rendering sheet")
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/mux"
+)
+
+// locationFromRequest decodes and checks the location in the request body
+func locationFromRequest(r *http.Request) (*models.Location, error) {
+	l := new(models.Location)
+	if err := json.NewDecoder(r.Body).Decode(l); err != nil {
+		return nil, fmt.Errorf("Invalid JSON body.")
+	}
+	l.Warehouse, l.Aisle, l.Bin = strings.TrimSpace(l.Warehouse), strings.TrimSpace(l.Aisle), strings.TrimSpace(l.Bin)
+	if l.Warehouse == "" {
+		return nil, fmt.Errorf("Please include a warehouse.")
+	}
+	if l.Bin != "" && l.Aisle == "" {
+		return nil, fmt.Errorf("Please include the aisle of the bin.")
+	}
+	return l, nil
+}
+
+// locationID reads the {id} route variable, writing a 400 if it isn't a valid ID
+func locationID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid location ID."))
+		return 0, false
+	}
+	return id, true
+}
+
+// Returns every location in JSON format
+func getLocations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	locs, err := inventories.ListLocations()
+	if err != nil {
+		fmt.Println("location.go - getLocations - ListLocations error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load locations"))
+		return
+	}
+	json.NewEncoder(w).Encode(locs)
+}
+
+// Returns a specific location in JSON format
+func getLocationByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	id, ok := locationID(w, r)
+	if !ok {
+		return
+	}
+	l, err := inventories.GetLocation(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Location not found"))
+		return
+	}
+	json.NewEncoder(w).Encode(l)
+}
+
+// Adds a location from the passed in JSON and returns it with its new ID
+func createLocation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	l, err := locationFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+	err = inventories.CreateLocation(l)
+	if err == models.ErrExists {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Location " + l.Code() + " already exists"))
+		return
+	}
+	if err != nil {
+		fmt.Println("location.go - createLocation - CreateLocation error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save location"))
+		return
+	}
+	json.NewEncoder(w).Encode(l)
+}
+
+// Renames the location with the given ID. The stock in it stays put.
+func updateLocationByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	id, ok := locationID(w, r)
+	if !ok {
+		return
+	}
+	l, err := locationFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+
+	err = inventories.UpdateLocation(id, l)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case models.ErrLocationNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Location not found"))
+	case models.ErrExists:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Location " + l.Code() + " already exists"))
+	default:
+		fmt.Println("location.go - updateLocationByID - UpdateLocation error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save location"))
+	}
+}
+
+// Removes the location with the given ID. Only empty locations can go, and never the default one.
+func deleteLocationByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	id, ok := locationID(w, r)
+	if !ok {
+		return
+	}
+
+	err := inventories.DeleteLocation(id)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"deleted": "true"}`))
+	case models.ErrLocationNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Location not found"))
+	case models.ErrInUse:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Location still holds, expects or reserves stock or is the default location"))
+	default:
+		fmt.Println("location.go - deleteLocationByID - DeleteLocation error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to delete location"))
+	}
+}
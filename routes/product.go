@@ -1,129 +1,64 @@
 package routes
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/webhooks"
 	"../models"
+	"../webhooks"
 	"github.com/gorilla/mux"
 )
 
-// Returns all of the products stored in the database in JSON format
+// Returns all of the products stored in the database in JSON format. With any of ?limit=, ?offset=, ?sort=
+// or the filters (?category=, ?tag=, ?color=, ?price_min= ...) it returns one page of them instead, see
+// productQueryFromRequest, and with ?group=style the variants are listed under their styles.
 func getProducts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT P.*, I.Quantity FROM Product P LEFT JOIN Inventory I ON P.ProductID = I.ProductID"); err != nil {
-		return
-	}
-
-	prods := make([]*models.Product, 0)
-	for rows.Next() {
-		p := new(models.Product)
-		err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted, &p.Quantity)
-		if err != nil {
-			//More error handling
-			fmt.Println("routes.go - getProducts - rows.Scan error")
-			fmt.Println(err)
-		}
-		if p.Deleted == 0 {
-			prods = append(prods, p)
-		}
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("3")
-		fmt.Println(err)
-	}
-	json.NewEncoder(w).Encode(prods)
-}
-
-// Returns a specific product from the database in JSON format
-func getProduct(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	params := mux.Vars(r)
-	id := params["id"]
-	found := -1
-
-	productID, err := strconv.Atoi(id)
-	if productID < 1 {
+	group := r.URL.Query().Get("group")
+	if group != "" && group != "style" {
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid product ID."))
-		return
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
+		w.Write([]byte("400 - Products can only be grouped by style."))
 		return
 	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT P.*, I.Quantity FROM Product P LEFT JOIN Inventory I ON P.ProductID = I.ProductID  WHERE P.ProductID = ?", id); err != nil {
-		fmt.Println("routes.go - getProduct - tx.Query error selecting product id: " + id)
-		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
+	q, ok := productQueryFromRequest(w, r)
+	if !ok {
 		return
 	}
-	defer rows.Close()
 
-	prods := make([]*models.Product, 0)
-	for rows.Next() {
-		p := new(models.Product)
-		err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted, &p.Quantity)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
+	var prods []*models.Product
+	var err error
+	if q == nil {
+		prods, err = products.ListProducts()
+	} else {
+		if !productQueriesEnabled(w) {
+			return
 		}
-		if p.Deleted == 1 {
-			prods = append(prods, p)
+		var total int
+		prods, total, err = productQueries.QueryProducts(q)
+		if err == models.ErrCategoryNotFound {
 			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
+			w.Write([]byte("404 - Category not found"))
 			return
 		}
-		found = productID
-		prods = append(prods, p)
+		if err == nil {
+			writePageHeaders(w, r, total, q.Limit, q.Offset)
+		}
 	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("routes.go - getProduct - rows.Err()")
+	if err != nil {
+		fmt.Println("product.go - getProducts - ListProducts error")
 		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load products"))
+		return
 	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
-		// json.NewEncoder(w).Encode("404 - Product not found")
+	if group == "style" {
+		writeGroupedProducts(w, prods)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(prods)
 }
 
@@ -132,70 +67,24 @@ func getProductBySKU(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	params := mux.Vars(r)
 	sku := params["sku"]
-	found := -1
 
-	productSKU, err := strconv.Atoi(sku)
+	productSKU, _ := strconv.Atoi(sku)
 	if productSKU < 1 {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("400 - Invalid product SKU."))
 		return
 	}
 
-	tx, err := db.Begin()
+	p, err := products.GetProduct(productSKU)
 	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	var rows *sql.Rows
-	if rows, err = tx.Query("SELECT P.*, I.Quantity FROM Product P LEFT JOIN Inventory I ON P.ProductID = I.ProductID  WHERE P.SKU = ?", sku); err != nil {
-		fmt.Println("routes.go - getProduct - tx.Query error selecting product sku: " + sku)
-		fmt.Println(err)
-		json.NewEncoder(w).Encode(err)
-		return
-	}
-	defer rows.Close()
-
-	prods := make([]*models.Product, 0)
-	for rows.Next() {
-		p := new(models.Product)
-		err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted, &p.Quantity)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if p.Deleted == 1 {
-			prods = append(prods, p)
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - Product not found"))
-			return
-		}
-		found = productSKU
-		prods = append(prods, p)
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("routes.go - getProduct - rows.Err()")
+		fmt.Println("product.go - getProductBySKU - error selecting product sku: " + sku)
 		fmt.Println(err)
-	}
-
-	//STILL NEED THIS FOR IF ITS NOT FOUND
-	if found == -1 {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("404 - Product not found"))
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(prods)
+	json.NewEncoder(w).Encode([]*models.Product{p})
 }
 
 // Smart thing to do would be to check the DB for the item already being created first
@@ -209,9 +98,7 @@ func createProduct(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		fmt.Println(err)
 	}
-	fmt.Println(product)
 
-	//new stuff
 	//probs want to validate each required column
 	if product.ProductName == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -219,379 +106,98 @@ func createProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	res, err := tx.Exec("INSERT INTO Product (ProductName, NotificationQuantity, Color, TrimColor, Size, Price, Dimensions, SKU) VALUES(?,?,?,?,?,?,?,?)", product.ProductName, product.NotificationQuantity, product.Color, product.TrimColor, product.Size, product.Price, product.Dimensions, product.SKU)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Println("1")
+	if !models.ValidNegativeStock(product.NegativeStock) {
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid product, please include a name, notification quantity, color, trim color, size, price, dimensions, and SKU"))
+		w.Write([]byte("400 - negativestock must be reject, allow or clamp."))
 		return
 	}
-	lastId, err := res.LastInsertId()
-	if err != nil {
-		fmt.Println("3")
-		fmt.Println(err)
+
+	lastId, err := products.CreateProduct(&product)
+	if err == models.ErrStyleNotFound {
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Insert failed"))
+		w.Write([]byte("400 - Style not found."))
 		return
 	}
-	rowCnt, err := res.RowsAffected()
 	if err != nil {
-		fmt.Println("4")
+		fmt.Println("product.go - createProduct - CreateProduct error")
 		fmt.Println(err)
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Insert failed"))
+		w.Write([]byte("400 - Invalid product, please include a name, notification quantity, color, trim color, size, price, dimensions, and SKU"))
 		return
 	}
-	fmt.Printf("ID = %d, affected = %d\n", lastId, rowCnt)
+	product.ProductID = int(lastId)
+	publish(webhooks.ProductCreated, &product)
 	lstId := strconv.Itoa(int(lastId))
 	//Not sure what we want to return when sucess?
 	w.Write([]byte("{\"ProductId\": " + lstId + "}"))
 }
 
-// Deletes the specified product from the database
-// If the route logic were seperate from the DB logic, we could just call a getproductbyID method that is used
-// by both
-func deleteProduct(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	params := mux.Vars(r)
-	id := params["id"]
-	found := -1
-
-	productID, err := strconv.Atoi(id)
-	if productID < 1 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid product ID."))
-		return
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	rows, err := tx.Query("SELECT * FROM Product WHERE ProductID = ?", id)
-	if err != nil {
-		//Error handlin
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
-		fmt.Errorf("404 - Product not found")
-		fmt.Println(err)
-		return
-	}
-	defer rows.Close()
-	prods := make([]*models.Product, 0)
-	for rows.Next() {
-
-		p := new(models.Product)
-		err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if p.Deleted == 0 {
-			prods = append(prods, p)
-		}
-		found = productID
-
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("3")
-		fmt.Println(err)
-	}
-
-	if found == -1 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid product ID."))
-		return
-	} else { //All deletion logic goes here because it confirms the find
-		prods[0].Deleted = 1
-		_, err := tx.Exec("UPDATE Product SET Deleted = 1 WHERE ProductID = ?", prods[0].ProductID)
-		if err != nil {
-			fmt.Println(err)
-		}
-
-	}
-	w.Write([]byte(`{"deleted": "true"}`))
-	w.WriteHeader(http.StatusOK)
-}
-
+// Archives the product with the given SKU. Isn't really a delete, it just flags the row.
 func deleteProductBySKU(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	params := mux.Vars(r)
-	id := params["sku"]
-	found := -1
+	sku := params["sku"]
 
-	productID, err := strconv.Atoi(id)
-	if productID < 1 {
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("400 - Invalid product ID."))
 		return
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	rows, err := tx.Query("SELECT * FROM Product WHERE SKU = ?", id)
-	if err != nil {
-		//Error handlin
+	if err := products.ArchiveProduct(productSKU); err != nil {
+		fmt.Println("product.go - deleteProductBySKU - ArchiveProduct error for sku: " + sku)
+		fmt.Println(err)
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("404 - Product not found"))
-		fmt.Errorf("404 - Product not found")
-		fmt.Println(err)
-		return
-	}
-	defer rows.Close()
-	prods := make([]*models.Product, 0)
-	for rows.Next() {
-
-		p := new(models.Product)
-		err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if p.Deleted == 0 {
-			prods = append(prods, p)
-		}
-		found = productID
-
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("3")
-		fmt.Println(err)
-	}
-
-	if found == -1 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid product ID."))
 		return
-	} else { //All deletion logic goes here because it confirms the find
-		prods[0].Deleted = 1
-		fmt.Println(prods[0].ProductID)
-		_, err := tx.Exec("UPDATE Product SET Deleted = 1 WHERE ProductID = ?", prods[0].ProductID)
-		if err != nil {
-			fmt.Println(err)
-		}
-
 	}
-	w.Write([]byte(`{"deleted": "true"}`))
+	publish(webhooks.ProductArchived, &models.Product{SKU: productSKU, Deleted: 1})
 	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"deleted": "true"}`))
 }
 
-// Updates the product
-func updateProduct(w http.ResponseWriter, r *http.Request) {
+// Updates the product with the given SKU. Every field is overwritten.
+func updateProductBySKU(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	var product models.Product
 	_ = json.NewDecoder(r.Body).Decode(&product)
 
 	params := mux.Vars(r)
-	id := params["id"]
-	found := -1
+	sku := params["sku"]
 
-	//new block
-	productID, err := strconv.Atoi(id)
-	if err != nil || productID < 1 {
+	productSKU, err := strconv.Atoi(sku)
+	if err != nil || productSKU < 1 {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("400 - Invalid product ID."))
 		return
 	}
-	tx, err := db.Begin()
-	if err != nil {
+
+	if !models.ValidNegativeStock(product.NegativeStock) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - negativestock must be reject, allow or clamp."))
 		return
 	}
 
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	rows, err := tx.Query("SELECT * FROM Product WHERE ProductID = ?", id)
-	if err != nil {
-		//Error handlin
+	err = products.UpdateProduct(productSKU, &product)
+	if err == models.ErrNotFound {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("404 - Product not found"))
-		fmt.Errorf("404 - Product not found")
-		fmt.Println(err)
 		return
 	}
-	defer rows.Close()
-	prods := make([]*models.Product, 0)
-	for rows.Next() {
-
-		p := new(models.Product)
-		err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if p.Deleted == 0 {
-			prods = append(prods, p)
-		}
-		found = productID
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("3")
-		fmt.Println(err)
-	}
-
-	if found == -1 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid product ID."))
-		return
-	} else { //All deletion logic goes here because it confirms the find
-		//need to do validation here
-		res, err := tx.Exec("UPDATE Product SET ProductName = ?, NotificationQuantity = ?, Color = ?, TrimColor = ?, Size = ?, Price = ?, Dimensions = ?, SKU = ? WHERE ProductID = ?", product.ProductName, product.NotificationQuantity, product.Color, product.TrimColor, product.Size, product.Price, product.Dimensions, product.SKU, prods[0].ProductID)
-		if err != nil {
-			fmt.Println(err)
-			fmt.Println("1")
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("400 - Invalid product, please include a name, notification quantity, color, trim color, size, price, dimensions, and SKU"))
-			return
-		}
-		rowCnt, err := res.RowsAffected()
-		if err != nil {
-			fmt.Println("4")
-			fmt.Println(err)
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("400 - Insert failed"))
-			return
-		}
-		fmt.Printf("update affected = %d\n", rowCnt)
-		//Not sure what we want to return when success?
-		w.WriteHeader(http.StatusAccepted)
-	}
-}
-
-func updateProductBySKU(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	var product models.Product
-	_ = json.NewDecoder(r.Body).Decode(&product)
-
-	params := mux.Vars(r)
-	id := params["sku"]
-	found := -1
-
-	//new block
-	productID, err := strconv.Atoi(id)
-	if err != nil || productID < 1 {
+	if err == models.ErrStyleNotFound {
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid product ID."))
-		return
-	}
-	tx, err := db.Begin()
-	if err != nil {
+		w.Write([]byte("400 - Style not found."))
 		return
 	}
-
-	defer func() {
-		switch err {
-		case nil:
-			err = tx.Commit()
-		default:
-			tx.Rollback()
-		}
-	}()
-
-	rows, err := tx.Query("SELECT * FROM Product WHERE SKU = ?", id)
 	if err != nil {
-		//Error handlin
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 - Product not found"))
-		fmt.Errorf("404 - Product not found")
-		fmt.Println(err)
-		return
-	}
-	defer rows.Close()
-	prods := make([]*models.Product, 0)
-	for rows.Next() {
-
-		p := new(models.Product)
-		err := rows.Scan(&p.ProductID, &p.ProductName, &p.NotificationQuantity, &p.Color, &p.TrimColor, &p.Size, &p.Price, &p.Dimensions, &p.SKU, &p.Deleted)
-		if err != nil {
-			//More error handling
-			fmt.Println("2")
-			fmt.Println(err)
-		}
-		if p.Deleted == 0 {
-			prods = append(prods, p)
-		}
-		found = productID
-	}
-	if err = rows.Err(); err != nil {
-		//Error handling
-		fmt.Println("3")
+		fmt.Println("product.go - updateProductBySKU - UpdateProduct error for sku: " + sku)
 		fmt.Println(err)
-	}
-
-	if found == -1 {
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Invalid product ID."))
+		w.Write([]byte("400 - Invalid product, please include a name, notification quantity, color, trim color, size, price, dimensions, and SKU"))
 		return
-	} else { //All deletion logic goes here because it confirms the find
-		//need to do validation here
-		res, err := tx.Exec("UPDATE Product SET ProductName = ?, NotificationQuantity = ?, Color = ?, TrimColor = ?, Size = ?, Price = ?, Dimensions = ?, SKU = ? WHERE ProductID = ?", product.ProductName, product.NotificationQuantity, product.Color, product.TrimColor, product.Size, product.Price, product.Dimensions, product.SKU, prods[0].ProductID)
-		if err != nil {
-			fmt.Println(err)
-			fmt.Println("1")
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("400 - Invalid product, please include a name, notification quantity, color, trim color, size, price, dimensions, and SKU"))
-			return
-		}
-		rowCnt, err := res.RowsAffected()
-		if err != nil {
-			fmt.Println("4")
-			fmt.Println(err)
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("400 - Insert failed"))
-			return
-		}
-		fmt.Printf("update affected = %d\n", rowCnt)
-		//Not sure what we want to return when success?
-		w.WriteHeader(http.StatusAccepted)
 	}
+	publish(webhooks.ProductUpdated, &product)
+	//Not sure what we want to return when success?
+	w.WriteHeader(http.StatusAccepted)
 }
@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+)
+
+// productQueriesEnabled writes a 404 when the product store can't page through products
+func productQueriesEnabled(w http.ResponseWriter) bool {
+	if productQueries == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Paging and filtering products is not enabled"))
+		return false
+	}
+	return true
+}
+
+// inventoryQueriesEnabled writes a 404 when the inventory store can't page through inventory rows
+func inventoryQueriesEnabled(w http.ResponseWriter) bool {
+	if inventoryQueries == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Paging and filtering inventories is not enabled"))
+		return false
+	}
+	return true
+}
+
+// The query parameters that GET /product and GET /inventories are filtered, sorted and paged by
+var productParams = []string{"limit", "offset", "sort", "category", "tag", "color", "trimcolor", "size", "style", "price_min", "price_max", "quantity_lt"}
+var inventoryParams = []string{"limit", "offset", "sort", "sku", "location", "warehouse", "quantity_lt"}
+
+// paged reports whether any of params is in the query string
+func paged(r *http.Request, params []string) bool {
+	q := r.URL.Query()
+	for _, p := range params {
+		if _, ok := q[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// listPageFromRequest is pageFromRequest for the product and inventory listings, which only come a page
+// at a time when ?limit= or ?offset= asks for it; a limit of 0 is every match otherwise.
+func listPageFromRequest(w http.ResponseWriter, r *http.Request) (limit int, offset int, ok bool) {
+	if !paged(r, []string{"limit", "offset"}) {
+		return 0, 0, true
+	}
+	return pageFromRequest(w, r)
+}
+
+// badQuery writes a 400 for an invalid query parameter and returns false
+func badQuery(w http.ResponseWriter, err error) bool {
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte("400 - " + err.Error()))
+	return false
+}
+
+// idParam reads the optional ID query parameter name, 0 if it isn't there
+func idParam(q url.Values, name string) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("Invalid %v.", name)
+	}
+	return n, nil
+}
+
+// quantityParam reads the optional quantity query parameter name
+func quantityParam(q url.Values, name string) (*int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %v.", name)
+	}
+	return &n, nil
+}
+
+// priceParam reads the optional price query parameter name
+func priceParam(q url.Values, name string) (*float64, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("Invalid %v.", name)
+	}
+	return &n, nil
+}
+
+// productQueryFromRequest reads the paging, sorting and filter parameters of GET /product, writing a 400
+// if any is invalid. It returns nil when there are none, the listing is every product then.
+func productQueryFromRequest(w http.ResponseWriter, r *http.Request) (*models.ProductQuery, bool) {
+	if !paged(r, productParams) {
+		return nil, true
+	}
+	limit, offset, ok := listPageFromRequest(w, r)
+	if !ok {
+		return nil, false
+	}
+	q := r.URL.Query()
+	pq := &models.ProductQuery{Tags: q["tag"], Color: q.Get("color"), TrimColor: q.Get("trimcolor"), Size: q.Get("size"), Sort: q.Get("sort"), Limit: limit, Offset: offset}
+
+	if !models.ValidProductSort(pq.Sort) {
+		return nil, badQuery(w, fmt.Errorf("Can't sort products by %q.", pq.Sort))
+	}
+	var err error
+	if pq.Category, err = idParam(q, "category"); err != nil {
+		return nil, badQuery(w, err)
+	}
+	if pq.StyleID, err = idParam(q, "style"); err != nil {
+		return nil, badQuery(w, err)
+	}
+	if pq.QuantityLt, err = quantityParam(q, "quantity_lt"); err != nil {
+		return nil, badQuery(w, err)
+	}
+	if pq.PriceMin, err = priceParam(q, "price_min"); err != nil {
+		return nil, badQuery(w, err)
+	}
+	if pq.PriceMax, err = priceParam(q, "price_max"); err != nil {
+		return nil, badQuery(w, err)
+	}
+	return pq, true
+}
+
+// inventoryQueryFromRequest reads the paging, sorting and filter parameters of GET /inventories like
+// productQueryFromRequest.
+func inventoryQueryFromRequest(w http.ResponseWriter, r *http.Request) (*models.InventoryQuery, bool) {
+	if !paged(r, inventoryParams) {
+		return nil, true
+	}
+	limit, offset, ok := listPageFromRequest(w, r)
+	if !ok {
+		return nil, false
+	}
+	q := r.URL.Query()
+	iq := &models.InventoryQuery{Warehouse: q.Get("warehouse"), Sort: q.Get("sort"), Limit: limit, Offset: offset}
+
+	if !models.ValidInventorySort(iq.Sort) {
+		return nil, badQuery(w, fmt.Errorf("Can't sort inventories by %q.", iq.Sort))
+	}
+	var err error
+	if iq.SKU, err = idParam(q, "sku"); err != nil {
+		return nil, badQuery(w, err)
+	}
+	if iq.LocationID, err = idParam(q, "location"); err != nil {
+		return nil, badQuery(w, err)
+	}
+	if iq.QuantityLt, err = quantityParam(q, "quantity_lt"); err != nil {
+		return nil, badQuery(w, err)
+	}
+	return iq, true
+}
+
+// writePageHeaders tells the client how many rows match over every page, in X-Total-Count, and where
+// the next and previous pages are, in Link. Has to be called before the body is written.
+func writePageHeaders(w http.ResponseWriter, r *http.Request, total int, limit int, offset int) {
+	w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, Link")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if limit == 0 {
+		return
+	}
+	var links []string
+	if offset+limit < total {
+		links = append(links, pageLink(r, limit, offset+limit, "next"))
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, pageLink(r, limit, prev, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLink is the Link header entry for the same request at another offset
+func pageLink(r *http.Request, limit int, offset int, rel string) string {
+	q := r.URL.Query()
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+	return "<" + r.URL.Path + "?" + q.Encode() + `>; rel="` + rel + `"`
+}
@@ -0,0 +1,222 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/mux"
+)
+
+// reservationsEnabled writes a 404 when the inventory store can't hold stock for orders
+func reservationsEnabled(w http.ResponseWriter) bool {
+	if reservations == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Reservations are not enabled"))
+		return false
+	}
+	return true
+}
+
+// reservationFromRequest decodes and checks the {"quantity", "location", "reference", "note", "user",
+// "expiresat"} body of a new reservation. The location falls back to ?location=, then the default
+// location, and the expiry to DefaultReservationExpiry from now.
+func reservationFromRequest(r *http.Request) (*models.Reservation, time.Time, error) {
+	var body struct {
+		Quantity  int    `json:"quantity"`
+		Location  int    `json:"location"`
+		Reference string `json:"reference"`
+		Note      string `json:"note"`
+		User      string `json:"user"`
+		ExpiresAt string `json:"expiresat"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, time.Time{}, fmt.Errorf("Invalid JSON body.")
+	}
+	if body.Quantity < 1 {
+		return nil, time.Time{}, fmt.Errorf("Please include a quantity of at least 1.")
+	}
+	if v := r.URL.Query().Get("location"); body.Location == 0 && v != "" {
+		body.Location, _ = strconv.Atoi(v)
+		if body.Location < 1 {
+			return nil, time.Time{}, fmt.Errorf("Invalid location.")
+		}
+	}
+	if body.Location == 0 {
+		body.Location = models.DefaultLocation
+	}
+	expires := time.Now().Add(models.DefaultReservationExpiry)
+	if body.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, body.ExpiresAt)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("expiresat must be an RFC 3339 time, e.g. 2017-11-24T17:00:00Z.")
+		}
+		if !t.After(time.Now()) {
+			return nil, time.Time{}, fmt.Errorf("expiresat must be in the future.")
+		}
+		expires = t
+	}
+	res := &models.Reservation{Quantity: body.Quantity, LocationID: body.Location, Reference: body.Reference, Note: body.Note, User: body.User}
+	return res, expires, nil
+}
+
+// reservationID reads the {id} route variable, writing a 400 if it isn't a valid ID
+func reservationID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid reservation ID."))
+		return 0, false
+	}
+	return id, true
+}
+
+// Returns the reservations of a SKU, newest first. ?status= narrows it down, e.g. to the active ones.
+func getReservationsBySKU(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !reservationsEnabled(w) {
+		return
+	}
+	sku := mux.Vars(r)["sku"]
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && !models.ValidReservationStatus(status) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Unknown status."))
+		return
+	}
+
+	list, err := reservations.ListReservations(productSKU, status)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("reservation.go - getReservationsBySKU - ListReservations error for sku: " + sku)
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load reservations"))
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// Holds a quantity of the SKU for an order from the passed in JSON, returning the reservation. Only
+// stock that is available (on hand and not already reserved) at the location can be held.
+func createReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !reservationsEnabled(w) {
+		return
+	}
+	sku := mux.Vars(r)["sku"]
+	productSKU, _ := strconv.Atoi(sku)
+	if productSKU < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid product SKU."))
+		return
+	}
+	res, expires, err := reservationFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+	res.SKU = productSKU
+
+	if err := reservations.CreateReservation(res, expires); err != nil {
+		writeInventoryResult(w, sku, err)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// Returns a specific reservation in JSON format
+func getReservationByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !reservationsEnabled(w) {
+		return
+	}
+	id, ok := reservationID(w, r)
+	if !ok {
+		return
+	}
+	res, err := reservations.GetReservation(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Reservation not found"))
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// Hands the stock held by a reservation back, e.g. when the order is cancelled
+func releaseReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !reservationsEnabled(w) {
+		return
+	}
+	id, ok := reservationID(w, r)
+	if !ok {
+		return
+	}
+	res, err := reservations.ReleaseReservation(id)
+	if !writeReservationResult(w, id, err) {
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// Takes the stock held by a reservation out of inventory when the order ships. Takes the same optional
+// {"reason", "note", "user"} body as decrement, the reason defaults to sale.
+func consumeReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !reservationsEnabled(w) {
+		return
+	}
+	id, ok := reservationID(w, r)
+	if !ok {
+		return
+	}
+	m, err := movementFromRequest(r, models.ReasonSale)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+
+	res, err := reservations.ConsumeReservation(id, m)
+	if !writeReservationResult(w, id, err) {
+		return
+	}
+	inventoryChanged(m)
+	json.NewEncoder(w).Encode(res)
+}
+
+// writeReservationResult writes the response for a failed release or consume, reporting whether the
+// reservation changed
+func writeReservationResult(w http.ResponseWriter, id int, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case models.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Reservation not found"))
+	case models.ErrReservationClosed:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Reservation is no longer active"))
+	default:
+		// a stock error when consuming, the reserved units were sold some other way
+		writeInventoryResult(w, "(reservation "+strconv.Itoa(id)+")", err)
+	}
+	return false
+}
@@ -1,53 +1,227 @@
 package routes
 
 import (
-	"database/sql"
 	//"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 
-	"../app"
+	"../alerts"
 	"../models"
-	// "github.com/Xero67/web-fire-family/app"
+	"../webhooks"
+	// "github.com/Xero67/web-fire-family/alerts"
 	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/webhooks"
 )
 
-var db *sql.DB
-var settings app.Dbdriver
-var dbConnection string
+var products models.ProductStore
+var boms models.BOMStore
+var styles models.StyleStore
+var categories models.CategoryStore
+var productQueries models.ProductQueryStore
+var searches models.SearchStore
+var inventoryQueries models.InventoryQueryStore
+var inventories models.InventoryStore
+var transfers models.TransferStore
+var reservations models.ReservationStore
+var stocktakes models.StocktakeStore
+var workOrders models.WorkOrderStore
+var notifier alerts.Notifier = alerts.LogNotifier{}
+
+var hooks *webhooks.Dispatcher
+
+// SetWebhooks turns on the /webhooks routes and delivery of product and inventory events through d.
+// Pass nil to turn them off again.
+func SetWebhooks(d *webhooks.Dispatcher) {
+	hooks = d
+}
+
+// SetNotifier changes where low stock alerts are sent, they are logged until it is called.
+func SetNotifier(n alerts.Notifier) {
+	notifier = n
+}
 
 // InitRoutes creates the web API routes and sets their event handler functions
-func InitRoutes(env models.Env) http.Handler {
+func InitRoutes(productStore models.ProductStore, inventoryStore models.InventoryStore) http.Handler {
 	router := mux.NewRouter()
 
-	db = env.Db
-
-	// Bootstrapping the setting
+	products = productStore
+	inventories = inventoryStore
+	// The transfer, reservation and stocktake routes are only on when the inventory store can also move
+	// stock between locations, hold it for orders and count it in sessions
+	transfers, _ = inventoryStore.(models.TransferStore)
+	reservations, _ = inventoryStore.(models.ReservationStore)
+	stocktakes, _ = inventoryStore.(models.StocktakeStore)
+	// Likewise the bill of materials, style and category routes need a product store that can keep kits,
+	// variants and categories, and the work order routes an inventory store that can build kits
+	boms, _ = productStore.(models.BOMStore)
+	styles, _ = productStore.(models.StyleStore)
+	categories, _ = productStore.(models.CategoryStore)
+	// Paged listings need stores that can page in the database, without them only the full lists work
+	productQueries, _ = productStore.(models.ProductQueryStore)
+	inventoryQueries, _ = inventoryStore.(models.InventoryQueryStore)
+	searches, _ = productStore.(models.SearchStore)
+	workOrders, _ = inventoryStore.(models.WorkOrderStore)
 
 	router.HandleFunc("/product", getProducts).Methods("GET")
+	//This finds products by their name, color, trim color, size and dimensions. Has to come before /product/{sku}.
+	router.HandleFunc("/product/search", searchProducts).Methods("GET")
 	// This should bring back a specific Product.
 	router.HandleFunc("/product/{sku}", getProductBySKU).Methods("GET")
-	// This should bring back a specific Product.
-	//router.HandleFunc("/productbyid/{id}", getProduct).Methods("GET")
 	//This creates a new product using a Json String.
 	router.HandleFunc("/product/create", createProduct).Methods("POST")
 	//This updates a product using a Json String.
-	router.HandleFunc("/product/update/{sku}", updateProductBySKU).Methods("POST")
+	router.HandleFunc("/product/update/{sku}", updateProductBySKU).Methods("POST", "PUT")
 	//This sets the product to inactive in the database.
-	router.HandleFunc("/product/delete/{sku}", deleteProductBySKU).Methods("POST")
+	router.HandleFunc("/product/delete/{sku}", deleteProductBySKU).Methods("POST", "DELETE")
 	//This gets the inventory values.
 	router.HandleFunc("/inventories", getInventories).Methods("GET")
+	//This streams every inventory change as it happens. Has to come before /inventory/{sku}.
+	router.HandleFunc("/inventory/stream", streamInventory).Methods("GET")
 	//This gets the inventory value.
 	router.HandleFunc("/inventory/{sku}", getInventoryBySKU).Methods("GET")
-	// This should bring back a specific Inventory.
-	//router.HandleFunc("/inventorybyid/{id}", getInventory).Methods("GET")
+	//This pages through the history of quantity changes for a product.
+	router.HandleFunc("/inventory/{sku}/movements", getInventoryMovementsBySKU).Methods("GET")
+	//This lists the reservations held against a product.
+	router.HandleFunc("/inventory/{sku}/reservations", getReservationsBySKU).Methods("GET")
+	//This holds stock of a product for an order using a Json String.
+	router.HandleFunc("/inventory/{sku}/reservations", createReservation).Methods("POST")
+	//This brings back a specific reservation.
+	router.HandleFunc("/reservations/{id}", getReservationByID).Methods("GET")
+	//This hands the stock held by a reservation back.
+	router.HandleFunc("/reservations/release/{id}", releaseReservation).Methods("POST")
+	//This takes the stock held by a reservation out of inventory.
+	router.HandleFunc("/reservations/consume/{id}", consumeReservation).Methods("POST")
 	//This allows the quantity value of a product to be set.
-	router.HandleFunc("/inventory/update/{sku}/{quantity}", updateInventoryBySKU).Methods("POST")
+	router.HandleFunc("/inventory/update/{sku}/{quantity}", updateInventoryBySKU).Methods("POST", "PUT")
 	//This allows for incrementation of a product's inventory.
-	router.HandleFunc("/inventory/increment/{sku}", incrementInventoryBySKU).Methods("POST")
+	router.HandleFunc("/inventory/increment/{sku}", incrementInventoryBySKU).Methods("POST", "PUT")
 	//This allows for decrementation of a product's inventory.
-	router.HandleFunc("/inventory/decrement/{sku}", decrementInventoryBySKU).Methods("POST")
+	router.HandleFunc("/inventory/decrement/{sku}", decrementInventoryBySKU).Methods("POST", "PUT")
+	//This adds or removes any number of units from a product's inventory in one go.
+	router.HandleFunc("/inventory/adjust/{sku}", adjustInventoryBySKU).Methods("POST")
+	//This lists the warehouses, aisles and bins stock can be kept at.
+	router.HandleFunc("/locations", getLocations).Methods("GET")
+	//This adds a location using a Json String.
+	router.HandleFunc("/locations/create", createLocation).Methods("POST")
+	//This brings back a specific location.
+	router.HandleFunc("/locations/{id}", getLocationByID).Methods("GET")
+	//This renames a location using a Json String.
+	router.HandleFunc("/locations/update/{id}", updateLocationByID).Methods("POST")
+	//This removes an empty location.
+	router.HandleFunc("/locations/delete/{id}", deleteLocationByID).Methods("POST")
+	//This lists the transfers of stock between locations.
+	router.HandleFunc("/transfers", getTransfers).Methods("GET")
+	//This moves stock from one location to another using a Json String.
+	router.HandleFunc("/transfers", createTransfer).Methods("POST")
+	//This brings back a specific transfer.
+	router.HandleFunc("/transfers/{id}", getTransferByID).Methods("GET")
+	//This takes the stock of a transfer out of its from location.
+	router.HandleFunc("/transfers/ship/{id}", shipTransfer).Methods("POST")
+	//This puts the stock of a transfer into its to location.
+	router.HandleFunc("/transfers/receive/{id}", receiveTransfer).Methods("POST")
+	//This calls off a transfer that hasn't shipped.
+	router.HandleFunc("/transfers/cancel/{id}", cancelTransfer).Methods("POST")
+	//This lists the work orders for building kits.
+	router.HandleFunc("/workorders", getWorkOrders).Methods("GET")
+	//This records a work order to build kits using a Json String.
+	router.HandleFunc("/workorders/create", createWorkOrder).Methods("POST")
+	//This brings back a specific work order.
+	router.HandleFunc("/workorders/{id}", getWorkOrderByID).Methods("GET")
+	//This reserves the components of a work order.
+	router.HandleFunc("/workorders/release/{id}", releaseWorkOrder).Methods("POST")
+	//This uses up the components of a work order and puts the finished kits into inventory.
+	router.HandleFunc("/workorders/complete/{id}", completeWorkOrder).Methods("POST")
+	//This calls off a work order and hands its components back.
+	router.HandleFunc("/workorders/cancel/{id}", cancelWorkOrder).Methods("POST")
+	//This lists the stocktakes.
+	router.HandleFunc("/stocktakes", getStocktakes).Methods("GET")
+	//This opens a stocktake using a Json String.
+	router.HandleFunc("/stocktakes/create", createStocktake).Methods("POST")
+	//This brings back a specific stocktake.
+	router.HandleFunc("/stocktakes/{id}", getStocktakeByID).Methods("GET")
+	//This compares the counts of a stocktake with the system quantities.
+	router.HandleFunc("/stocktakes/{id}/variance", getStocktakeVariance).Methods("GET")
+	//This records counted quantities against a stocktake using a Json String.
+	router.HandleFunc("/stocktakes/{id}/counts", createStocktakeCounts).Methods("POST")
+	//This adds the product with the scanned barcode to the count of a stocktake.
+	router.HandleFunc("/stocktakes/{id}/scan/{code:.+}", scanStocktake).Methods("POST")
+	//This applies the variances of a stocktake to inventory.
+	router.HandleFunc("/stocktakes/post/{id}", postStocktake).Methods("POST")
+	//This closes a stocktake without changing inventory.
+	router.HandleFunc("/stocktakes/cancel/{id}", cancelStocktake).Methods("POST")
+	//This brings back the components of a kit and how many can be built.
+	router.HandleFunc("/product/{sku}/bom", getProductBOM).Methods("GET")
+	//This replaces the components of a kit using a Json String.
+	router.HandleFunc("/product/{sku}/bom", updateProductBOM).Methods("POST")
+	//This lists the categories products are sorted into.
+	router.HandleFunc("/categories", getCategories).Methods("GET")
+	//This adds a category using a Json String.
+	router.HandleFunc("/categories/create", createCategory).Methods("POST")
+	//This brings back a specific category.
+	router.HandleFunc("/categories/{id}", getCategoryByID).Methods("GET")
+	//This renames or moves a category using a Json String.
+	router.HandleFunc("/categories/update/{id}", updateCategoryByID).Methods("POST")
+	//This removes a category without subcategories.
+	router.HandleFunc("/categories/delete/{id}", deleteCategoryByID).Methods("POST")
+	//This brings back the categories a product is in.
+	router.HandleFunc("/product/{sku}/categories", getProductCategories).Methods("GET")
+	//This replaces the categories a product is in using a Json String.
+	router.HandleFunc("/product/{sku}/categories", updateProductCategories).Methods("POST")
+	//This lists the tags in use.
+	router.HandleFunc("/tags", getTags).Methods("GET")
+	//This renames a tag on every product using a Json String.
+	router.HandleFunc("/tags/rename/{tag:.+}", renameTag).Methods("POST")
+	//This takes a tag off every product.
+	router.HandleFunc("/tags/delete/{tag:.+}", deleteTag).Methods("POST")
+	//This brings back the tags of a product.
+	router.HandleFunc("/product/{sku}/tags", getProductTags).Methods("GET")
+	//This replaces the tags of a product using a Json String.
+	router.HandleFunc("/product/{sku}/tags", updateProductTags).Methods("POST")
+	//This lists the styles products come in.
+	router.HandleFunc("/styles", getStyles).Methods("GET")
+	//This adds a style using a Json String.
+	router.HandleFunc("/styles/create", createStyle).Methods("POST")
+	//This brings back a specific style and its variants.
+	router.HandleFunc("/styles/{id}", getStyleByID).Methods("GET")
+	//This creates a product for every color, trim color and size of a style that doesn't have one.
+	router.HandleFunc("/styles/{id}/variants", generateStyleVariants).Methods("POST")
+	//This updates a style using a Json String.
+	router.HandleFunc("/styles/update/{id}", updateStyleByID).Methods("POST")
+	//This removes a style without variants.
+	router.HandleFunc("/styles/delete/{id}", deleteStyleByID).Methods("POST")
+	//This renders a printable label for a product.
+	router.HandleFunc("/product/{sku}/label", getProductLabel).Methods("GET")
+	//This lays out the labels for a list of products on sheets of label stock as a PDF.
+	router.HandleFunc("/labels/sheet", createLabelSheet).Methods("POST")
+	//This lists the barcodes of a product.
+	router.HandleFunc("/product/{sku}/barcodes", getProductBarcodes).Methods("GET")
+	//This links a barcode to a product using a Json String.
+	router.HandleFunc("/barcodes/create", createBarcode).Methods("POST")
+	//This unlinks a barcode from its product.
+	router.HandleFunc("/barcodes/delete/{code:.+}", deleteBarcode).Methods("POST")
+	//This looks up which product a barcode belongs to.
+	router.HandleFunc("/barcodes/{code:.+}", getBarcode).Methods("GET")
+	//This increments the product with the scanned barcode, like /inventory/increment.
+	router.HandleFunc("/scan/increment/{code:.+}", scanIncrement).Methods("POST")
+	//This decrements the product with the scanned barcode, like /inventory/decrement.
+	router.HandleFunc("/scan/decrement/{code:.+}", scanDecrement).Methods("POST")
+	//This opens a WebSocket session for a scanner station.
+	router.HandleFunc("/scanner", scannerSession).Methods("GET")
+	//This lists the products that have run low and need reordering.
+	router.HandleFunc("/alerts/low-stock", getLowStockAlerts).Methods("GET")
+	//This lists the registered webhooks.
+	router.HandleFunc("/webhooks", getWebhooks).Methods("GET")
+	//This registers a webhook using a Json String.
+	router.HandleFunc("/webhooks/create", createWebhook).Methods("POST")
+	//This brings back a specific webhook.
+	router.HandleFunc("/webhooks/{id}", getWebhookByID).Methods("GET")
+	//This pages through the delivery log of a webhook.
+	router.HandleFunc("/webhooks/{id}/deliveries", getWebhookDeliveries).Methods("GET")
+	//This updates a webhook using a Json String.
+	router.HandleFunc("/webhooks/update/{id}", updateWebhookByID).Methods("POST")
+	//This removes a webhook.
+	router.HandleFunc("/webhooks/delete/{id}", deleteWebhookByID).Methods("POST")
 
 	return router
 }
@@ -0,0 +1,339 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/websocket"
+)
+
+// Scanner session modes
+const (
+	// ModeReceive adds each scan to the quantity on hand
+	ModeReceive = "receive"
+	// ModePick takes each scan off the quantity on hand
+	ModePick = "pick"
+	// ModeCount tallies the scans and sets the quantities to the tallies on commit
+	ModeCount = "count"
+)
+
+// scanMessage is anything a scanner station sends. Type is hello, scan or commit.
+type scanMessage struct {
+	Type      string `json:"type"`
+	Mode      string `json:"mode,omitempty"`
+	Station   string `json:"station,omitempty"`
+	User      string `json:"user,omitempty"`
+	Location  int    `json:"location,omitempty"`
+	Stocktake int    `json:"stocktake,omitempty"`
+	Seq       int    `json:"seq,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Quantity  int    `json:"qty,omitempty"`
+}
+
+// scanReply is anything sent back. Type is session, ack or error.
+type scanReply struct {
+	Type        string `json:"type"`
+	Mode        string `json:"mode,omitempty"`
+	Location    int    `json:"location,omitempty"`
+	Stocktake   int    `json:"stocktake,omitempty"`
+	Seq         int    `json:"seq,omitempty"`
+	Code        string `json:"code,omitempty"`
+	SKU         int    `json:"sku,omitempty"`
+	ProductName string `json:"productname,omitempty"`
+	Delta       int    `json:"delta,omitempty"`
+	Quantity    int    `json:"quantity"`
+	Counted     int    `json:"counted,omitempty"`
+	MovementID  int    `json:"movementid,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	// Same as the Access-Control-Allow-Origin: * on every other route
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// scanSession is the state of one scanner connection
+type scanSession struct {
+	conn      *websocket.Conn
+	mode      string
+	station   string
+	user      string
+	location  int
+	stocktake int            // in count mode, the stocktake the scans are recorded against
+	names     map[int]string // product names already looked up
+	counts    map[int]int    // tallies in count mode, by SKU
+}
+
+const scannerPongWait = 60 * time.Second
+
+// Opens a WebSocket scanner session. The station first sends {"type": "hello", "mode": "receive",
+// "station": "dock-1", "user": "sam", "location": 3} with mode receive, pick or count (location defaults
+// to the default location), then one {"type": "scan", "seq": 1, "code": "7", "qty": 1} per scan
+// (qty defaults to 1) and gets an ack or error with the same seq back for each. In count mode the scans
+// are only tallied, {"type": "commit"} sets every counted SKU to its tally, unless hello named a "stocktake"
+// to record them against instead. Sending hello again switches mode, dropping the tallies unless it stays
+// the same count.
+func scannerSession(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println("scanner.go - scannerSession - upgrade error")
+		fmt.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	// Browsers and most scanner SDKs answer pings, a station that stops answering is hung up on
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(scannerPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(scannerPongWait))
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(scannerPongWait / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	s := &scanSession{conn: conn, names: make(map[int]string), counts: make(map[int]int)}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(scannerPongWait))
+
+		var msg scanMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.reply(scanReply{Type: "error", Error: "Invalid JSON message."})
+			continue
+		}
+
+		switch msg.Type {
+		case "hello":
+			s.hello(msg)
+		case "scan":
+			s.scan(msg)
+		case "commit":
+			s.commit(msg)
+		default:
+			s.reply(scanReply{Type: "error", Seq: msg.Seq, Error: fmt.Sprintf("Unknown message type %q.", msg.Type)})
+		}
+	}
+}
+
+func (s *scanSession) reply(r scanReply) {
+	if err := s.conn.WriteJSON(r); err != nil {
+		fmt.Println("scanner.go - reply - write error")
+		fmt.Println(err)
+	}
+}
+
+func (s *scanSession) hello(msg scanMessage) {
+	switch msg.Mode {
+	case ModeReceive, ModePick, ModeCount:
+	default:
+		s.reply(scanReply{Type: "error", Error: "mode must be receive, pick or count."})
+		return
+	}
+	if msg.Location == 0 {
+		msg.Location = models.DefaultLocation
+	}
+	if _, err := inventories.GetLocation(msg.Location); err != nil {
+		s.reply(scanReply{Type: "error", Error: "Location not found."})
+		return
+	}
+	if msg.Stocktake != 0 {
+		if msg.Mode != ModeCount || stocktakes == nil {
+			s.reply(scanReply{Type: "error", Error: "Only count sessions can join a stocktake."})
+			return
+		}
+		if _, err := stocktakes.GetStocktake(msg.Stocktake); err != nil {
+			s.reply(scanReply{Type: "error", Error: "Stocktake not found."})
+			return
+		}
+	}
+	if msg.Mode != s.mode || msg.Location != s.location || msg.Stocktake != s.stocktake {
+		// tallies belong to the count they were made in, committing them after picks or receipts at the
+		// same shelf would overwrite those
+		s.counts = make(map[int]int)
+	}
+	s.mode, s.station, s.user, s.location, s.stocktake = msg.Mode, msg.Station, msg.User, msg.Location, msg.Stocktake
+	s.reply(scanReply{Type: "session", Mode: s.mode, Location: s.location, Stocktake: s.stocktake})
+}
+
+func (s *scanSession) scan(msg scanMessage) {
+	ack := scanReply{Type: "ack", Seq: msg.Seq, Code: msg.Code}
+	fail := func(e string) {
+		ack.Type, ack.Error = "error", e
+		s.reply(ack)
+	}
+
+	if s.mode == "" {
+		fail("Send hello with a mode first.")
+		return
+	}
+	qty := msg.Quantity
+	if qty == 0 {
+		qty = 1
+	}
+	if qty < 0 {
+		fail("qty must be positive.")
+		return
+	}
+	sku, err := resolveCode(msg.Code)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	ack.SKU = sku
+	if ack.ProductName, err = s.productName(sku); err != nil {
+		fail("Product not found.")
+		return
+	}
+
+	if s.stocktake != 0 {
+		c := &models.StocktakeCount{SKU: sku, LocationID: s.location, Counted: qty, User: s.user}
+		switch err := stocktakes.RecordCounts(s.stocktake, []*models.StocktakeCount{c}, true); err {
+		case nil:
+		case models.ErrStocktakeClosed:
+			fail("The stocktake is no longer open.")
+			return
+		case models.ErrNotInStocktake:
+			fail("Not part of this stocktake.")
+			return
+		default:
+			fmt.Println("scanner.go - scan - RecordCounts error for sku: " + strconv.Itoa(sku))
+			fmt.Println(err)
+			fail("Unable to record the count.")
+			return
+		}
+		ack.Counted, ack.Quantity = c.Counted, c.Expected
+		s.reply(ack)
+		return
+	}
+
+	if s.mode == ModeCount {
+		inv, err := inventories.GetInventory(sku)
+		if err != nil {
+			fail("Product not found.")
+			return
+		}
+		s.counts[sku] += qty
+		ack.Counted = s.counts[sku]
+		for _, i := range inv {
+			if i.LocationID == s.location {
+				ack.Quantity = i.Quantity
+			}
+		}
+		s.reply(ack)
+		return
+	}
+
+	m := s.movement(models.ReasonReceive)
+	delta := qty
+	if s.mode == ModePick {
+		m.Reason, delta = models.ReasonSale, -qty
+	}
+	if err := inventories.AdjustInventory(sku, s.location, delta, m); err != nil {
+		if stockErr, ok := err.(*models.StockError); ok {
+			ack.Quantity = stockErr.Quantity
+			fail(fmt.Sprintf("Only %v on hand.", stockErr.Quantity))
+			return
+		}
+		fmt.Println("scanner.go - scan - AdjustInventory error for sku: " + strconv.Itoa(sku))
+		fmt.Println(err)
+		fail("Unable to update inventory.")
+		return
+	}
+	inventoryChanged(m)
+	ack.Delta, ack.Quantity, ack.MovementID = m.Delta, m.Quantity, m.MovementID
+	s.reply(ack)
+}
+
+// commit sets every SKU counted since the last commit to its tally, acking each in SKU order
+func (s *scanSession) commit(msg scanMessage) {
+	if s.mode != ModeCount {
+		s.reply(scanReply{Type: "error", Seq: msg.Seq, Error: "Only count sessions can commit."})
+		return
+	}
+	if s.stocktake != 0 {
+		s.reply(scanReply{Type: "error", Seq: msg.Seq, Error: "Post the stocktake to apply its counts."})
+		return
+	}
+	skus := make([]int, 0, len(s.counts))
+	for sku := range s.counts {
+		skus = append(skus, sku)
+	}
+	sort.Ints(skus)
+	for _, sku := range skus {
+		ack := scanReply{Type: "ack", Seq: msg.Seq, SKU: sku, ProductName: s.names[sku], Counted: s.counts[sku]}
+		m := s.movement(models.ReasonCountCorrection)
+		if err := inventories.SetInventory(sku, s.location, s.counts[sku], m); err != nil {
+			fmt.Println("scanner.go - commit - SetInventory error for sku: " + strconv.Itoa(sku))
+			fmt.Println(err)
+			ack.Type, ack.Error = "error", "Unable to update inventory."
+			s.reply(ack)
+			continue
+		}
+		inventoryChanged(m)
+		delete(s.counts, sku)
+		ack.Delta, ack.Quantity, ack.MovementID = m.Delta, m.Quantity, m.MovementID
+		s.reply(ack)
+	}
+}
+
+func (s *scanSession) movement(reason string) *models.InventoryMovement {
+	m := &models.InventoryMovement{Reason: reason, User: s.user}
+	if s.station != "" {
+		m.Note = "scanner " + s.station
+	}
+	return m
+}
+
+func (s *scanSession) productName(sku int) (string, error) {
+	if name, ok := s.names[sku]; ok {
+		return name, nil
+	}
+	p, err := products.GetProduct(sku)
+	if err != nil {
+		return "", err
+	}
+	s.names[sku] = p.ProductName
+	return p.ProductName, nil
+}
+
+// resolveCode turns a scanned code into a product SKU. Known barcodes win, otherwise a number is taken
+// to be the SKU itself, so labels printed with the SKU keep working.
+func resolveCode(code string) (int, error) {
+	code = strings.TrimSpace(code)
+	b, err := products.FindBarcode(code)
+	if err == nil {
+		return b.SKU, nil
+	}
+	if err != models.ErrNotFound {
+		fmt.Println("scanner.go - resolveCode - FindBarcode error for code: " + code)
+		fmt.Println(err)
+		return 0, fmt.Errorf("Unable to look up code %q.", code)
+	}
+	sku, _ := strconv.Atoi(code)
+	if sku < 1 {
+		return 0, fmt.Errorf("Unknown code %q.", code)
+	}
+	return sku, nil
+}
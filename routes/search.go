@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// searchEnabled writes a 404 when the product store can't search products
+func searchEnabled(w http.ResponseWriter) bool {
+	if searches == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Product search is not enabled"))
+		return false
+	}
+	return true
+}
+
+// Returns the products whose name, color, trim color, size or dimensions match the words of ?q=, best
+// match first with its score, so "red swing large" finds the large red swing without its SKU. Words can
+// be cut short or have a typo in them. ?limit= caps the matches, 20 by default and 100 at most.
+func searchProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !searchEnabled(w) {
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Please include something to search for in q."))
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - limit must be between 1 and 100."))
+			return
+		}
+		limit = n
+	}
+
+	matches, err := searches.SearchProducts(q, limit)
+	if err != nil {
+		fmt.Println("search.go - searchProducts - SearchProducts error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to search products"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(matches)
+}
@@ -0,0 +1,355 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/mux"
+)
+
+// stocktakesEnabled writes a 404 when the inventory store can't run stocktakes
+func stocktakesEnabled(w http.ResponseWriter) bool {
+	if stocktakes == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Stocktakes are not enabled"))
+		return false
+	}
+	return true
+}
+
+// stocktakeID reads the {id} route variable, writing a 400 if it isn't a valid ID
+func stocktakeID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid stocktake ID."))
+		return 0, false
+	}
+	return id, true
+}
+
+// loadStocktake reads the stocktake named by the {id} route variable, writing a 404 if there isn't one
+func loadStocktake(w http.ResponseWriter, r *http.Request) (*models.Stocktake, bool) {
+	id, ok := stocktakeID(w, r)
+	if !ok {
+		return nil, false
+	}
+	st, err := stocktakes.GetStocktake(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Stocktake not found"))
+		return nil, false
+	}
+	return st, true
+}
+
+// Returns a page of stocktakes, newest first. ?status= narrows it down, ?limit= and ?offset= page
+// through it.
+func getStocktakes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stocktakesEnabled(w) {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && !models.ValidStocktakeStatus(status) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Unknown status."))
+		return
+	}
+	limit, offset, ok := pageFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := stocktakes.ListStocktakes(status, limit, offset)
+	if err != nil {
+		fmt.Println("stocktake.go - getStocktakes - ListStocktakes error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load stocktakes"))
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// Opens a stocktake from the passed in JSON {"location", "skus", "note", "user"}. Leave location out to
+// count every location and skus out to count every product.
+func createStocktake(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stocktakesEnabled(w) {
+		return
+	}
+	var body struct {
+		Location int    `json:"location"`
+		SKUs     []int  `json:"skus"`
+		Note     string `json:"note"`
+		User     string `json:"user"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid JSON body."))
+			return
+		}
+	}
+	if body.Location < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid location."))
+		return
+	}
+	for _, sku := range body.SKUs {
+		if sku < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid product SKU."))
+			return
+		}
+	}
+
+	st := &models.Stocktake{LocationID: body.Location, SKUs: body.SKUs, Note: body.Note, User: body.User}
+	if err := stocktakes.CreateStocktake(st); err != nil {
+		writeInventoryResult(w, "(new stocktake)", err)
+		return
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// Returns a specific stocktake in JSON format
+func getStocktakeByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stocktakesEnabled(w) {
+		return
+	}
+	st, ok := loadStocktake(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// Returns the counted lines of a stocktake against the system quantities, and while it is open the
+// stock in its scope that is still to be counted.
+func getStocktakeVariance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stocktakesEnabled(w) {
+		return
+	}
+	id, ok := stocktakeID(w, r)
+	if !ok {
+		return
+	}
+	report, err := stocktakes.Variance(id)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Stocktake not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("stocktake.go - getStocktakeVariance - Variance error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load variance report"))
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// Records counted quantities from the passed in JSON {"counts": [{"sku", "location", "counted"}], "user"}.
+// A count replaces any earlier count of the same SKU at the same location, the location defaults to the
+// stocktake's own, or the default location when it counts every location. Responds with the counts.
+func createStocktakeCounts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stocktakesEnabled(w) {
+		return
+	}
+	st, ok := loadStocktake(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Counts []struct {
+			SKU      int  `json:"sku"`
+			Location int  `json:"location"`
+			Counted  *int `json:"counted"`
+		} `json:"counts"`
+		User string `json:"user"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+	if len(body.Counts) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Please include at least one count."))
+		return
+	}
+	counts := make([]*models.StocktakeCount, 0, len(body.Counts))
+	for _, c := range body.Counts {
+		if c.SKU < 1 || c.Location < 0 || c.Counted == nil || *c.Counted < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Every count needs a sku and a counted quantity of 0 or more."))
+			return
+		}
+		counts = append(counts, &models.StocktakeCount{SKU: c.SKU, LocationID: countLocation(st, c.Location), Counted: *c.Counted, User: body.User})
+	}
+
+	if !writeStocktakeResult(w, st.StocktakeID, stocktakes.RecordCounts(st.StocktakeID, counts, false)) {
+		return
+	}
+	json.NewEncoder(w).Encode(counts)
+}
+
+// Adds a scanned item to the count of a stocktake, like /scan/increment. Takes an optional JSON body
+// {"quantity", "location", "user"}, quantity defaults to 1 and location to ?location=, then the same
+// default as the counts route. Responds with the count so far.
+func scanStocktake(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stocktakesEnabled(w) {
+		return
+	}
+	st, ok := loadStocktake(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Quantity int    `json:"quantity"`
+		Location int    `json:"location"`
+		User     string `json:"user"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid JSON body."))
+			return
+		}
+	}
+	if body.Quantity == 0 {
+		body.Quantity = 1
+	}
+	if body.Quantity < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - quantity must be positive."))
+		return
+	}
+	// Scanners that can't send a body name the location in the URL instead
+	if v := r.URL.Query().Get("location"); body.Location == 0 && v != "" {
+		body.Location, _ = strconv.Atoi(v)
+		if body.Location < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid location."))
+			return
+		}
+	}
+	if body.Location < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid location."))
+		return
+	}
+	code := mux.Vars(r)["code"]
+	sku, err := resolveCode(code)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - " + err.Error()))
+		return
+	}
+
+	c := &models.StocktakeCount{SKU: sku, LocationID: countLocation(st, body.Location), Counted: body.Quantity, User: body.User}
+	if !writeStocktakeResult(w, st.StocktakeID, stocktakes.RecordCounts(st.StocktakeID, []*models.StocktakeCount{c}, true)) {
+		return
+	}
+	json.NewEncoder(w).Encode(c)
+}
+
+// countLocation is where a count without a location of its own was made
+func countLocation(st *models.Stocktake, location int) int {
+	switch {
+	case location != 0:
+		return location
+	case st.LocationID != 0:
+		return st.LocationID
+	}
+	return models.DefaultLocation
+}
+
+// Applies the variance of every counted line of a stocktake to inventory with reason count-correction,
+// all or nothing, and closes it. Takes an optional {"user"} body.
+func postStocktake(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stocktakesEnabled(w) {
+		return
+	}
+	id, ok := stocktakeID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		User string `json:"user"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid JSON body."))
+			return
+		}
+	}
+
+	st, moves, err := stocktakes.PostStocktake(id, body.User)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Stocktake not found"))
+		return
+	}
+	if !writeStocktakeResult(w, id, err) {
+		return
+	}
+	for _, m := range moves {
+		inventoryChanged(m)
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// Closes a stocktake without changing inventory
+func cancelStocktake(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stocktakesEnabled(w) {
+		return
+	}
+	id, ok := stocktakeID(w, r)
+	if !ok {
+		return
+	}
+	st, err := stocktakes.CancelStocktake(id)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Stocktake not found"))
+		return
+	}
+	if !writeStocktakeResult(w, id, err) {
+		return
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// writeStocktakeResult writes the response for a failed count, post or cancel, reporting whether the
+// stocktake changed. A not found here is the product, the stocktake has been looked up already.
+func writeStocktakeResult(w http.ResponseWriter, id int, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case models.ErrStocktakeClosed:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Stocktake is no longer open"))
+	case models.ErrNotInStocktake:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Product or location is not part of this stocktake"))
+	default:
+		// a stock error when posting, or an unknown product or location
+		writeInventoryResult(w, "(stocktake "+strconv.Itoa(id)+")", err)
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+)
+
+// broker fans the movements recorded by this process out to the open /inventory/stream connections
+type broker struct {
+	mu   sync.Mutex
+	subs map[chan *models.InventoryMovement]bool
+	last int // the highest MovementID published so far
+}
+
+var changes = &broker{subs: make(map[chan *models.InventoryMovement]bool)}
+
+// subscribe also returns the highest MovementID published before it, those movements don't come
+// through ch.
+func (b *broker) subscribe() (chan *models.InventoryMovement, int) {
+	ch := make(chan *models.InventoryMovement, 64)
+	b.mu.Lock()
+	b.subs[ch] = true
+	last := b.last
+	b.mu.Unlock()
+	return ch, last
+}
+
+func (b *broker) unsubscribe(ch chan *models.InventoryMovement) {
+	b.mu.Lock()
+	if b.subs[ch] {
+		delete(b.subs, ch)
+		close(ch)
+	}
+	b.mu.Unlock()
+}
+
+// publish never blocks a handler on a slow client. A client that has fallen a whole buffer behind is
+// dropped instead, it reconnects with Last-Event-ID and catches up from the movement ledger.
+func (b *broker) publish(m *models.InventoryMovement) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if m.MovementID > b.last {
+		b.last = m.MovementID
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- m:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// streamHeartbeat is how often an idle stream sends a comment, so proxies don't time the connection out
+var streamHeartbeat = 15 * time.Second
+
+// Streams every inventory change as a Server-Sent Event. ?sku=1,2 limits the stream to those SKUs. The
+// event ID is the MovementID, so a client reconnecting with Last-Event-ID (or ?lastEventId=) is first
+// sent every change it missed from the movement ledger.
+func streamInventory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Streaming not supported"))
+		return
+	}
+
+	skus := make(map[int]bool)
+	for _, v := range r.URL.Query()["sku"] {
+		for _, s := range strings.Split(v, ",") {
+			sku, _ := strconv.Atoi(s)
+			if sku < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("400 - Invalid product SKU."))
+				return
+			}
+			skus[sku] = true
+		}
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	last := 0
+	if lastID != "" {
+		n, err := strconv.Atoi(lastID)
+		if err != nil || n < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid Last-Event-ID."))
+			return
+		}
+		last = n
+	}
+
+	// Subscribe before replaying so nothing recorded in between is missed, duplicates are skipped by ID
+	ch, published := changes.subscribe()
+	defer changes.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(m *models.InventoryMovement) {
+		if len(skus) > 0 && !skus[m.SKU] {
+			return
+		}
+		data, _ := json.Marshal(m)
+		fmt.Fprintf(w, "id: %v\nevent: inventory\ndata: %s\n\n", m.MovementID, data)
+	}
+
+	// IDs the replay sent that can come in live as well. What was published before subscribing can't,
+	// so only the movements recorded since are kept.
+	replayed := make(map[int]bool)
+	if lastID != "" {
+		for {
+			moves, err := inventories.MovementsSince(last, 500)
+			if err != nil {
+				fmt.Println("stream.go - streamInventory - MovementsSince error")
+				fmt.Println(err)
+				return
+			}
+			for _, m := range moves {
+				send(m)
+				if m.MovementID > published {
+					replayed[m.MovementID] = true
+				}
+				last = m.MovementID
+			}
+			if len(moves) < 500 {
+				break
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			// Skip only what the replay sent. Commits can land out of ID order, so a movement with a
+			// lower ID than the last one replayed may still be new to the client. Once the live
+			// movements have gone past the replay nothing it sent is still to come.
+			if replayed[m.MovementID] {
+				delete(replayed, m.MovementID)
+				continue
+			}
+			if m.MovementID > last {
+				replayed = nil
+			}
+			send(m)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,248 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/webhooks"
+	"../models"
+	"../webhooks"
+	"github.com/gorilla/mux"
+)
+
+// stylesEnabled writes a 404 when the product store can't keep styles
+func stylesEnabled(w http.ResponseWriter) bool {
+	if styles == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Styles are not enabled"))
+		return false
+	}
+	return true
+}
+
+// styleID reads the {id} route variable, writing a 400 if it isn't a valid ID
+func styleID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid style ID."))
+		return 0, false
+	}
+	return id, true
+}
+
+// styleFromRequest decodes and checks the style in the request body
+func styleFromRequest(r *http.Request) (*models.Style, error) {
+	st := new(models.Style)
+	if err := json.NewDecoder(r.Body).Decode(st); err != nil {
+		return nil, fmt.Errorf("Invalid JSON body.")
+	}
+	st.StyleName = strings.TrimSpace(st.StyleName)
+	if st.StyleName == "" {
+		return nil, fmt.Errorf("Please include a stylename.")
+	}
+	if !models.ValidNegativeStock(st.NegativeStock) {
+		return nil, fmt.Errorf("negativestock must be reject, allow or clamp.")
+	}
+	for _, values := range []*[]string{&st.Colors, &st.TrimColors, &st.Sizes} {
+		seen := make(map[string]bool)
+		for i, v := range *values {
+			v = strings.TrimSpace(v)
+			if v == "" || seen[v] {
+				return nil, fmt.Errorf("Colors, trim colors and sizes can't be blank or listed twice.")
+			}
+			seen[v] = true
+			(*values)[i] = v
+		}
+	}
+	return st, nil
+}
+
+// Returns every style with its colors, trim colors and sizes in JSON format
+func getStyles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stylesEnabled(w) {
+		return
+	}
+	list, err := styles.ListStyles()
+	if err != nil {
+		fmt.Println("style.go - getStyles - ListStyles error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load styles"))
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// Returns a specific style and its variants in JSON format
+func getStyleByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stylesEnabled(w) {
+		return
+	}
+	id, ok := styleID(w, r)
+	if !ok {
+		return
+	}
+	st, err := styles.GetStyle(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Style not found"))
+		return
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// Adds a style from the passed in JSON and returns it with its new ID. No products are made until its
+// variants are generated.
+func createStyle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stylesEnabled(w) {
+		return
+	}
+	st, err := styleFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+	if err := styles.CreateStyle(st); err != nil {
+		fmt.Println("style.go - createStyle - CreateStyle error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save style"))
+		return
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// Updates the style with the given ID. Every field is overwritten, its existing variants stay as they are.
+func updateStyleByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stylesEnabled(w) {
+		return
+	}
+	id, ok := styleID(w, r)
+	if !ok {
+		return
+	}
+	st, err := styleFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+
+	err = styles.UpdateStyle(id, st)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case models.ErrStyleNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Style not found"))
+	default:
+		fmt.Println("style.go - updateStyleByID - UpdateStyle error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save style"))
+	}
+}
+
+// Removes the style with the given ID. Only a style without variants can go.
+func deleteStyleByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stylesEnabled(w) {
+		return
+	}
+	id, ok := styleID(w, r)
+	if !ok {
+		return
+	}
+
+	err := styles.DeleteStyle(id)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"deleted": "true"}`))
+	case models.ErrStyleNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Style not found"))
+	case models.ErrInUse:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Style still has variants"))
+	default:
+		fmt.Println("style.go - deleteStyleByID - DeleteStyle error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to delete style"))
+	}
+}
+
+// Creates a product for every combination of the style's colors, trim colors and sizes that doesn't have
+// one yet, with SKUs assigned from the highest one in use, and returns the new products. Running it again
+// after adding a color only makes the products for that color.
+func generateStyleVariants(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !stylesEnabled(w) {
+		return
+	}
+	id, ok := styleID(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := styles.GenerateVariants(id)
+	if err == models.ErrStyleNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Style not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("style.go - generateStyleVariants - GenerateVariants error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to create variants"))
+		return
+	}
+	for _, p := range created {
+		publish(webhooks.ProductCreated, p)
+	}
+	json.NewEncoder(w).Encode(created)
+}
+
+// writeGroupedProducts writes prods grouped by style for GET /product?group=style: every style with its
+// variants, and the products that aren't a variant of any on their own.
+func writeGroupedProducts(w http.ResponseWriter, prods []*models.Product) {
+	if !stylesEnabled(w) {
+		return
+	}
+	list, err := styles.ListStyles()
+	if err != nil {
+		fmt.Println("style.go - writeGroupedProducts - ListStyles error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load styles"))
+		return
+	}
+	byID := make(map[int]*models.Style)
+	for _, st := range list {
+		byID[st.StyleID] = st
+	}
+	ungrouped := make([]*models.Product, 0)
+	for _, p := range prods {
+		if st := byID[p.StyleID]; st != nil {
+			st.Variants = append(st.Variants, p)
+		} else {
+			ungrouped = append(ungrouped, p)
+		}
+	}
+	json.NewEncoder(w).Encode(struct {
+		Styles   []*models.Style   `json:"styles"`
+		Products []*models.Product `json:"products"`
+	}{list, ungrouped})
+}
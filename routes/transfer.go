@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/mux"
+)
+
+// transfersEnabled writes a 404 when the inventory store can't move stock between locations
+func transfersEnabled(w http.ResponseWriter) bool {
+	if transfers == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Transfers are not enabled"))
+		return false
+	}
+	return true
+}
+
+// transferFromRequest decodes and checks the transfer in the request body
+func transferFromRequest(r *http.Request) (*models.Transfer, error) {
+	t := new(models.Transfer)
+	if err := json.NewDecoder(r.Body).Decode(t); err != nil {
+		return nil, fmt.Errorf("Invalid JSON body.")
+	}
+	if t.SKU < 1 {
+		return nil, fmt.Errorf("Please include a sku.")
+	}
+	if t.Quantity < 1 {
+		return nil, fmt.Errorf("Please include a quantity of at least 1.")
+	}
+	if t.From < 1 || t.To < 1 || t.From == t.To {
+		return nil, fmt.Errorf("Please include two different locations to transfer from and to.")
+	}
+	// Only the statuses a new transfer can be moved on to
+	switch t.Status {
+	case "", models.TransferRequested, models.TransferInTransit, models.TransferReceived:
+	default:
+		return nil, fmt.Errorf("A new transfer can't be %q.", t.Status)
+	}
+	return t, nil
+}
+
+// transferID reads the {id} route variable, writing a 400 if it isn't a valid ID
+func transferID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid transfer ID."))
+		return 0, false
+	}
+	return id, true
+}
+
+// Returns a page of transfers, newest first. ?status= and ?sku= narrow it down, ?limit= and ?offset=
+// page through it.
+func getTransfers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !transfersEnabled(w) {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && !models.ValidTransferStatus(status) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Unknown status."))
+		return
+	}
+	sku := 0
+	if v := r.URL.Query().Get("sku"); v != "" {
+		sku, _ = strconv.Atoi(v)
+		if sku < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid product SKU."))
+			return
+		}
+	}
+	limit, offset, ok := pageFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := transfers.ListTransfers(status, sku, limit, offset)
+	if err != nil {
+		fmt.Println("transfer.go - getTransfers - ListTransfers error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load transfers"))
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// Returns a specific transfer in JSON format
+func getTransferByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !transfersEnabled(w) {
+		return
+	}
+	id, ok := transferID(w, r)
+	if !ok {
+		return
+	}
+	t, err := transfers.GetTransfer(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Transfer not found"))
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// Records a transfer of stock between two locations from the passed in JSON. It starts out requested
+// unless the body asks for in-transit (ship it now) or received (move it now, e.g. between two bins),
+// either way the whole step happens in one transaction.
+func createTransfer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !transfersEnabled(w) {
+		return
+	}
+	t, err := transferFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+
+	moves, err := transfers.CreateTransfer(t)
+	if err != nil {
+		writeInventoryResult(w, strconv.Itoa(t.SKU), err)
+		return
+	}
+	for _, m := range moves {
+		inventoryChanged(m)
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// Takes the stock of a requested transfer out of its from location
+func shipTransfer(w http.ResponseWriter, r *http.Request) {
+	updateTransferStatus(w, r, models.TransferInTransit)
+}
+
+// Puts the stock of a transfer into its to location, shipping it first if it hasn't been
+func receiveTransfer(w http.ResponseWriter, r *http.Request) {
+	updateTransferStatus(w, r, models.TransferReceived)
+}
+
+// Calls off a transfer that hasn't shipped yet
+func cancelTransfer(w http.ResponseWriter, r *http.Request) {
+	updateTransferStatus(w, r, models.TransferCancelled)
+}
+
+// updateTransferStatus moves the transfer on to status, recording the optional {"user"} from the body
+// against the movements it makes
+func updateTransferStatus(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !transfersEnabled(w) {
+		return
+	}
+	id, ok := transferID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		User string `json:"user"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid JSON body."))
+			return
+		}
+	}
+
+	t, moves, err := transfers.UpdateTransferStatus(id, status, body.User)
+	switch err {
+	case nil:
+	case models.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Transfer not found"))
+		return
+	case models.ErrTransferStatus:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Transfer can't be " + status + " from its current status"))
+		return
+	default:
+		// a stock error when shipping, or the location has gone
+		writeInventoryResult(w, "(transfer "+strconv.Itoa(id)+")", err)
+		return
+	}
+	for _, m := range moves {
+		inventoryChanged(m)
+	}
+	json.NewEncoder(w).Encode(t)
+}
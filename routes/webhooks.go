@@ -0,0 +1,220 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/webhooks"
+	"../models"
+	"../webhooks"
+	"github.com/gorilla/mux"
+)
+
+// publish hands an event to the webhook dispatcher, if there is one
+func publish(event string, data interface{}) {
+	if hooks != nil {
+		hooks.Publish(event, data)
+	}
+}
+
+// webhookFromRequest decodes and checks the webhook in the request body
+func webhookFromRequest(r *http.Request) (*models.Webhook, error) {
+	h := &models.Webhook{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(h); err != nil {
+		return nil, fmt.Errorf("Invalid JSON body.")
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("Please include an http or https url.")
+	}
+	for _, e := range h.Events {
+		if !webhooks.ValidEvent(e) {
+			return nil, fmt.Errorf("Unknown event %q.", e)
+		}
+	}
+	if h.Events == nil {
+		h.Events = []string{}
+	}
+	return h, nil
+}
+
+// webhookID reads the {id} route variable, writing a 400 if it isn't a valid ID
+func webhookID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid webhook ID."))
+		return 0, false
+	}
+	return id, true
+}
+
+// webhooksEnabled writes a 404 when the server was started without a webhook store
+func webhooksEnabled(w http.ResponseWriter) bool {
+	if hooks == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Webhooks are not enabled"))
+		return false
+	}
+	return true
+}
+
+// Returns every registered webhook in JSON format. Secrets are only returned when the webhook is
+// created, anyone can call these routes.
+func getWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !webhooksEnabled(w) {
+		return
+	}
+	list, err := hooks.Store.ListWebhooks()
+	if err != nil {
+		fmt.Println("webhooks.go - getWebhooks - ListWebhooks error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load webhooks"))
+		return
+	}
+	for _, h := range list {
+		h.Secret = ""
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// Returns a specific webhook in JSON format, without its secret
+func getWebhookByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !webhooksEnabled(w) {
+		return
+	}
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	h, err := hooks.Store.GetWebhook(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Webhook not found"))
+		return
+	}
+	h.Secret = ""
+	json.NewEncoder(w).Encode(h)
+}
+
+// Registers a webhook from the passed in JSON. A secret is generated when none is given, either way
+// it is returned so the receiver can be set up to check signatures.
+func createWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !webhooksEnabled(w) {
+		return
+	}
+	h, err := webhookFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+	if h.Secret == "" {
+		h.Secret = webhooks.NewSecret()
+	}
+	if err := hooks.Store.CreateWebhook(h); err != nil {
+		fmt.Println("webhooks.go - createWebhook - CreateWebhook error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save webhook"))
+		return
+	}
+	json.NewEncoder(w).Encode(h)
+}
+
+// Updates the webhook with the given ID. Every field is overwritten, leaving out the secret keeps the old one.
+func updateWebhookByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !webhooksEnabled(w) {
+		return
+	}
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	h, err := webhookFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+	if h.Secret == "" {
+		old, err := hooks.Store.GetWebhook(id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404 - Webhook not found"))
+			return
+		}
+		h.Secret = old.Secret
+	}
+
+	err = hooks.Store.UpdateWebhook(id, h)
+	if err == models.ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Webhook not found"))
+		return
+	}
+	if err != nil {
+		fmt.Println("webhooks.go - updateWebhookByID - UpdateWebhook error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save webhook"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Removes the webhook with the given ID. Like products it is only flagged, so its delivery log stays readable.
+func deleteWebhookByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !webhooksEnabled(w) {
+		return
+	}
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	if err := hooks.Store.DeleteWebhook(id); err != nil {
+		fmt.Println("webhooks.go - deleteWebhookByID - DeleteWebhook error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Webhook not found"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"deleted": "true"}`))
+}
+
+// Returns a page of the delivery log for the given webhook, newest first. ?limit= (default 50, at most
+// 500) and ?offset= page through it.
+func getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !webhooksEnabled(w) {
+		return
+	}
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageFromRequest(w, r)
+	if !ok {
+		return
+	}
+	deliveries, err := hooks.Store.ListDeliveries(id, limit, offset)
+	if err != nil {
+		fmt.Println("webhooks.go - getWebhookDeliveries - ListDeliveries error for id: " + strconv.Itoa(id))
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load deliveries"))
+		return
+	}
+	json.NewEncoder(w).Encode(deliveries)
+}
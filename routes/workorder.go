@@ -0,0 +1,227 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	// "github.com/Xero67/web-fire-family/models"
+	"../models"
+	"github.com/gorilla/mux"
+)
+
+// workOrdersEnabled writes a 404 when the inventory store can't build kits
+func workOrdersEnabled(w http.ResponseWriter) bool {
+	if workOrders == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Work orders are not enabled"))
+		return false
+	}
+	return true
+}
+
+// workOrderID reads the {id} route variable, writing a 400 if it isn't a valid ID
+func workOrderID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid work order ID."))
+		return 0, false
+	}
+	return id, true
+}
+
+// Returns a page of work orders, newest first. ?status= and ?sku= narrow it down, ?limit= and ?offset=
+// page through it.
+func getWorkOrders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !workOrdersEnabled(w) {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && !models.ValidWorkOrderStatus(status) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Unknown status."))
+		return
+	}
+	sku := 0
+	if v := r.URL.Query().Get("sku"); v != "" {
+		sku, _ = strconv.Atoi(v)
+		if sku < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid product SKU."))
+			return
+		}
+	}
+	limit, offset, ok := pageFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := workOrders.ListWorkOrders(status, sku, limit, offset)
+	if err != nil {
+		fmt.Println("workorder.go - getWorkOrders - ListWorkOrders error")
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to load work orders"))
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// Returns a specific work order and its components in JSON format
+func getWorkOrderByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !workOrdersEnabled(w) {
+		return
+	}
+	id, ok := workOrderID(w, r)
+	if !ok {
+		return
+	}
+	o, err := workOrders.GetWorkOrder(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Work order not found"))
+		return
+	}
+	json.NewEncoder(w).Encode(o)
+}
+
+// Records a work order to build kits from the passed in JSON {"sku", "quantity", "location", "note",
+// "user"}, with the components of the kit's bill of materials. The location defaults to ?location=, then
+// the default location. It starts out a draft unless "status" asks for released or completed, either
+// way the whole step happens in one transaction.
+func createWorkOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !workOrdersEnabled(w) {
+		return
+	}
+	var body struct {
+		SKU      int    `json:"sku"`
+		Quantity int    `json:"quantity"`
+		Location int    `json:"location"`
+		Status   string `json:"status"`
+		Note     string `json:"note"`
+		User     string `json:"user"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid JSON body."))
+		return
+	}
+	if body.SKU < 1 || body.Quantity < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Please include a sku and a quantity of at least 1."))
+		return
+	}
+	if v := r.URL.Query().Get("location"); body.Location == 0 && v != "" {
+		body.Location, _ = strconv.Atoi(v)
+	}
+	if body.Location == 0 {
+		body.Location = models.DefaultLocation
+	}
+	if body.Location < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Invalid location."))
+		return
+	}
+	// Only the statuses a new work order can be moved on to
+	switch body.Status {
+	case "", models.WorkOrderDraft, models.WorkOrderReleased, models.WorkOrderCompleted:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("400 - A new work order can't be %q.", body.Status)))
+		return
+	}
+
+	o := &models.WorkOrder{SKU: body.SKU, Quantity: body.Quantity, LocationID: body.Location, Status: body.Status, Note: body.Note, User: body.User}
+	moves, err := workOrders.CreateWorkOrder(o)
+	if err == models.ErrNoBOM {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Product has no bill of materials."))
+		return
+	}
+	if err == models.ErrComponentArchived {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - A component of the kit is archived, change its bill of materials first."))
+		return
+	}
+	if err != nil {
+		writeInventoryResult(w, strconv.Itoa(body.SKU), err)
+		return
+	}
+	for _, m := range moves {
+		inventoryChanged(m)
+	}
+	json.NewEncoder(w).Encode(o)
+}
+
+// Reserves the components of a draft work order
+func releaseWorkOrder(w http.ResponseWriter, r *http.Request) {
+	updateWorkOrderStatus(w, r, models.WorkOrderReleased)
+}
+
+// Takes the components of a work order out of stock and puts the finished kits in, releasing it first if
+// it hasn't been
+func completeWorkOrder(w http.ResponseWriter, r *http.Request) {
+	updateWorkOrderStatus(w, r, models.WorkOrderCompleted)
+}
+
+// Calls off a work order that hasn't been completed, handing its reserved components back
+func cancelWorkOrder(w http.ResponseWriter, r *http.Request) {
+	updateWorkOrderStatus(w, r, models.WorkOrderCancelled)
+}
+
+// updateWorkOrderStatus moves the work order on to status, recording the optional {"user"} from the
+// body against the reservations and movements it makes
+func updateWorkOrderStatus(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !workOrdersEnabled(w) {
+		return
+	}
+	id, ok := workOrderID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		User string `json:"user"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Invalid JSON body."))
+			return
+		}
+	}
+
+	o, moves, err := workOrders.UpdateWorkOrderStatus(id, status, body.User)
+	if !writeWorkOrderResult(w, id, status, err) {
+		return
+	}
+	for _, m := range moves {
+		inventoryChanged(m)
+	}
+	json.NewEncoder(w).Encode(o)
+}
+
+// writeWorkOrderResult writes the response for a failed status change, reporting whether the work order
+// changed
+func writeWorkOrderResult(w http.ResponseWriter, id int, status string, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case models.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - Work order not found"))
+	case models.ErrWorkOrderStatus:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Work order can't be " + status + " from its current status"))
+	default:
+		// a stock error when reserving or using up the components
+		writeInventoryResult(w, "(work order "+strconv.Itoa(id)+")", err)
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"../alerts"
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/alerts"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+// chanNotifier hands every alert to the test
+type chanNotifier chan alerts.LowStock
+
+func (c chanNotifier) Notify(a alerts.LowStock) error {
+	c <- a
+	return nil
+}
+
+func TestLowStockAlertOncePerCrossing(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+	sent := make(chanNotifier, 10)
+	routes.SetNotifier(sent)
+	defer routes.SetNotifier(alerts.LogNotifier{})
+
+	// Swing starts at 10 with a notification quantity of 2, scan it down to 0
+	for i := 0; i < 10; i++ {
+		serve(router, "POST", "/inventory/decrement/1", nil)
+	}
+
+	select {
+	case a := <-sent:
+		if a.SKU != 1 || a.Quantity != 2 || a.Threshold != 2 {
+			t.Errorf("unexpected alert: %+v", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no alert sent when the quantity reached the notification quantity")
+	}
+
+	// restocking re-arms the alert
+	serve(router, "POST", "/inventory/update/1/5", nil)
+	serve(router, "POST", "/inventory/adjust/1", []byte(`{"delta":-4}`))
+	select {
+	case a := <-sent:
+		if a.Quantity != 1 {
+			t.Errorf("unexpected alert after restock: %+v", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no alert sent after restocking and running low again")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if len(sent) != 0 {
+		t.Errorf("%v extra alerts sent, want one per crossing", len(sent))
+	}
+}
+
+// Shipping a transfer takes stock off its location, but the product still has it
+func TestLowStockAlertSkipsTransfers(t *testing.T) {
+	router := newSQLiteRouter(t)
+	sent := make(chanNotifier, 10)
+	routes.SetNotifier(sent)
+	defer routes.SetNotifier(alerts.LogNotifier{})
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","notificationquantity":5,"sku":1}`))
+	serve(router, "POST", "/inventory/adjust/1", []byte(`{"delta":8}`))
+	var showroom models.Location
+	json.Unmarshal(serve(router, "POST", "/locations/create", []byte(`{"warehouse":"SHOWROOM"}`)).Body.Bytes(), &showroom)
+	var tr models.Transfer
+	json.Unmarshal(serve(router, "POST", "/transfers", []byte(`{"sku":1,"from":1,"to":`+strconv.Itoa(showroom.LocationID)+`,"quantity":6}`)).Body.Bytes(), &tr)
+	if w := serve(router, "POST", "/transfers/ship/"+strconv.Itoa(tr.TransferID), nil); w.Code != http.StatusOK {
+		t.Fatalf("ship returned %v: %s", w.Code, w.Body.String())
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if len(sent) != 0 {
+		t.Errorf("shipping a transfer sent %+v", <-sent)
+	}
+}
+
+func TestLowStockEndpoint(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	for _, data := range []string{
+		`{"productname":"Swing","notificationquantity":5,"sku":1}`,
+		`{"productname":"Apron","notificationquantity":5,"sku":2}`,
+		`{"productname":"Wallet","sku":3}`,
+	} {
+		serve(router, "POST", "/product/create", []byte(data))
+	}
+	serve(router, "POST", "/inventory/update/1/5", nil)
+	serve(router, "POST", "/inventory/update/2/6", nil)
+
+	w := serve(router, "GET", "/alerts/low-stock", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	var prods []models.Product
+	if err := json.Unmarshal(w.Body.Bytes(), &prods); err != nil {
+		t.Fatal(err)
+	}
+	if len(prods) != 1 || prods[0].SKU != 1 || prods[0].OnHand != 5 {
+		t.Errorf("unexpected low stock products: %+v", prods)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	got := make(chan alerts.LowStock, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alerts.LowStock
+		json.NewDecoder(r.Body).Decode(&a)
+		got <- a
+	}))
+	defer server.Close()
+
+	n := alerts.WebhookNotifier{URL: server.URL}
+	if err := n.Notify(alerts.LowStock{SKU: 3, ProductName: "Apron", Quantity: 1, Threshold: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if a := <-got; a.SKU != 3 || a.Quantity != 1 {
+		t.Errorf("webhook received %+v", a)
+	}
+
+	msg := string(alerts.Message("shop@example.com", []string{"a@example.com", "b@example.com"}, alerts.LowStock{SKU: 3, ProductName: "Apron", Quantity: 1, Threshold: 2}))
+	if !strings.Contains(msg, "To: a@example.com, b@example.com\r\n") || !strings.Contains(msg, "Subject: Low stock: Apron (sku 3)\r\n") {
+		t.Errorf("unexpected email:\n%v", msg)
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"../models"
+	// "github.com/Xero67/web-fire-family/models"
+)
+
+func TestValidateBarcode(t *testing.T) {
+	cases := []struct {
+		symbology string
+		code      string
+		valid     bool
+	}{
+		{models.UPCA, "036000291452", true},
+		{models.UPCA, "036000291453", false},
+		{models.UPCA, "03600029145", false},
+		{models.EAN13, "4006381333931", true},
+		{models.EAN13, "4006381333932", false},
+		{models.EAN13, "400638133393A", false},
+		{models.Code128, "SUP-00417/B", true},
+		{models.Code128, "", false},
+		{models.Code128, "tab\there", false},
+		{"qr", "anything", false},
+	}
+	for _, c := range cases {
+		if err := models.ValidateBarcode(c.symbology, c.code); (err == nil) != c.valid {
+			t.Errorf("ValidateBarcode(%q, %q) = %v, want valid %v", c.symbology, c.code, err, c.valid)
+		}
+	}
+
+	for code, want := range map[string]string{"036000291452": models.UPCA, "4006381333931": models.EAN13, "4006381333932": models.EAN13, "SUP-1": models.Code128} {
+		if got := models.DetectSymbology(code); got != want {
+			t.Errorf("DetectSymbology(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestBarcodeScan(t *testing.T) {
+	router := newSQLiteRouter(t)
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":7}`))
+
+	if w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"036000291452","sku":7}`)); w.Code != http.StatusOK {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+	w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"SUP-00417","symbology":"code128","sku":7}`))
+	var b models.Barcode
+	if err := json.Unmarshal(w.Body.Bytes(), &b); err != nil || b.Symbology != models.Code128 || b.SKU != 7 {
+		t.Fatalf("unexpected barcode %+v: %s", b, w.Body.String())
+	}
+
+	if w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"036000291453","symbology":"upc-a","sku":7}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("bad check digit accepted: got %v", w.Code)
+	}
+	// a mistyped EAN isn't taken for Code128 unless asked to
+	if w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"4006381333932","sku":7}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("bad check digit accepted without a symbology: got %v", w.Code)
+	}
+	// the same UPC read as an EAN-13
+	if w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"0036000291452","sku":7}`)); w.Code != http.StatusConflict {
+		t.Errorf("duplicate barcode accepted: got %v", w.Code)
+	}
+	if w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"4006381333931","sku":99}`)); w.Code != http.StatusNotFound {
+		t.Errorf("barcode for a missing product accepted: got %v", w.Code)
+	}
+
+	serve(router, "POST", "/scan/increment/0036000291452", nil)
+	serve(router, "POST", "/scan/increment/SUP-00417", nil)
+	serve(router, "POST", "/scan/decrement/036000291452", nil)
+	if w := serve(router, "POST", "/scan/increment/4006381333931", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown barcode scanned: got %v", w.Code)
+	}
+
+	w = serve(router, "GET", "/product/7", nil)
+	var prods []models.Product
+	json.Unmarshal(w.Body.Bytes(), &prods)
+	if len(prods) != 1 || prods[0].OnHand != 1 {
+		t.Errorf("unexpected product after scans: %+v", prods)
+	}
+
+	w = serve(router, "GET", "/barcodes/SUP-00417", nil)
+	if json.Unmarshal(w.Body.Bytes(), &b); b.SKU != 7 {
+		t.Errorf("barcode resolved to %+v", b)
+	}
+
+	serve(router, "POST", "/barcodes/delete/SUP-00417", nil)
+	w = serve(router, "GET", "/product/7/barcodes", nil)
+	var codes []models.Barcode
+	json.Unmarshal(w.Body.Bytes(), &codes)
+	if len(codes) != 1 || codes[0].Code != "036000291452" || codes[0].Symbology != models.UPCA {
+		t.Errorf("unexpected barcodes: %+v", codes)
+	}
+	// a code stays taken by its product after that is archived, until the barcode is deleted
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Apron","sku":8}`))
+	if w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"4006381333932","symbology":"code128","sku":8}`)); w.Code != http.StatusOK {
+		t.Errorf("all digit code128 refused: got %v %s", w.Code, w.Body.String())
+	}
+	serve(router, "POST", "/product/delete/8", nil)
+	if w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"4006381333932","symbology":"code128","sku":7}`)); w.Code != http.StatusConflict {
+		t.Errorf("code of an archived product: got %v want %v", w.Code, http.StatusConflict)
+	}
+	serve(router, "POST", "/barcodes/delete/4006381333932", nil)
+	if w := serve(router, "POST", "/barcodes/create", []byte(`{"code":"4006381333932","symbology":"code128","sku":7}`)); w.Code != http.StatusOK {
+		t.Errorf("freed code: got %v %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestBOM(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing set","sku":1}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Chain","sku":2}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Seat","sku":3}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Hardware kit","sku":4}`))
+	serve(router, "POST", "/inventory/adjust/2", []byte(`{"delta":9}`))
+	serve(router, "POST", "/inventory/adjust/3", []byte(`{"delta":7}`))
+	serve(router, "POST", "/inventory/adjust/4", []byte(`{"delta":2}`))
+
+	var bom models.BOM
+	json.Unmarshal(serve(router, "GET", "/product/1/bom", nil).Body.Bytes(), &bom)
+	if len(bom.Components) != 0 || bom.Buildable != 0 {
+		t.Errorf("product without a bill of materials %+v", bom)
+	}
+
+	w := serve(router, "POST", "/product/1/bom", []byte(`{"components":[{"sku":3,"quantity":2},{"sku":2,"quantity":4},{"sku":4,"quantity":1}]}`))
+	if err := json.Unmarshal(w.Body.Bytes(), &bom); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("update returned %v: %s", w.Code, w.Body.String())
+	}
+	// 9 chains make 2 sets, 7 seats 3, 2 hardware kits 2
+	if len(bom.Components) != 3 || bom.Components[0].SKU != 2 || bom.Components[0].Buildable != 2 || bom.Components[1].Buildable != 3 || bom.Buildable != 2 {
+		t.Errorf("unexpected bill of materials %+v", bom)
+	}
+
+	// reserved components aren't there to build with
+	serve(router, "POST", "/inventory/4/reservations", []byte(`{"quantity":1}`))
+	json.Unmarshal(serve(router, "GET", "/product/1/bom", nil).Body.Bytes(), &bom)
+	if bom.Buildable != 1 || bom.Components[2].Available != 1 || bom.Components[0].ProductName != "Chain" {
+		t.Errorf("unexpected bill of materials with a reservation %+v", bom)
+	}
+
+	// an archived component stays on the list but nothing can be built with it
+	serve(router, "POST", "/product/delete/3", nil)
+	json.Unmarshal(serve(router, "GET", "/product/1/bom", nil).Body.Bytes(), &bom)
+	if len(bom.Components) != 3 || !bom.Components[1].Archived || bom.Components[1].Buildable != 0 || bom.Components[0].Archived || bom.Buildable != 0 {
+		t.Errorf("unexpected bill of materials with an archived component %+v", bom)
+	}
+
+	// the hardware kit can't contain the swing set it is part of
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Bolt","sku":5}`))
+	if w := serve(router, "POST", "/product/4/bom", []byte(`{"components":[{"sku":5,"quantity":8}]}`)); w.Code != http.StatusOK {
+		t.Errorf("nested kit: got %v want %v", w.Code, http.StatusOK)
+	}
+	for body, want := range map[string]int{
+		`{"components":[{"sku":2,"quantity":0}]}`:                        http.StatusBadRequest,
+		`{"components":[{"sku":2,"quantity":1},{"sku":2,"quantity":1}]}`: http.StatusBadRequest,
+		`{"components":[{"sku":42,"quantity":1}]}`:                       http.StatusNotFound,
+	} {
+		if w := serve(router, "POST", "/product/5/bom", []byte(body)); w.Code != want {
+			t.Errorf("%s: got %v want %v", body, w.Code, want)
+		}
+	}
+	if w := serve(router, "POST", "/product/5/bom", []byte(`{"components":[{"sku":1,"quantity":1}]}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("kit inside itself: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+
+	// a failed update leaves the old bill of materials alone, an empty one takes it away
+	if w := serve(router, "POST", "/product/1/bom", []byte(`{"components":[{"sku":2,"quantity":1},{"sku":42,"quantity":1}]}`)); w.Code != http.StatusNotFound {
+		t.Errorf("unknown component: got %v want %v", w.Code, http.StatusNotFound)
+	}
+	json.Unmarshal(serve(router, "GET", "/product/1/bom", nil).Body.Bytes(), &bom)
+	if len(bom.Components) != 3 {
+		t.Errorf("failed update changed the bill of materials %+v", bom)
+	}
+	json.Unmarshal(serve(router, "POST", "/product/1/bom", []byte(`{"components":[]}`)).Body.Bytes(), &bom)
+	if len(bom.Components) != 0 || bom.Buildable != 0 {
+		t.Errorf("bill of materials not cleared %+v", bom)
+	}
+	if w := serve(router, "GET", "/product/42/bom", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown product: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestBOMNotEnabled(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	if w := serve(router, "GET", "/product/1/bom", nil); w.Code != http.StatusNotFound {
+		t.Errorf("bill of materials without a bom store: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestCategories(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	for _, body := range []string{`{"categoryname":"Outdoor"}`, `{"categoryname":"Swings","parentid":1}`, `{"categoryname":"Baby","parentid":2}`, `{"categoryname":"Gifts"}`} {
+		if w := serve(router, "POST", "/categories/create", []byte(body)); w.Code != http.StatusOK {
+			t.Fatalf("%s: got %v: %s", body, w.Code, w.Body.String())
+		}
+	}
+	var c models.Category
+	json.Unmarshal(serve(router, "GET", "/categories/3", nil).Body.Bytes(), &c)
+	if c.Path != "Outdoor / Swings / Baby" || c.ParentID != 2 {
+		t.Errorf("unexpected category %+v", c)
+	}
+
+	for body, want := range map[string]int{
+		`{"categoryname":"swings","parentid":1}`: http.StatusConflict,
+		`{"categoryname":"Toys","parentid":9}`:   http.StatusNotFound,
+		`{"categoryname":" "}`:                   http.StatusBadRequest,
+	} {
+		if w := serve(router, "POST", "/categories/create", []byte(body)); w.Code != want {
+			t.Errorf("%s: got %v want %v", body, w.Code, want)
+		}
+	}
+	// Outdoor can't go below its own grandchild
+	if w := serve(router, "POST", "/categories/update/1", []byte(`{"categoryname":"Outdoor","parentid":3}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("category inside itself: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":1}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Baby swing","sku":2}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Wallet","sku":3}`))
+	serve(router, "POST", "/product/1/categories", []byte(`{"categories":[2]}`))
+	serve(router, "POST", "/product/2/categories", []byte(`{"categories":[3,4]}`))
+	serve(router, "POST", "/product/3/categories", []byte(`{"categories":[4]}`))
+	var cats []*models.Category
+	json.Unmarshal(serve(router, "GET", "/product/2/categories", nil).Body.Bytes(), &cats)
+	if len(cats) != 2 || cats[0].Path != "Gifts" || cats[1].CategoryID != 3 {
+		t.Errorf("unexpected product categories %+v", cats)
+	}
+
+	var tags []string
+	json.Unmarshal(serve(router, "POST", "/product/1/tags", []byte(`{"tags":["Outdoor","best  seller"]}`)).Body.Bytes(), &tags)
+	if len(tags) != 2 || tags[0] != "best seller" || tags[1] != "outdoor" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	serve(router, "POST", "/product/2/tags", []byte(`{"tags":["outdoor"]}`))
+	serve(router, "POST", "/product/3/tags", []byte(`{"tags":["Best Seller"]}`))
+
+	skus := func(url string) []int {
+		var prods []*models.Product
+		w := serve(router, "GET", url, nil)
+		if err := json.Unmarshal(w.Body.Bytes(), &prods); err != nil {
+			t.Errorf("%v: got %v: %s", url, w.Code, w.Body.String())
+		}
+		var list []int
+		for _, p := range prods {
+			list = append(list, p.SKU)
+		}
+		return list
+	}
+	for url, want := range map[string][]int{
+		"/product?category=1":                           {1, 2},
+		"/product?category=3":                           {2},
+		"/product?category=4":                           {2, 3},
+		"/product?tag=best+seller":                      {1, 3},
+		"/product?tag=outdoor&tag=best+seller":          {1},
+		"/product?category=4&tag=outdoor":               {2},
+		"/product?category=2&tag=outdoor&tag=clearance": nil,
+	} {
+		if got := skus(url); len(got) != len(want) || (len(got) > 0 && (got[0] != want[0] || got[len(got)-1] != want[len(want)-1])) {
+			t.Errorf("%v: got %v want %v", url, got, want)
+		}
+	}
+	if w := serve(router, "GET", "/product?category=9", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown category: got %v want %v", w.Code, http.StatusNotFound)
+	}
+
+	// renaming merges into a tag the product already has
+	serve(router, "POST", "/tags/rename/best seller", []byte(`{"tag":"outdoor"}`))
+	var list []*models.Tag
+	json.Unmarshal(serve(router, "GET", "/tags", nil).Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Tag != "outdoor" || list[0].Products != 3 {
+		t.Errorf("unexpected tags after rename %+v", list)
+	}
+	if w := serve(router, "POST", "/tags/delete/clearance", nil); w.Code != http.StatusNotFound {
+		t.Errorf("delete unknown tag: got %v want %v", w.Code, http.StatusNotFound)
+	}
+
+	// a category goes once its subcategories have, taking its products out of it
+	if w := serve(router, "POST", "/categories/delete/2", nil); w.Code != http.StatusConflict {
+		t.Errorf("delete with subcategories: got %v want %v", w.Code, http.StatusConflict)
+	}
+	if w := serve(router, "POST", "/categories/delete/3", nil); w.Code != http.StatusOK {
+		t.Errorf("delete without subcategories: got %v want %v", w.Code, http.StatusOK)
+	}
+	if got := skus("/product?category=1"); len(got) != 1 || got[0] != 1 {
+		t.Errorf("products of Outdoor after deleting Baby: %v", got)
+	}
+}
+
+func TestCategoriesNotEnabled(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	for _, url := range []string{"/categories", "/tags", "/product?tag=outdoor"} {
+		if w := serve(router, "GET", url, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%v without a category store: got %v want %v", url, w.Code, http.StatusNotFound)
+		}
+	}
+}
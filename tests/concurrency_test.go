@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+// N scanners decrementing the same SKU at once must take exactly N units off, and every one of them
+// must see its own resulting quantity in the ledger.
+func TestConcurrentDecrements(t *testing.T) {
+	const start, scans = 100, 40
+	router := newSQLiteRouter(t)
+
+	data := []byte(`{"productname":"Chain","notificationquantity":2,"color":"Silver","trimcolor":"","size":"8'","price":19.99,"dimensions":"8'","sku":3}`)
+	if w := serve(router, "POST", "/product/create", data); w.Code != http.StatusOK {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+	serve(router, "POST", "/inventory/update/3/"+strconv.Itoa(start), nil)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, scans)
+	for n := 0; n < scans; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(router, "POST", "/inventory/decrement/3", nil).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("decrement returned %v", code)
+		}
+	}
+
+	var level models.StockLevel
+	json.Unmarshal(serve(router, "GET", "/inventory/3", nil).Body.Bytes(), &level)
+	if len(level.Locations) != 1 || level.OnHand != start-scans {
+		t.Fatalf("quantity after %v parallel decrements: got %+v want %v", scans, level, start-scans)
+	}
+
+	var moves []models.InventoryMovement
+	json.Unmarshal(serve(router, "GET", "/inventory/3/movements?limit=500", nil).Body.Bytes(), &moves)
+	quantities := make([]int, 0)
+	for _, m := range moves {
+		if m.Reason == models.ReasonSale {
+			quantities = append(quantities, m.Quantity)
+		}
+	}
+	sort.Ints(quantities)
+	if len(quantities) != scans {
+		t.Fatalf("got %v sale movements, want %v", len(quantities), scans)
+	}
+	for i, q := range quantities {
+		if q != start-scans+i {
+			t.Fatalf("ledger quantities overlap or skip: %v", quantities)
+		}
+	}
+}
+
+// SQLite serializes whole transactions, the other dialects rely on the inventory row, and only that row,
+// being read with FOR UPDATE before anything else in the transaction.
+func TestDecrementLocksInventoryRow(t *testing.T) {
+	for _, dialect := range []models.Dialect{models.MySQL, models.Postgres} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT InventoryID FROM Inventory WHERE (.+) FOR UPDATE$").WillReturnError(errors.New("lock wait timeout exceeded"))
+		mock.ExpectRollback()
+
+		store := models.NewSQLStore(db, dialect)
+		if w := serve(routes.InitRoutes(store, store), "POST", "/inventory/decrement/3", nil); w.Code == http.StatusOK {
+			t.Errorf("dialect %v: decrement went ahead without its lock", dialect)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("dialect %v: %s", dialect, err)
+		}
+		db.Close()
+	}
+}
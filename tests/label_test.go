@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"flag"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"../labels"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/labels"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name, or rewrites it when the tests are run with -update
+func golden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%v does not match, run go test -update if the change is intended\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func newLabelRouter() http.Handler {
+	store := newMemStore()
+	store.products[1].Color, store.products[1].TrimColor, store.products[1].Size = "Red", "Black", "Large"
+	store.products[1].ProductName = "Swing ^ Set"
+	return routes.InitRoutes(store, store)
+}
+
+func TestProductLabelZPL(t *testing.T) {
+	router := newLabelRouter()
+	for _, kind := range []string{"code128", "qr"} {
+		w := serve(router, "GET", "/product/1/label?format=zpl&barcode="+kind, nil)
+		if w.Code != 200 || w.Header().Get("Content-Type") != "application/x-zpl" {
+			t.Fatalf("%v label returned %v %v", kind, w.Code, w.Header().Get("Content-Type"))
+		}
+		golden(t, "label_"+kind+".zpl", w.Body.Bytes())
+	}
+}
+
+func TestProductLabelImages(t *testing.T) {
+	router := newLabelRouter()
+
+	w := serve(router, "GET", "/product/1/label", nil)
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 406 || b.Dy() != 203 {
+		t.Errorf("png is %v, want 406x203", b)
+	}
+
+	w = serve(router, "GET", "/product/1/label?format=svg&barcode=qr", nil)
+	svg := w.Body.String()
+	if w.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(svg, ">Swing ^ Set</text>") || !strings.Contains(svg, ">Red / Black / Large</text>") || !strings.Contains(svg, ">SKU 1</text>") {
+		t.Errorf("unexpected svg:\n%v", svg)
+	}
+
+	if w := serve(router, "GET", "/product/1/label?format=gif", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(router, "GET", "/product/1/label?barcode=pdf417", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown barcode: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(router, "GET", "/product/9/label", nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing product: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+// pdfPages counts the page objects in an uncompressed PDF catalog
+func pdfPages(pdf []byte) int {
+	return bytes.Count(pdf, []byte("/Type /Page\n"))
+}
+
+func TestLabelSheet(t *testing.T) {
+	router := newLabelRouter()
+
+	w := serve(router, "POST", "/labels/sheet", []byte(`{"items":[{"sku":1,"copies":31}]}`))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("sheet returned %v %v: %v", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) || pdfPages(w.Body.Bytes()) != 2 {
+		t.Errorf("31 labels on 5160 stock should take 2 pages, got %v", pdfPages(w.Body.Bytes()))
+	}
+
+	// skipping the used positions of a 10 up sheet pushes the second label onto a new page
+	w = serve(router, "POST", "/labels/sheet", []byte(`{"template":"5163","skip":9,"barcode":"qr","items":[{"sku":1},{"sku":1}]}`))
+	if w.Code != http.StatusOK || pdfPages(w.Body.Bytes()) != 2 {
+		t.Errorf("skip: got %v with %v pages, want 2 pages", w.Code, pdfPages(w.Body.Bytes()))
+	}
+
+	bad := map[string]int{
+		`{"items":[{"sku":9}]}`:                               http.StatusNotFound,
+		`{"template":"1234","items":[{"sku":1}]}`:             http.StatusBadRequest,
+		`{"rows":12,"items":[{"sku":1}]}`:                     http.StatusBadRequest,
+		`{"skip":30,"items":[{"sku":1}]}`:                     http.StatusBadRequest,
+		`{"items":[]}`:                                        http.StatusBadRequest,
+		`{"items":[{"sku":1,"copies":-1}]}`:                   http.StatusBadRequest,
+		`{"barcode":"pdf417","items":[{"sku":1,"copies":2}]}`: http.StatusBadRequest,
+	}
+	for body, want := range bad {
+		if w := serve(router, "POST", "/labels/sheet", []byte(body)); w.Code != want {
+			t.Errorf("%v: got %v want %v", body, w.Code, want)
+		}
+	}
+}
+
+func TestSheetTemplatesFit(t *testing.T) {
+	for name, sheet := range labels.Templates {
+		if err := sheet.Validate(); err != nil {
+			t.Errorf("template %v: %v", name, err)
+		}
+	}
+	if pages := labels.Templates["5167"].Pages(160, 1); pages != 3 {
+		t.Errorf("160 labels after 1 skipped on 80 up stock: got %v pages want 3", pages)
+	}
+}
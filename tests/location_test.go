@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"../models"
+	// "github.com/Xero67/web-fire-family/models"
+)
+
+func TestLocationInventory(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	data := []byte(`{"productname":"Swing","notificationquantity":2,"sku":5}`)
+	if w := serve(router, "POST", "/product/create", data); w.Code != http.StatusOK {
+		t.Fatalf("create product returned %v: %s", w.Code, w.Body.String())
+	}
+	w := serve(router, "POST", "/locations/create", []byte(`{"warehouse":"EAST","aisle":"A3","bin":"07"}`))
+	var bin models.Location
+	if err := json.Unmarshal(w.Body.Bytes(), &bin); err != nil || bin.LocationID < 2 {
+		t.Fatalf("create location returned %v: %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, "POST", "/locations/create", []byte(`{"warehouse":"EAST","aisle":"A3","bin":"07"}`)); w.Code != http.StatusConflict {
+		t.Errorf("duplicate location: got %v want %v", w.Code, http.StatusConflict)
+	}
+
+	// the default location when none is given, the new bin from the body or the query string
+	serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":4}`))
+	w = serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":6,"location":`+strconv.Itoa(bin.LocationID)+`}`))
+	var m models.InventoryMovement
+	json.Unmarshal(w.Body.Bytes(), &m)
+	if m.LocationID != bin.LocationID || m.Quantity != 6 {
+		t.Errorf("adjust at a location recorded %+v", m)
+	}
+	serve(router, "POST", "/inventory/decrement/5?location="+strconv.Itoa(bin.LocationID), nil)
+
+	var level models.StockLevel
+	json.Unmarshal(serve(router, "GET", "/inventory/5", nil).Body.Bytes(), &level)
+	if level.OnHand != 9 || len(level.Locations) != 2 {
+		t.Fatalf("unexpected stock level %+v", level)
+	}
+	for _, i := range level.Locations {
+		want := map[int]int{models.DefaultLocation: 4, bin.LocationID: 5}[i.LocationID]
+		if i.Quantity != want || i.Location == nil || i.Location.LocationID != i.LocationID {
+			t.Errorf("location %v: got %+v want quantity %v", i.LocationID, i, want)
+		}
+	}
+
+	// the product total is what /product and the low stock alerts see
+	var prods []models.Product
+	json.Unmarshal(serve(router, "GET", "/product/5", nil).Body.Bytes(), &prods)
+	if len(prods) != 1 || prods[0].OnHand != 9 {
+		t.Errorf("product quantity: got %+v want 9", prods)
+	}
+
+	// each location has its own stock to sell from
+	w = serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":-5,"location":1}`))
+	if w.Code != http.StatusConflict {
+		t.Errorf("overselling one location: got %v want %v", w.Code, http.StatusConflict)
+	}
+	if w := serve(router, "POST", "/inventory/increment/5?location=99", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown location: got %v want %v", w.Code, http.StatusNotFound)
+	}
+
+	url := "/locations/delete/" + strconv.Itoa(bin.LocationID)
+	if w := serve(router, "POST", url, nil); w.Code != http.StatusConflict {
+		t.Errorf("deleting a location with stock: got %v want %v", w.Code, http.StatusConflict)
+	}
+	if w := serve(router, "POST", "/locations/delete/1", nil); w.Code != http.StatusConflict {
+		t.Errorf("deleting the default location: got %v want %v", w.Code, http.StatusConflict)
+	}
+	serve(router, "POST", "/inventory/update/5/0?location="+strconv.Itoa(bin.LocationID), nil)
+	if w := serve(router, "POST", url, nil); w.Code != http.StatusOK {
+		t.Errorf("deleting an empty location: got %v want %v", w.Code, http.StatusOK)
+	}
+}
+
+// Products with more than one inventory row are merged into one at the default location
+func TestLocationMigrationMergesDuplicates(t *testing.T) {
+	db, err := models.InitSQLite(filepath.Join(t.TempDir(), "merge.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := models.MigrateUp(db, models.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	// back to before locations, which also exercises the down migration
+	states, _ := models.MigrationStatus(db, models.SQLite)
+	steps := 0
+	for _, s := range states {
+		if s.Version >= 7 {
+			steps++
+		}
+	}
+	if _, err := models.MigrateDown(db, models.SQLite, steps); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{
+		"INSERT INTO Product (ProductID, ProductName, SKU) VALUES (1, 'Swing', 1)",
+		"INSERT INTO Inventory (InventoryID, Quantity, ProductID) VALUES (1, 4, 1)",
+		"INSERT INTO Inventory (InventoryID, Quantity, ProductID) VALUES (2, 6, 1)",
+		"INSERT INTO InventoryMovement (InventoryID, Delta, Quantity, Reason) VALUES (2, 6, 6, 'receive')",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%v: %v", q, err)
+		}
+	}
+	if _, err := models.MigrateUp(db, models.SQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	store := models.NewSQLStore(db, models.SQLite)
+	inv, err := store.GetInventory(1)
+	if err != nil || len(inv) != 1 || inv[0].InventoryID != 1 || inv[0].Quantity != 10 || inv[0].LocationID != models.DefaultLocation {
+		t.Fatalf("inventory not merged: %+v %v", inv, err)
+	}
+	moves, _ := store.ListMovements(1, 10, 0)
+	if len(moves) != 1 || moves[0].InventoryID != 1 {
+		t.Errorf("movement not moved to the merged row: %+v", moves)
+	}
+}
+
+func TestDeleteLocationInUse(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":5}`))
+	serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":3}`))
+	var bin models.Location
+	json.Unmarshal(serve(router, "POST", "/locations/create", []byte(`{"warehouse":"EAST","aisle":"A3","bin":"07"}`)).Body.Bytes(), &bin)
+	to := strconv.Itoa(bin.LocationID)
+	url := "/locations/delete/" + to
+
+	// stock on its way to the bin has to land somewhere
+	var tr models.Transfer
+	json.Unmarshal(serve(router, "POST", "/transfers", []byte(`{"sku":5,"from":1,"to":`+to+`,"quantity":2}`)).Body.Bytes(), &tr)
+	if w := serve(router, "POST", url, nil); w.Code != http.StatusConflict {
+		t.Errorf("deleting a location with a requested transfer: got %v want %v", w.Code, http.StatusConflict)
+	}
+	serve(router, "POST", "/transfers/ship/"+strconv.Itoa(tr.TransferID), nil)
+	if w := serve(router, "POST", url, nil); w.Code != http.StatusConflict {
+		t.Errorf("deleting a location with a transfer in transit: got %v want %v", w.Code, http.StatusConflict)
+	}
+	serve(router, "POST", "/transfers/receive/"+strconv.Itoa(tr.TransferID), nil)
+
+	// and so do reservations against it, even once the stock is gone
+	var held models.Reservation
+	json.Unmarshal(serve(router, "POST", "/inventory/5/reservations", []byte(`{"quantity":1,"location":`+to+`}`)).Body.Bytes(), &held)
+	if held.LocationID != bin.LocationID {
+		t.Fatalf("unexpected reservation %+v", held)
+	}
+	serve(router, "POST", "/inventory/update/5/0?location="+to, nil)
+	if w := serve(router, "POST", url, nil); w.Code != http.StatusConflict {
+		t.Errorf("deleting a location with an active reservation: got %v want %v", w.Code, http.StatusConflict)
+	}
+	serve(router, "POST", "/reservations/release/"+strconv.Itoa(held.ReservationID), nil)
+	if w := serve(router, "POST", url, nil); w.Code != http.StatusOK {
+		t.Errorf("deleting a location no longer in use: got %v want %v", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"../models"
+	// "github.com/Xero67/web-fire-family/models"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestMigrateUpDownStatus(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations, err := models.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 || m.Up == "" || m.Down == "" {
+			t.Errorf("migration %04d_%v is out of sequence or missing its up/down file", m.Version, m.Name)
+		}
+	}
+
+	done, err := models.MigrateUp(db, models.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(migrations) {
+		t.Errorf("applied %v migrations, want %v", len(done), len(migrations))
+	}
+
+	if done, _ = models.MigrateUp(db, models.SQLite); len(done) != 0 {
+		t.Errorf("second migrate up applied %v migrations, want 0", len(done))
+	}
+
+	if _, err = db.Exec("INSERT INTO Product (ProductName, SKU) VALUES ('Swing', 1)"); err != nil {
+		t.Errorf("Product table not usable after migrate up: %s", err)
+	}
+
+	if done, err = models.MigrateDown(db, models.SQLite, len(migrations)); err != nil || len(done) != len(migrations) {
+		t.Fatalf("migrate down reverted %v migrations: %v", len(done), err)
+	}
+
+	states, err := models.MigrationStatus(db, models.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range states {
+		if s.Applied {
+			t.Errorf("migration %04d_%v still applied after migrate down", s.Version, s.Name)
+		}
+	}
+	if _, err = db.Exec("SELECT * FROM Product"); err == nil {
+		t.Errorf("Product table still exists after migrate down")
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestPagedProducts(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	for _, body := range []string{
+		`{"productname":"Swing","color":"Red","size":"Large","price":129.99,"sku":1}`,
+		`{"productname":"Swing","color":"Blue","size":"Large","price":119.99,"sku":2}`,
+		`{"productname":"Swing","color":"Red","size":"Small","price":99.99,"sku":3}`,
+		`{"productname":"Apron","color":"Tan","price":29,"sku":4}`,
+		`{"productname":"Wallet","color":"Tan","price":30,"sku":5}`,
+	} {
+		serve(router, "POST", "/product/create", []byte(body))
+	}
+	serve(router, "POST", "/product/delete/5", nil)
+	serve(router, "POST", "/inventory/adjust/1", []byte(`{"delta":8}`))
+	serve(router, "POST", "/inventory/adjust/2", []byte(`{"delta":2}`))
+	serve(router, "POST", "/inventory/adjust/4", []byte(`{"delta":5}`))
+
+	skus := func(url string) ([]int, http.Header) {
+		var prods []*models.Product
+		w := serve(router, "GET", url, nil)
+		if err := json.Unmarshal(w.Body.Bytes(), &prods); err != nil {
+			t.Errorf("%v: got %v: %s", url, w.Code, w.Body.String())
+		}
+		var list []int
+		for _, p := range prods {
+			list = append(list, p.SKU)
+		}
+		return list, w.Header()
+	}
+
+	// archived products don't take up room on a page
+	got, header := skus("/product?limit=2")
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 || header.Get("X-Total-Count") != "4" {
+		t.Errorf("first page %v, total %v", got, header.Get("X-Total-Count"))
+	}
+	if link := header.Get("Link"); link != `</product?limit=2&offset=2>; rel="next"` {
+		t.Errorf("first page link %q", link)
+	}
+	got, header = skus("/product?limit=2&offset=2")
+	if len(got) != 2 || got[0] != 3 || got[1] != 4 || header.Get("Link") != `</product?limit=2&offset=0>; rel="prev"` {
+		t.Errorf("last page %v, link %q", got, header.Get("Link"))
+	}
+
+	for url, want := range map[string][]int{
+		"/product?sort=-price":                      {1, 2, 3, 4},
+		"/product?sort=onhand":                      {3, 2, 4, 1},
+		"/product?sort=productname":                 {4, 1, 2, 3},
+		"/product?color=red":                        {1, 3},
+		"/product?size=Large&color=BLUE":            {2},
+		"/product?price_min=99.99&price_max=119.99": {2, 3},
+		"/product?quantity_lt=5":                    {2, 3},
+		"/product?quantity_lt=5&sort=-sku&limit=1":  {3},
+		"/product?color=Tan&price_min=100":          nil,
+	} {
+		if got, _ := skus(url); len(got) != len(want) || (len(got) > 0 && (got[0] != want[0] || got[len(got)-1] != want[len(want)-1])) {
+			t.Errorf("%v: got %v want %v", url, got, want)
+		}
+	}
+
+	for _, url := range []string{"/product?sort=deleted", "/product?limit=0", "/product?price_min=x", "/product?quantity_lt=few", "/product?style=-1"} {
+		if w := serve(router, "GET", url, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%v: got %v want %v", url, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	// without any of the parameters it is the whole list as before
+	got, header = skus("/product")
+	if len(got) != 4 || header.Get("X-Total-Count") != "" {
+		t.Errorf("unpaged listing %v with headers %v", got, header)
+	}
+}
+
+func TestFilteredProductsNotPaged(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	for sku := 1; sku <= 60; sku++ {
+		serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","color":"Red","sku":`+strconv.Itoa(sku)+`}`))
+	}
+
+	// a filter alone returns every match, only limit or offset make it a page
+	var prods []*models.Product
+	w := serve(router, "GET", "/product?color=red", nil)
+	json.Unmarshal(w.Body.Bytes(), &prods)
+	if len(prods) != 60 || w.Header().Get("X-Total-Count") != "60" || w.Header().Get("Link") != "" {
+		t.Errorf("filtered listing returned %v products, headers %v", len(prods), w.Header())
+	}
+	w = serve(router, "GET", "/product?color=red&offset=5", nil)
+	json.Unmarshal(w.Body.Bytes(), &prods)
+	if len(prods) != 50 || prods[0].SKU != 6 || w.Header().Get("Link") == "" {
+		t.Errorf("offset alone returned %v products, headers %v", len(prods), w.Header())
+	}
+}
+
+func TestPagedInventories(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	var bin models.Location
+	json.Unmarshal(serve(router, "POST", "/locations/create", []byte(`{"warehouse":"EAST"}`)).Body.Bytes(), &bin)
+	for sku := 1; sku <= 3; sku++ {
+		serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":`+strconv.Itoa(sku)+`}`))
+		serve(router, "POST", "/inventory/adjust/"+strconv.Itoa(sku), []byte(`{"delta":`+strconv.Itoa(sku*3)+`}`))
+	}
+	serve(router, "POST", "/inventory/adjust/2", []byte(`{"delta":1,"location":`+strconv.Itoa(bin.LocationID)+`}`))
+
+	var inv []*models.Inventory
+	w := serve(router, "GET", "/inventories?sort=-quantity&limit=2", nil)
+	json.Unmarshal(w.Body.Bytes(), &inv)
+	if len(inv) != 2 || inv[0].SKU != 3 || inv[1].SKU != 2 || inv[1].LocationID != models.DefaultLocation || w.Header().Get("X-Total-Count") != "4" {
+		t.Errorf("unexpected page %+v, total %v", inv, w.Header().Get("X-Total-Count"))
+	}
+	json.Unmarshal(serve(router, "GET", "/inventories?warehouse=EAST", nil).Body.Bytes(), &inv)
+	if len(inv) != 1 || inv[0].SKU != 2 || inv[0].Quantity != 1 {
+		t.Errorf("unexpected inventories in EAST %+v", inv)
+	}
+	json.Unmarshal(serve(router, "GET", "/inventories?sku=2&quantity_lt=5", nil).Body.Bytes(), &inv)
+	if len(inv) != 1 || inv[0].LocationID != bin.LocationID {
+		t.Errorf("unexpected low inventories of sku 2 %+v", inv)
+	}
+	if w := serve(router, "GET", "/inventories?sort=available", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("sort by available: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestPagingNotEnabled(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	for _, url := range []string{"/product?limit=10", "/inventories?sort=sku"} {
+		if w := serve(router, "GET", url, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%v without paging: got %v want %v", url, w.Code, http.StatusNotFound)
+		}
+	}
+	if w := serve(router, "GET", "/product", nil); w.Code != http.StatusOK {
+		t.Errorf("full list without paging: got %v want %v", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestReservation(t *testing.T) {
+	store := newSQLiteStore(t)
+	router := routes.InitRoutes(store, store)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":5}`))
+	serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":10}`))
+
+	w := serve(router, "POST", "/inventory/5/reservations", []byte(`{"quantity":4,"reference":"order 1001","user":"sam"}`))
+	var held models.Reservation
+	if err := json.Unmarshal(w.Body.Bytes(), &held); err != nil || held.ReservationID == 0 || held.Status != models.ReservationActive || held.LocationID != models.DefaultLocation {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+	id := strconv.Itoa(held.ReservationID)
+
+	var level models.StockLevel
+	json.Unmarshal(serve(router, "GET", "/inventory/5", nil).Body.Bytes(), &level)
+	if level.OnHand != 10 || level.Reserved != 4 || level.Available != 6 || level.Locations[0].Available != 6 {
+		t.Errorf("unexpected stock level %+v", level)
+	}
+	var prods []models.Product
+	json.Unmarshal(serve(router, "GET", "/product/5", nil).Body.Bytes(), &prods)
+	if len(prods) != 1 || prods[0].OnHand != 10 || prods[0].Reserved != 4 || prods[0].Available != 6 {
+		t.Errorf("unexpected product quantities %+v", prods)
+	}
+
+	// the same units can't be promised twice
+	w = serve(router, "POST", "/inventory/5/reservations", []byte(`{"quantity":7}`))
+	var stockErr models.StockError
+	if json.Unmarshal(w.Body.Bytes(), &stockErr); w.Code != http.StatusConflict || stockErr.Quantity != 6 {
+		t.Errorf("over reserving: got %v %s", w.Code, w.Body.String())
+	}
+
+	// an expired reservation stops holding stock without anyone closing it
+	old := &models.Reservation{SKU: 5, LocationID: models.DefaultLocation, Quantity: 6}
+	if err := store.CreateReservation(old, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(serve(router, "GET", "/reservations/"+strconv.Itoa(old.ReservationID), nil).Body.Bytes(), old)
+	if old.Status != models.ReservationExpired {
+		t.Errorf("reservation past its expiry is %q", old.Status)
+	}
+	var active []models.Reservation
+	json.Unmarshal(serve(router, "GET", "/inventory/5/reservations?status=active", nil).Body.Bytes(), &active)
+	if len(active) != 1 || active[0].ReservationID != held.ReservationID || active[0].Reference != "order 1001" {
+		t.Errorf("unexpected active reservations %+v", active)
+	}
+	if w := serve(router, "POST", "/reservations/consume/"+strconv.Itoa(old.ReservationID), nil); w.Code != http.StatusConflict {
+		t.Errorf("consuming an expired reservation: got %v want %v", w.Code, http.StatusConflict)
+	}
+
+	// shipping the order takes the held units out of stock
+	w = serve(router, "POST", "/reservations/consume/"+id, []byte(`{"user":"sam"}`))
+	json.Unmarshal(w.Body.Bytes(), &held)
+	if w.Code != http.StatusOK || held.Status != models.ReservationConsumed || held.DateClosed == "" {
+		t.Fatalf("consume returned %v: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(serve(router, "GET", "/inventory/5", nil).Body.Bytes(), &level)
+	if level.OnHand != 6 || level.Reserved != 0 || level.Available != 6 {
+		t.Errorf("unexpected stock level after consuming %+v", level)
+	}
+	var moves []models.InventoryMovement
+	json.Unmarshal(serve(router, "GET", "/inventory/5/movements", nil).Body.Bytes(), &moves)
+	if moves[0].Delta != -4 || moves[0].Reason != models.ReasonSale || moves[0].Note != "reservation "+id || moves[0].User != "sam" {
+		t.Errorf("unexpected movement %+v", moves[0])
+	}
+	if w := serve(router, "POST", "/reservations/release/"+id, nil); w.Code != http.StatusConflict {
+		t.Errorf("releasing a consumed reservation: got %v want %v", w.Code, http.StatusConflict)
+	}
+
+	// cancelling an order hands its stock back
+	json.Unmarshal(serve(router, "POST", "/inventory/5/reservations", []byte(`{"quantity":6}`)).Body.Bytes(), &held)
+	w = serve(router, "POST", "/reservations/release/"+strconv.Itoa(held.ReservationID), nil)
+	if json.Unmarshal(w.Body.Bytes(), &held); held.Status != models.ReservationReleased {
+		t.Errorf("release returned %v: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(serve(router, "GET", "/product/5", nil).Body.Bytes(), &prods)
+	if prods[0].OnHand != 6 || prods[0].Available != 6 {
+		t.Errorf("released stock not available again %+v", prods[0])
+	}
+
+	for body, want := range map[string]int{
+		`{"quantity":0}`:                                    http.StatusBadRequest,
+		`{"quantity":1,"expiresat":"tomorrow"}`:             http.StatusBadRequest,
+		`{"quantity":1,"expiresat":"2017-11-24T17:00:00Z"}`: http.StatusBadRequest,
+		`{"quantity":1,"location":99}`:                      http.StatusNotFound,
+	} {
+		if w := serve(router, "POST", "/inventory/5/reservations", []byte(body)); w.Code != want {
+			t.Errorf("%s: got %v want %v", body, w.Code, want)
+		}
+	}
+	if w := serve(router, "POST", "/inventory/42/reservations", []byte(`{"quantity":1}`)); w.Code != http.StatusNotFound {
+		t.Errorf("reserving an unknown product: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestReservationHoldsStockFromSales(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":5}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Apron","negativestock":"clamp","sku":6}`))
+	for _, sku := range []string{"5", "6"} {
+		serve(router, "POST", "/inventory/adjust/"+sku, []byte(`{"delta":5}`))
+	}
+	var held models.Reservation
+	json.Unmarshal(serve(router, "POST", "/inventory/5/reservations", []byte(`{"quantity":4}`)).Body.Bytes(), &held)
+	serve(router, "POST", "/inventory/6/reservations", []byte(`{"quantity":4}`))
+
+	// only the one unit nobody holds can be sold
+	w := serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":-2}`))
+	var stockErr models.StockError
+	if json.Unmarshal(w.Body.Bytes(), &stockErr); w.Code != http.StatusConflict || stockErr.Quantity != 1 {
+		t.Errorf("selling reserved stock: got %v %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, "POST", "/inventory/decrement/5", nil); w.Code != http.StatusOK {
+		t.Errorf("selling the unreserved unit: got %v %s", w.Code, w.Body.String())
+	}
+	var m models.InventoryMovement
+	json.Unmarshal(serve(router, "POST", "/inventory/adjust/6", []byte(`{"delta":-3}`)).Body.Bytes(), &m)
+	if m.Delta != -1 || m.Quantity != 4 {
+		t.Errorf("clamp took reserved stock: %+v", m)
+	}
+
+	// the reservation's own units are there for it
+	if w := serve(router, "POST", "/reservations/consume/"+strconv.Itoa(held.ReservationID), nil); w.Code != http.StatusOK {
+		t.Errorf("consume returned %v: %s", w.Code, w.Body.String())
+	}
+	var level models.StockLevel
+	json.Unmarshal(serve(router, "GET", "/inventory/5", nil).Body.Bytes(), &level)
+	if level.OnHand != 0 || level.Reserved != 0 || level.Available != 0 {
+		t.Errorf("unexpected stock level after consuming %+v", level)
+	}
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,8 +10,6 @@ import (
 
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 
-	"../models"
-	"../routes"
 	//"github.com/Xero67/web-fire-family/models"
 	//"github.com/Xero67/web-fire-family/routes"
 	//"os"
@@ -32,16 +31,18 @@ func TestGetInventories(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "productid", "deleted", "sku"}).
-		AddRow(1, 10, "11/17/2017", 0, 1, 1).
-		AddRow(2, 5, "11/16/2017", 0, 2, 2).
-		AddRow(3, 300, "11/15/2017", 0, 3, 3)
+	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "productid", "deleted", "locationid", "sku", "warehouse", "aisle", "bin"}).
+		AddRow(1, 10, "11/17/2017", 0, 1, 1, 1, "MAIN", "", "").
+		AddRow(2, 5, "11/16/2017", 0, 2, 1, 2, "MAIN", "", "").
+		AddRow(3, 300, "11/15/2017", 0, 3, 1, 3, "MAIN", "", "")
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT I.(.+), P.SKU FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID$").WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT I.(.+), P.SKU, L.(.+) FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID$").WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT ProductID, LocationID, SUM\\(Quantity\\) AS Quantity FROM Reservation WHERE Status = 'active' AND ExpiresAt > \\? GROUP BY ProductID, LocationID$").
+		WillReturnRows(sqlmock.NewRows([]string{"productid", "locationid", "quantity"}).AddRow(2, 1, 2))
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -51,7 +52,7 @@ func TestGetInventories(t *testing.T) {
 	}
 
 	// Check the response body is what we expect.
-	expected := `[{"inventoryid":1,"quantity":10,"datelastupdated":"11/17/2017","productid":1,"sku":1},{"inventoryid":2,"quantity":5,"datelastupdated":"11/16/2017","productid":2,"sku":2},{"inventoryid":3,"quantity":300,"datelastupdated":"11/15/2017","productid":3,"sku":3}]`
+	expected := `[{"inventoryid":1,"quantity":10,"datelastupdated":"11/17/2017","productid":1,"sku":1,"locationid":1,"location":{"locationid":1,"warehouse":"MAIN"},"reserved":0,"available":10},{"inventoryid":2,"quantity":5,"datelastupdated":"11/16/2017","productid":2,"sku":2,"locationid":1,"location":{"locationid":1,"warehouse":"MAIN"},"reserved":2,"available":3},{"inventoryid":3,"quantity":300,"datelastupdated":"11/15/2017","productid":3,"sku":3,"locationid":1,"location":{"locationid":1,"warehouse":"MAIN"},"reserved":0,"available":300}]`
 	equal, err := AreEqualJSON(w.Body.String(), expected)
 	if !equal {
 		t.Errorf("handler returned unexpected body: got %v want %v", w.Body.String(), expected)
@@ -79,13 +80,17 @@ func TestGetInventory(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "deleted", "productid", "SKU"}).
-		AddRow(4, 10, "11/17/2017", 0, 1, 4)
+	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "deleted", "productid", "locationid", "sku", "warehouse", "aisle", "bin"}).
+		AddRow(4, 10, "11/17/2017", 0, 1, 1, 4, "MAIN", "", "")
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT I.(.+), P.SKU FROM Inventory I INNER JOIN Product P ON I.ProductID = P.ProductID WHERE P.SKU = \\?$").WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT I.(.+), P.SKU, L.(.+) FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID WHERE P.SKU = \\? ORDER BY L.Warehouse, L.Aisle, L.Bin$").WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT (.+) FROM Reservation (.+)$").WillReturnRows(sqlmock.NewRows([]string{"productid", "locationid", "quantity"}).AddRow(1, 1, 4))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT SUM\\(T.Quantity\\) FROM Transfer T (.+) WHERE T.Status = \\? AND P.SKU = \\?$").WithArgs("in-transit", 4).WillReturnRows(sqlmock.NewRows([]string{"quantity"}).AddRow(3))
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -95,7 +100,7 @@ func TestGetInventory(t *testing.T) {
 	}
 
 	// Check the response body is what we expect.
-	expected := `[{"inventoryid":4,"quantity":10,"datelastupdated":"11/17/2017","productid":1,"sku":4}]`
+	expected := `{"sku":4,"onhand":10,"reserved":4,"available":6,"intransit":3,"locations":[{"inventoryid":4,"quantity":10,"datelastupdated":"11/17/2017","productid":1,"sku":4,"locationid":1,"location":{"locationid":1,"warehouse":"MAIN"},"reserved":4,"available":6}]}`
 	equal, err := AreEqualJSON(w.Body.String(), expected)
 	if !equal {
 		t.Errorf("handler returned unexpected body: got %v want %v", w.Body.String(), expected)
@@ -123,9 +128,9 @@ func TestGetInventoryInvalidID(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT I.(.+), P.SKU FROM Inventory I INNER JOIN Product P ON I.ProductID = P.ProductID WHERE P.SKU = \\?$").WillReturnError(fmt.Errorf("404 - Inventory not found"))
+	mock.ExpectQuery("^SELECT I.(.+), P.SKU, L.(.+) FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID WHERE P.SKU = \\? ORDER BY L.Warehouse, L.Aisle, L.Bin$").WillReturnError(fmt.Errorf("404 - Inventory not found"))
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -157,7 +162,7 @@ func TestGetInventoryInvalidID(t *testing.T) {
 // 	mock.ExpectQuery("^SELECT (.+) FROM Inventory INNER JOIN Product ON Inventory.ProductID = Product.ProductID WHERE SKU = \\?$").WillReturnRows(rows)
 // 	mock.ExpectCommit()
 
-// 	router := routes.InitRoutes(models.Env{db})
+// 	router := newRouter(db)
 
 // 	router.ServeHTTP(w, req)
 
@@ -194,7 +199,7 @@ func TestGetInventoryNegativeID(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -229,15 +234,17 @@ func TestUpdateInventory(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "productid", "deleted"}).
-		AddRow(1, 50, "11/17/2017", 1, 0)
+	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "deleted", "productid", "locationid", "sku", "warehouse", "aisle", "bin"}).
+		AddRow(1, 10, "11/17/2017", 0, 1, 1, 1, "MAIN", "", "")
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT I.(.+), P.SKU FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID WHERE P.SKU = \\?$").WillReturnRows(rows)
-	mock.ExpectExec("^UPDATE Inventory SET InventoryID = \\?, Quantity = \\?, DateLastUpdated = \\?, Deleted = \\?, ProductID = \\? WHERE InventoryID = \\?$").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT InventoryID FROM Inventory WHERE ProductID IN \\(SELECT ProductID FROM Product WHERE SKU = \\?\\) AND LocationID = \\? FOR UPDATE$").WillReturnRows(sqlmock.NewRows([]string{"inventoryid"}).AddRow(1))
+	mock.ExpectQuery("^SELECT I.(.+), P.SKU, L.(.+) FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID WHERE I.InventoryID = \\?$").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectExec("^UPDATE Inventory SET Quantity = \\?, DateLastUpdated = \\? WHERE InventoryID = \\?$").WithArgs(50, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO InventoryMovement \\(InventoryID, Delta, Quantity, Reason, Note, UserName, DateCreated\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)$").WithArgs(1, 40, 50, "count-correction", "", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -271,9 +278,11 @@ func TestUpdateInventoryInvalidID(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT I.(.+), P.SKU FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID WHERE P.SKU = \\?$").WillReturnError(fmt.Errorf("404 - Inventory not found"))
+	mock.ExpectQuery("^SELECT InventoryID FROM Inventory WHERE ProductID IN \\(SELECT ProductID FROM Product WHERE SKU = \\?\\) AND LocationID = \\? FOR UPDATE$").WithArgs(800, 1).WillReturnRows(sqlmock.NewRows([]string{"inventoryid"}))
+	// no inventory row there, so the store checks whether the product exists before creating one
+	mock.ExpectQuery("^SELECT ProductID, (.+) FROM Product WHERE SKU = \\?$").WithArgs(800).WillReturnRows(sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid"}))
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -307,15 +316,17 @@ func TestIncrementInventory(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "productid", "deleted"}).
-		AddRow(1, 11, "11/17/2017", 1, 0)
+	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "deleted", "productid", "locationid", "sku", "warehouse", "aisle", "bin"}).
+		AddRow(1, 10, "11/17/2017", 0, 1, 1, 1, "MAIN", "", "")
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT I.(.+), P.SKU FROM Inventory I INNER JOIN Product P on P.ProductID = I.ProductID WHERE P.SKU = \\?$").WillReturnRows(rows)
-	mock.ExpectExec("^UPDATE Inventory SET InventoryID = \\?, Quantity = \\?, DateLastUpdated = \\?, Deleted = \\?, ProductID = \\? WHERE InventoryID = \\?$").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT InventoryID FROM Inventory WHERE ProductID IN \\(SELECT ProductID FROM Product WHERE SKU = \\?\\) AND LocationID = \\? FOR UPDATE$").WillReturnRows(sqlmock.NewRows([]string{"inventoryid"}).AddRow(1))
+	mock.ExpectQuery("^SELECT I.(.+), P.SKU, L.(.+) FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID WHERE I.InventoryID = \\?$").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectExec("^UPDATE Inventory SET Quantity = Quantity \\+ \\?, DateLastUpdated = \\? WHERE InventoryID = \\?$").WithArgs(1, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO InventoryMovement \\(InventoryID, Delta, Quantity, Reason, Note, UserName, DateCreated\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)$").WithArgs(1, 1, 11, "receive", "", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -349,15 +360,25 @@ func TestDecrementInventory(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "productid", "deleted"}).
-		AddRow(1, 9, "11/17/2017", 1, 0)
+	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "deleted", "productid", "locationid", "sku", "warehouse", "aisle", "bin"}).
+		AddRow(1, 10, "11/17/2017", 0, 1, 1, 1, "MAIN", "", "")
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT I.(.+), P.SKU FROM Inventory I INNER JOIN Product P on P.ProductID = I.ProductID WHERE P.SKU = \\?$").WillReturnRows(rows)
-	mock.ExpectExec("^UPDATE Inventory SET InventoryID = \\?, Quantity = \\?, DateLastUpdated = \\?, Deleted = \\?, ProductID = \\? WHERE InventoryID = \\?$").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT InventoryID FROM Inventory WHERE ProductID IN \\(SELECT ProductID FROM Product WHERE SKU = \\?\\) AND LocationID = \\? FOR UPDATE$").WillReturnRows(sqlmock.NewRows([]string{"inventoryid"}).AddRow(1))
+	mock.ExpectQuery("^SELECT I.(.+), P.SKU, L.(.+) FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID WHERE I.InventoryID = \\?$").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT COALESCE\\(SUM\\(Quantity\\), 0\\) FROM Reservation WHERE ProductID = \\? AND LocationID = \\? AND Status = 'active' AND ExpiresAt > \\?$").WithArgs(1, 1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(0))
+	mock.ExpectExec("^UPDATE Inventory SET Quantity = Quantity \\+ \\?, DateLastUpdated = \\? WHERE InventoryID = \\?$").WithArgs(-1, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO InventoryMovement \\(InventoryID, Delta, Quantity, Reason, Note, UserName, DateCreated\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)$").WithArgs(1, -1, 9, "sale", "", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	// the total on hand after the change, for the low stock alert
+	mock.ExpectQuery("^SELECT COALESCE\\(SUM\\(Quantity\\), 0\\) FROM Inventory WHERE ProductID = \\? AND Deleted = 0$").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"onhand"}).AddRow(9))
+	mock.ExpectCommit()
+	// the product is looked up for its notification quantity
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT P.(.+) WHERE P.SKU = \\?$").WithArgs(sqlmock.AnyArg(), 1).WillReturnRows(sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid", "quantity", "reserved"}).
+		AddRow(1, "Swing", 2, "Red", "Black", "Large", 129.99, "4' wide", 1, 0, "", 0, 9, nil))
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -371,3 +392,104 @@ func TestDecrementInventory(t *testing.T) {
 		t.Errorf("there were unfulfilled expections: %s", err)
 	}
 }
+
+func TestAdjustInventory(t *testing.T) {
+	data := []byte(`{"delta":240,"reason":"receive","note":"pallet of chains","user":"sam"}`)
+
+	req, err := http.NewRequest("POST", "/inventory/adjust/1", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "deleted", "productid", "locationid", "sku", "warehouse", "aisle", "bin"}).
+		AddRow(1, 10, "11/17/2017", 0, 1, 1, 1, "MAIN", "", "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT InventoryID FROM Inventory WHERE ProductID IN \\(SELECT ProductID FROM Product WHERE SKU = \\?\\) AND LocationID = \\? FOR UPDATE$").WillReturnRows(sqlmock.NewRows([]string{"inventoryid"}).AddRow(1))
+	mock.ExpectQuery("^SELECT I.(.+), P.SKU, L.(.+) FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID WHERE I.InventoryID = \\?$").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectExec("^UPDATE Inventory SET Quantity = Quantity \\+ \\?, DateLastUpdated = \\? WHERE InventoryID = \\?$").WithArgs(240, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO InventoryMovement (.+)$").WithArgs(1, 240, 250, "receive", "pallet of chains", "sam", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	router := newRouter(db)
+
+	router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil || m["quantity"] != 250.0 || m["delta"] != 240.0 {
+		t.Errorf("handler returned unexpected body: %v", w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expections: %s", err)
+	}
+}
+
+func TestAdjustInventoryZeroDelta(t *testing.T) {
+	req, err := http.NewRequest("POST", "/inventory/adjust/1", bytes.NewBuffer([]byte(`{"reason":"receive"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	w := httptest.NewRecorder()
+
+	router := newRouter(db)
+
+	router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestDecrementInventoryOversell(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"inventoryid", "quantity", "datelastupdated", "deleted", "productid", "locationid", "sku", "warehouse", "aisle", "bin"}).
+		AddRow(1, 0, "11/17/2017", 0, 1, 1, 1, "MAIN", "", "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT InventoryID FROM Inventory WHERE ProductID IN \\(SELECT ProductID FROM Product WHERE SKU = \\?\\) AND LocationID = \\? FOR UPDATE$").WillReturnRows(sqlmock.NewRows([]string{"inventoryid"}).AddRow(1))
+	mock.ExpectQuery("^SELECT I.(.+), P.SKU, L.(.+) FROM Inventory I INNER JOIN Product P ON P.ProductID = I.ProductID INNER JOIN Location L ON L.LocationID = I.LocationID WHERE I.InventoryID = \\?$").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT COALESCE\\(SUM\\(Quantity\\), 0\\) FROM Reservation WHERE ProductID = \\? AND LocationID = \\? AND Status = 'active' AND ExpiresAt > \\?$").WithArgs(1, 1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(0))
+	mock.ExpectQuery("^SELECT NegativeStock FROM Product WHERE ProductID = \\?$").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"negativestock"}).AddRow(""))
+	mock.ExpectRollback()
+
+	router := newRouter(db)
+	w := serve(router, "POST", "/inventory/decrement/1", nil)
+
+	if status := w.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+	expected := `{"sku":1,"locationid":1,"quantity":0,"requested":-1}`
+	if equal, _ := AreEqualJSON(w.Body.String(), expected); !equal {
+		t.Errorf("handler returned unexpected body: got %v want %v", w.Body.String(), expected)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expections: %s", err)
+	}
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -36,16 +37,16 @@ func TestGetProducts(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "quantity"}).
-		AddRow(1, "Firefighter Wallet", 10, "Tan", "Black", "size", 30, "3 1/2\" tall and 4 1/2\" long", 1, 0, 10).
-		AddRow(2, "Firefighter Apron", 20, "Tan", "Black", "One Size Fits All", 29, "31\" tall and 26\" wide and ties around a waist up to 54\"", 2, 0, 10).
-		AddRow(3, "Firefighter Baby Outfit", 13, "Tan", "Black", "Newborn", 39.99, "Waist-14\", Length-10\"", 3, 0, 10)
+	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid", "quantity", "reserved"}).
+		AddRow(1, "Firefighter Wallet", 10, "Tan", "Black", "size", 30, "3 1/2\" tall and 4 1/2\" long", 1, 0, "", 0, 10, nil).
+		AddRow(2, "Firefighter Apron", 20, "Tan", "Black", "One Size Fits All", 29, "31\" tall and 26\" wide and ties around a waist up to 54\"", 2, 0, "", 0, 10, nil).
+		AddRow(3, "Firefighter Baby Outfit", 13, "Tan", "Black", "Newborn", 39.99, "Waist-14\", Length-10\"", 3, 0, "", 0, 10, nil)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT P.(.+), I.Quantity FROM Product P LEFT JOIN Inventory I ON P.ProductID = I.ProductID$").WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT P.(.+), I.Quantity, R.Quantity FROM Product P LEFT JOIN \\(SELECT ProductID, SUM\\(Quantity\\) AS Quantity FROM Inventory WHERE Deleted = 0 GROUP BY ProductID\\) I ON P.ProductID = I.ProductID LEFT JOIN \\(SELECT ProductID, SUM\\(Quantity\\) AS Quantity FROM Reservation WHERE (.+) GROUP BY ProductID\\) R ON P.ProductID = R.ProductID$").WillReturnRows(rows)
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -55,7 +56,7 @@ func TestGetProducts(t *testing.T) {
 	}
 
 	// Check the response body is what we expect.
-	expected := `[{"productid":1,"productname":"Firefighter Wallet","notificationquantity":10,"color":"Tan","trimcolor":"Black","size":"size","price":30,"dimensions":"3 1/2\" tall and 4 1/2\" long","sku":1, "quantity":10},{"productid":2,"productname":"Firefighter Apron","notificationquantity":20,"color":"Tan","trimcolor":"Black","size":"One Size Fits All","price":29,"dimensions":"31\" tall and 26\" wide and ties around a waist up to 54\"","sku":2, "quantity":10},{"productid":3,"productname":"Firefighter Baby Outfit","notificationquantity":13,"color":"Tan","trimcolor":"Black","size":"Newborn","price":39.99,"dimensions":"Waist-14\", Length-10\"","sku":3, "quantity":10}]`
+	expected := `[{"productid":1,"productname":"Firefighter Wallet","notificationquantity":10,"color":"Tan","trimcolor":"Black","size":"size","price":30,"dimensions":"3 1/2\" tall and 4 1/2\" long","sku":1, "onhand":10, "reserved":0, "available":10},{"productid":2,"productname":"Firefighter Apron","notificationquantity":20,"color":"Tan","trimcolor":"Black","size":"One Size Fits All","price":29,"dimensions":"31\" tall and 26\" wide and ties around a waist up to 54\"","sku":2, "onhand":10, "reserved":0, "available":10},{"productid":3,"productname":"Firefighter Baby Outfit","notificationquantity":13,"color":"Tan","trimcolor":"Black","size":"Newborn","price":39.99,"dimensions":"Waist-14\", Length-10\"","sku":3, "onhand":10, "reserved":0, "available":10}]`
 	equal, err := AreEqualJSON(w.Body.String(), expected)
 	if !equal {
 		t.Errorf("handler returned unexpected body: got %v want %v", w.Body.String(), expected)
@@ -83,13 +84,13 @@ func TestGetProduct(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "quantity"}).
-		AddRow(1, "Firefighter Wallet", 10, "Tan", "Black", "size", 30, "3 1/2\" tall and 4 1/2\" long", 1, 0, 10)
+	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid", "quantity", "reserved"}).
+		AddRow(1, "Firefighter Wallet", 10, "Tan", "Black", "size", 30, "3 1/2\" tall and 4 1/2\" long", 1, 0, "", 0, 10, nil)
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT P.(.+), I.Quantity FROM Product P LEFT JOIN Inventory I ON P.ProductID = I.ProductID WHERE P.SKU = \\?$").WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT P.(.+), I.Quantity, R.Quantity FROM Product P LEFT JOIN \\(SELECT ProductID, SUM\\(Quantity\\) AS Quantity FROM Inventory WHERE Deleted = 0 GROUP BY ProductID\\) I ON P.ProductID = I.ProductID LEFT JOIN \\(SELECT ProductID, SUM\\(Quantity\\) AS Quantity FROM Reservation WHERE (.+) GROUP BY ProductID\\) R ON P.ProductID = R.ProductID WHERE P.SKU = \\?$").WillReturnRows(rows)
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -99,7 +100,7 @@ func TestGetProduct(t *testing.T) {
 	}
 
 	// Check the response body is what we expect.
-	expected := `[{"productid":1,"productname":"Firefighter Wallet","notificationquantity":10,"color":"Tan","trimcolor":"Black","size":"size","price":30,"dimensions":"3 1/2\" tall and 4 1/2\" long","sku":1,"quantity":10}]`
+	expected := `[{"productid":1,"productname":"Firefighter Wallet","notificationquantity":10,"color":"Tan","trimcolor":"Black","size":"size","price":30,"dimensions":"3 1/2\" tall and 4 1/2\" long","sku":1,"onhand":10,"reserved":0,"available":10}]`
 	equal, err := AreEqualJSON(w.Body.String(), expected)
 	if !equal {
 		t.Errorf("handler returned unexpected body: got %v want %v", w.Body.String(), expected)
@@ -127,9 +128,9 @@ func TestGetProductInvalidID(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT P.(.+), I.Quantity FROM Product P LEFT JOIN Inventory I ON P.ProductID = I.ProductID WHERE P.SKU = \\?$").WillReturnError(fmt.Errorf("404 - Product not found"))
+	mock.ExpectQuery("^SELECT P.(.+), I.Quantity, R.Quantity FROM Product P LEFT JOIN \\(SELECT ProductID, SUM\\(Quantity\\) AS Quantity FROM Inventory WHERE Deleted = 0 GROUP BY ProductID\\) I ON P.ProductID = I.ProductID LEFT JOIN \\(SELECT ProductID, SUM\\(Quantity\\) AS Quantity FROM Reservation WHERE (.+) GROUP BY ProductID\\) R ON P.ProductID = R.ProductID WHERE P.SKU = \\?$").WillReturnError(fmt.Errorf("404 - Product not found"))
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -161,7 +162,7 @@ func TestGetProductInvalidID(t *testing.T) {
 // 	mock.ExpectQuery("^SELECT (.+) FROM Product WHERE SKU = \\?$").WillReturnRows(rows)
 // 	mock.ExpectCommit()
 
-// 	router := routes.InitRoutes(models.Env{db})
+// 	router := newRouter(db)
 
 // 	router.ServeHTTP(w, req)
 
@@ -198,7 +199,7 @@ func TestGetProductNegativeID(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -233,10 +234,11 @@ func TestCreateProduct(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectBegin()
-	mock.ExpectExec("INSERT INTO Product \\(ProductName, NotificationQuantity, Color, TrimColor, Size, Price, Dimensions, SKU\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)").WithArgs("Firefighter Stuff", 10, "Tan", "Black", "size", 30.0, "3 1/2\" tall and 4 1/2\" long", 10).WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectExec("INSERT INTO Product \\(ProductName, NotificationQuantity, Color, TrimColor, Size, Price, Dimensions, SKU, NegativeStock, StyleID\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)").WithArgs("Firefighter Stuff", 10, "Tan", "Black", "size", 30.0, "3 1/2\" tall and 4 1/2\" long", 10, "", 0).WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectExec("INSERT INTO Inventory \\(Quantity, DateLastUpdated, Deleted, ProductID, LocationID\\) VALUES\\(\\?,\\?,\\?,\\?,\\?\\)").WithArgs(0, sqlmock.AnyArg(), 0, 10, 1).WillReturnResult(sqlmock.NewResult(10, 1))
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -274,15 +276,15 @@ func TestDeleteProduct(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted"}).
-		AddRow(2, "Swing", 10, "test", "test", "test", 1, "test", 1, 0)
+	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid"}).
+		AddRow(2, "Swing", 10, "test", "test", "test", 1, "test", 1, 0, "", 0)
 
 	mock.ExpectBegin()
 	mock.ExpectQuery("^SELECT (.+) FROM Product WHERE SKU = \\?$").WillReturnRows(rows)
 	mock.ExpectExec("^UPDATE Product SET Deleted = 1 WHERE ProductID = \\?").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -322,7 +324,7 @@ func TestDeleteProductNonExistant(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectQuery("^SELECT (.+) FROM Product WHERE SKU = \\?$").WillReturnError(fmt.Errorf("404 - Product not found"))
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -362,15 +364,15 @@ func TestUpdateProduct(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "quantity"}).
-		AddRow(2, "Swing", 10, "test", "test", "test", 1, "test", 1, 0, 10)
+	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid"}).
+		AddRow(2, "Swing", 10, "test", "test", "test", 1, "test", 1, 0, "", 0)
 
 	mock.ExpectBegin()
 	mock.ExpectQuery("^SELECT (.+) FROM Product WHERE SKU = \\?$").WillReturnRows(rows)
-	mock.ExpectExec("^UPDATE Product SET ProductName = \\?, NotificationQuantity = \\?, Color = \\?, TrimColor = \\?, Size = \\?, Price = \\?, Dimensions = \\?, SKU = \\? WHERE ProductID = \\?$").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE Product SET ProductName = \\?, NotificationQuantity = \\?, Color = \\?, TrimColor = \\?, Size = \\?, Price = \\?, Dimensions = \\?, SKU = \\?, NegativeStock = \\?, StyleID = \\? WHERE ProductID = \\?$").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -404,9 +406,9 @@ func TestUpdateProductInvalidID(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^SELECT (.+) FROM Product WHERE SKU = \\?$").WillReturnError(fmt.Errorf("404 - Product not found")) //.WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT (.+) FROM Product WHERE SKU = \\?$").WillReturnRows(sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid"}))
 
-	router := routes.InitRoutes(models.Env{db})
+	router := newRouter(db)
 
 	router.ServeHTTP(w, req)
 
@@ -421,6 +423,12 @@ func TestUpdateProductInvalidID(t *testing.T) {
 	}
 }
 
+// newRouter builds the API routes on top of a SQLStore wrapping the mock database
+func newRouter(db *sql.DB) http.Handler {
+	store := models.NewSQLStore(db, models.MySQL)
+	return routes.InitRoutes(store, store)
+}
+
 func AreEqualJSON(s1, s2 string) (bool, error) {
 	var o1 interface{}
 	var o2 interface{}
@@ -486,3 +494,74 @@ func TestWebSettings(t *testing.T) {
 	}
 
 }
+
+func TestGetProductPostgres(t *testing.T) {
+	req, err := http.NewRequest("GET", "/product/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid", "quantity", "reserved"}).
+		AddRow(1, "Firefighter Wallet", 10, "Tan", "Black", "size", 30, "3 1/2\" tall and 4 1/2\" long", 1, 0, "", 0, 10, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT P.ProductID, (.+), I.Quantity, R.Quantity FROM Product P LEFT JOIN \\(SELECT ProductID, SUM\\(Quantity\\) AS Quantity FROM Inventory WHERE Deleted = 0 GROUP BY ProductID\\) I ON P.ProductID = I.ProductID LEFT JOIN \\(SELECT ProductID, SUM\\(Quantity\\) AS Quantity FROM Reservation WHERE (.+) GROUP BY ProductID\\) R ON P.ProductID = R.ProductID WHERE P.SKU = \\$2$").WithArgs(sqlmock.AnyArg(), 1).WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	store := models.NewSQLStore(db, models.Postgres)
+	router := routes.InitRoutes(store, store)
+
+	router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expections: %s", err)
+	}
+}
+
+func TestCreateProductPostgres(t *testing.T) {
+	data := []byte(`{"productname":"Firefighter Stuff","notificationquantity":10,"color":"Tan","trimcolor":"Black","size":"size","price":30,"dimensions":"3 1/2\" tall and 4 1/2\" long","sku":10}`)
+
+	req, err := http.NewRequest("POST", "/product/create", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO Product \\(ProductName, NotificationQuantity, Color, TrimColor, Size, Price, Dimensions, SKU, NegativeStock, StyleID\\) VALUES\\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7,\\$8,\\$9,\\$10\\) RETURNING ProductID").WillReturnRows(sqlmock.NewRows([]string{"productid"}).AddRow(15))
+	mock.ExpectExec("INSERT INTO Inventory \\(Quantity, DateLastUpdated, Deleted, ProductID, LocationID\\) VALUES\\(\\$1,\\$2,\\$3,\\$4,\\$5\\)").WithArgs(0, sqlmock.AnyArg(), 0, 15, 1).WillReturnResult(sqlmock.NewResult(15, 1))
+	mock.ExpectCommit()
+
+	store := models.NewSQLStore(db, models.Postgres)
+	router := routes.InitRoutes(store, store)
+
+	router.ServeHTTP(w, req)
+
+	expected := `{"ProductId": 15}`
+	equal, err := AreEqualJSON(w.Body.String(), expected)
+	if !equal {
+		t.Errorf("handler returned unexpected body: got %v want %v", w.Body.String(), expected)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expections: %s", err)
+	}
+}
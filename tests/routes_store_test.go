@@ -0,0 +1,365 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"../models"
+	"../routes"
+	//"github.com/Xero67/web-fire-family/models"
+	//"github.com/Xero67/web-fire-family/routes"
+)
+
+// memStore is an in-memory ProductStore and InventoryStore so handlers can be tested without sqlmock
+type memStore struct {
+	products  map[int]*models.Product
+	inventory map[stockKey]*models.Inventory
+	locations map[int]*models.Location
+	movements []*models.InventoryMovement
+	barcodes  map[string]*models.Barcode
+}
+
+// stockKey addresses an inventory row by SKU and LocationID
+type stockKey struct{ sku, location int }
+
+func newMemStore() *memStore {
+	return &memStore{
+		products: map[int]*models.Product{
+			1: {ProductID: 1, ProductName: "Swing", NotificationQuantity: 2, SKU: 1, OnHand: 10, Available: 10},
+		},
+		inventory: map[stockKey]*models.Inventory{
+			{1, models.DefaultLocation}: {InventoryID: 1, Quantity: 10, ProductID: 1, SKU: 1, LocationID: models.DefaultLocation},
+		},
+		locations: map[int]*models.Location{
+			models.DefaultLocation: {LocationID: models.DefaultLocation, Warehouse: "MAIN"},
+		},
+		barcodes: map[string]*models.Barcode{},
+	}
+}
+
+// total is the quantity of sku summed over every location
+func (m *memStore) total(sku int) int {
+	total := 0
+	for k, i := range m.inventory {
+		if k.sku == sku {
+			total += i.Quantity
+		}
+	}
+	return total
+}
+
+func (m *memStore) ListProducts() ([]*models.Product, error) {
+	prods := make([]*models.Product, 0)
+	for _, p := range m.products {
+		if p.Deleted == 0 {
+			prods = append(prods, p)
+		}
+	}
+	return prods, nil
+}
+
+func (m *memStore) ListLowStock() ([]*models.Product, error) {
+	prods := make([]*models.Product, 0)
+	for sku, p := range m.products {
+		if p.Deleted == 0 && p.NotificationQuantity > 0 && m.total(sku) <= p.NotificationQuantity {
+			prods = append(prods, p)
+		}
+	}
+	return prods, nil
+}
+
+func (m *memStore) GetProduct(sku int) (*models.Product, error) {
+	p, ok := m.products[sku]
+	if !ok || p.Deleted == 1 {
+		return nil, models.ErrNotFound
+	}
+	p.OnHand, p.Available = m.total(sku), m.total(sku)
+	return p, nil
+}
+
+func (m *memStore) CreateProduct(p *models.Product) (int64, error) {
+	p.ProductID = len(m.products) + 1
+	m.products[p.SKU] = p
+	return int64(p.ProductID), nil
+}
+
+func (m *memStore) UpdateProduct(sku int, p *models.Product) error {
+	if _, err := m.GetProduct(sku); err != nil {
+		return err
+	}
+	m.products[sku] = p
+	return nil
+}
+
+func (m *memStore) ArchiveProduct(sku int) error {
+	p, err := m.GetProduct(sku)
+	if err != nil {
+		return err
+	}
+	p.Deleted = 1
+	return nil
+}
+
+func (m *memStore) FindBarcode(code string) (*models.Barcode, error) {
+	b, ok := m.barcodes[code]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return b, nil
+}
+
+func (m *memStore) ListBarcodes(sku int) ([]*models.Barcode, error) {
+	if _, err := m.GetProduct(sku); err != nil {
+		return nil, err
+	}
+	codes := make([]*models.Barcode, 0)
+	for _, b := range m.barcodes {
+		if b.SKU == sku {
+			codes = append(codes, b)
+		}
+	}
+	return codes, nil
+}
+
+func (m *memStore) AddBarcode(sku int, b *models.Barcode) error {
+	if _, err := m.GetProduct(sku); err != nil {
+		return err
+	}
+	if _, ok := m.barcodes[b.Code]; ok {
+		return models.ErrExists
+	}
+	b.SKU = sku
+	m.barcodes[b.Code] = b
+	return nil
+}
+
+func (m *memStore) DeleteBarcode(code string) error {
+	if _, ok := m.barcodes[code]; !ok {
+		return models.ErrNotFound
+	}
+	delete(m.barcodes, code)
+	return nil
+}
+
+func (m *memStore) ListInventories() ([]*models.Inventory, error) {
+	inv := make([]*models.Inventory, 0)
+	for _, i := range m.inventory {
+		inv = append(inv, i)
+	}
+	return inv, nil
+}
+
+func (m *memStore) GetInventory(sku int) ([]*models.Inventory, error) {
+	inv := make([]*models.Inventory, 0)
+	for k, i := range m.inventory {
+		if k.sku == sku {
+			inv = append(inv, i)
+		}
+	}
+	if len(inv) == 0 {
+		return nil, models.ErrNotFound
+	}
+	return inv, nil
+}
+
+// stock returns the inventory row of sku at location, creating it on first use like SQLStore does
+func (m *memStore) stock(sku int, location int) (*models.Inventory, error) {
+	if i, ok := m.inventory[stockKey{sku, location}]; ok {
+		return i, nil
+	}
+	p, err := m.GetProduct(sku)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.GetLocation(location); err != nil {
+		return nil, err
+	}
+	i := &models.Inventory{InventoryID: len(m.inventory) + 1, ProductID: p.ProductID, SKU: sku, LocationID: location}
+	m.inventory[stockKey{sku, location}] = i
+	return i, nil
+}
+
+func (m *memStore) SetInventory(sku int, location int, quantity int, move *models.InventoryMovement) error {
+	i, err := m.stock(sku, location)
+	if err != nil {
+		return err
+	}
+	return m.AdjustInventory(sku, location, quantity-i.Quantity, move)
+}
+
+func (m *memStore) AdjustInventory(sku int, location int, delta int, move *models.InventoryMovement) error {
+	i, err := m.stock(sku, location)
+	if err != nil {
+		return err
+	}
+	i.Quantity += delta
+	move.MovementID, move.SKU, move.LocationID, move.InventoryID, move.Delta, move.Quantity = len(m.movements)+1, sku, location, i.InventoryID, delta, i.Quantity
+	if delta < 0 {
+		move.OnHand = m.total(sku)
+	}
+	m.movements = append(m.movements, move)
+	return nil
+}
+
+func (m *memStore) ListLocations() ([]*models.Location, error) {
+	locs := make([]*models.Location, 0)
+	for _, l := range m.locations {
+		if l.Deleted == 0 {
+			locs = append(locs, l)
+		}
+	}
+	return locs, nil
+}
+
+func (m *memStore) GetLocation(id int) (*models.Location, error) {
+	l, ok := m.locations[id]
+	if !ok || l.Deleted == 1 {
+		return nil, models.ErrLocationNotFound
+	}
+	return l, nil
+}
+
+func (m *memStore) CreateLocation(l *models.Location) error {
+	for _, other := range m.locations {
+		if other.Code() == l.Code() {
+			return models.ErrExists
+		}
+	}
+	l.LocationID = len(m.locations) + 1
+	m.locations[l.LocationID] = l
+	return nil
+}
+
+func (m *memStore) UpdateLocation(id int, l *models.Location) error {
+	if _, err := m.GetLocation(id); err != nil {
+		return err
+	}
+	l.LocationID = id
+	m.locations[id] = l
+	return nil
+}
+
+func (m *memStore) DeleteLocation(id int) error {
+	l, err := m.GetLocation(id)
+	if err != nil {
+		return err
+	}
+	if id == models.DefaultLocation {
+		return models.ErrInUse
+	}
+	for k, i := range m.inventory {
+		if k.location == id && i.Quantity != 0 {
+			return models.ErrInUse
+		}
+	}
+	l.Deleted = 1
+	return nil
+}
+
+func (m *memStore) ListMovements(sku int, limit int, offset int) ([]*models.InventoryMovement, error) {
+	moves := make([]*models.InventoryMovement, 0)
+	for i := len(m.movements) - 1; i >= 0; i-- {
+		if m.movements[i].SKU == sku {
+			moves = append(moves, m.movements[i])
+		}
+	}
+	if offset > len(moves) {
+		offset = len(moves)
+	}
+	moves = moves[offset:]
+	if limit < len(moves) {
+		moves = moves[:limit]
+	}
+	return moves, nil
+}
+
+func (m *memStore) MovementsSince(id int, limit int) ([]*models.InventoryMovement, error) {
+	moves := make([]*models.InventoryMovement, 0)
+	for _, move := range m.movements {
+		if move.MovementID > id && len(moves) < limit {
+			moves = append(moves, move)
+		}
+	}
+	return moves, nil
+}
+
+func TestStoreIncrementInventory(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	req, err := http.NewRequest("POST", "/inventory/increment/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if q := store.total(1); q != 11 {
+		t.Errorf("quantity not incremented: got %v want %v", q, 11)
+	}
+	if len(store.movements) != 1 || store.movements[0].Reason != models.ReasonReceive {
+		t.Errorf("increment not recorded as a receive movement: %+v", store.movements)
+	}
+}
+
+func TestStoreMovementReasonFromBody(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	w := serve(router, "POST", "/inventory/decrement/1", []byte(`{"reason":"damage","note":"dropped","user":"sam"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	if m := store.movements[0]; m.Reason != "damage" || m.Note != "dropped" || m.User != "sam" || m.Delta != -1 {
+		t.Errorf("unexpected movement: %+v", m)
+	}
+
+	w = serve(router, "POST", "/inventory/decrement/1", []byte(`{"reason":"stolen"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown reason accepted: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestStoreDecrementInventoryNotFound(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	req, err := http.NewRequest("POST", "/inventory/decrement/42", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestStoreDeleteProduct(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	req, err := http.NewRequest("POST", "/product/delete/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	req, _ = http.NewRequest("GET", "/product/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if status := w.Code; status != http.StatusNotFound {
+		t.Errorf("archived product still returned: got %v want %v", status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"../routes"
+	// "github.com/Xero67/web-fire-family/routes"
+	"github.com/gorilla/websocket"
+)
+
+// scanner dials /scanner on server and returns a function that sends one message and reads one reply
+func scanner(t *testing.T, server *httptest.Server) (*websocket.Conn, func(msg string) map[string]interface{}) {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/scanner", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, func(msg string) map[string]interface{} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		var reply map[string]interface{}
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+}
+
+func TestScannerSession(t *testing.T) {
+	store := newSQLiteStore(t)
+	router := routes.InitRoutes(store, store)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":7}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Apron","sku":8}`))
+
+	conn, send := scanner(t, server)
+	defer conn.Close()
+
+	if r := send(`{"type":"scan","seq":1,"code":"7"}`); r["type"] != "error" {
+		t.Errorf("scan before hello accepted: %v", r)
+	}
+	if r := send(`{"type":"hello","mode":"receive","station":"dock-1","user":"sam"}`); r["type"] != "session" || r["mode"] != "receive" {
+		t.Fatalf("unexpected hello reply: %v", r)
+	}
+	if r := send(`{"type":"scan","seq":2,"code":"7","qty":12}`); r["type"] != "ack" || r["seq"] != 2.0 || r["productname"] != "Swing" || r["quantity"] != 12.0 {
+		t.Errorf("unexpected receive ack: %v", r)
+	}
+	if r := send(`{"type":"scan","seq":3,"code":"99"}`); r["type"] != "error" || r["seq"] != 3.0 {
+		t.Errorf("unknown product acked: %v", r)
+	}
+	if r := send(`not json`); r["type"] != "error" {
+		t.Errorf("bad message not rejected: %v", r)
+	}
+
+	send(`{"type":"hello","mode":"pick"}`)
+	if r := send(`{"type":"scan","seq":4,"code":"7"}`); r["type"] != "ack" || r["delta"] != -1.0 || r["quantity"] != 11.0 {
+		t.Errorf("unexpected pick ack: %v", r)
+	}
+	if r := send(`{"type":"scan","seq":5,"code":"8"}`); r["type"] != "error" || r["quantity"] != 0.0 {
+		t.Errorf("oversell acked: %v", r)
+	}
+
+	send(`{"type":"hello","mode":"count","user":"sam"}`)
+	send(`{"type":"scan","seq":6,"code":"7"}`)
+	if r := send(`{"type":"scan","seq":7,"code":"7","qty":2}`); r["counted"] != 3.0 || r["quantity"] != 11.0 {
+		t.Errorf("unexpected count ack: %v", r)
+	}
+	if r := send(`{"type":"commit","seq":8}`); r["type"] != "ack" || r["sku"] != 7.0 || r["quantity"] != 3.0 || r["delta"] != -8.0 {
+		t.Errorf("unexpected commit ack: %v", r)
+	}
+
+	moves, _ := store.ListMovements(7, 10, 0)
+	if len(moves) != 3 || moves[0].Reason != "count-correction" || moves[1].Reason != "sale" || moves[2].Note != "scanner dock-1" || moves[2].User != "sam" {
+		t.Errorf("unexpected movements: %+v", moves)
+	}
+}
+
+func TestScannerCountAfterSwitchingMode(t *testing.T) {
+	store := newSQLiteStore(t)
+	router := routes.InitRoutes(store, store)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":7}`))
+	serve(router, "POST", "/inventory/adjust/7", []byte(`{"delta":5}`))
+
+	conn, send := scanner(t, server)
+	defer conn.Close()
+
+	// a tally from before a pick at the same shelf would put the picked unit back on commit
+	send(`{"type":"hello","mode":"count"}`)
+	send(`{"type":"scan","seq":1,"code":"7","qty":2}`)
+	send(`{"type":"hello","mode":"pick"}`)
+	send(`{"type":"scan","seq":2,"code":"7"}`)
+	send(`{"type":"hello","mode":"count"}`)
+	if r := send(`{"type":"scan","seq":3,"code":"7"}`); r["counted"] != 1.0 || r["quantity"] != 4.0 {
+		t.Errorf("tally kept across a pick: %v", r)
+	}
+	if r := send(`{"type":"commit","seq":4}`); r["type"] != "ack" || r["quantity"] != 1.0 || r["delta"] != -3.0 {
+		t.Errorf("unexpected commit ack: %v", r)
+	}
+
+	// a repeated hello for the same count keeps counting
+	send(`{"type":"hello","mode":"count"}`)
+	send(`{"type":"scan","seq":5,"code":"7"}`)
+	send(`{"type":"hello","mode":"count"}`)
+	if r := send(`{"type":"scan","seq":6,"code":"7"}`); r["counted"] != 2.0 {
+		t.Errorf("tally dropped by the same hello: %v", r)
+	}
+}
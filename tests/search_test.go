@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestSearchProducts(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	for _, body := range []string{
+		`{"productname":"Swing","color":"Red","trimcolor":"Black","size":"Small","sku":1}`,
+		`{"productname":"Swing","color":"Red","trimcolor":"Black","size":"Large","sku":2}`,
+		`{"productname":"Swing","color":"Blue","trimcolor":"Red","size":"Large","sku":3}`,
+		`{"productname":"Firefighter Apron","color":"Tan","size":"One Size Fits All","dimensions":"31\" tall","sku":4}`,
+		`{"productname":"Wallet","color":"Red","sku":5}`,
+	} {
+		serve(router, "POST", "/product/create", []byte(body))
+	}
+	serve(router, "POST", "/product/delete/5", nil)
+
+	search := func(url string) []*models.ProductMatch {
+		var matches []*models.ProductMatch
+		w := serve(router, "GET", url, nil)
+		if err := json.Unmarshal(w.Body.Bytes(), &matches); err != nil {
+			t.Errorf("%v: got %v: %s", url, w.Code, w.Body.String())
+		}
+		return matches
+	}
+
+	// the words can come in any order, cut short or misspelt
+	for _, q := range []string{"red+swing+large", "large+swing+red", "red+swnig+larg"} {
+		matches := search("/product/search?q=" + q)
+		if len(matches) != 3 || matches[0].SKU != 2 || matches[0].Score <= matches[1].Score {
+			t.Errorf("%v: unexpected matches %+v", q, matches)
+		}
+	}
+	// a red color counts for more than a red trim
+	if matches := search("/product/search?q=red&limit=2"); len(matches) != 2 || matches[0].SKU != 1 || matches[1].SKU != 2 {
+		t.Errorf("red: unexpected matches %+v", matches)
+	}
+	if matches := search("/product/search?q=firefigter+aporn"); len(matches) != 1 || matches[0].SKU != 4 {
+		t.Errorf("firefigter aporn: unexpected matches %+v", matches)
+	}
+	if matches := search("/product/search?q=wallet"); len(matches) != 0 {
+		t.Errorf("archived product found %+v", matches)
+	}
+
+	// the index picks up changed products
+	serve(router, "POST", "/product/update/4", []byte(`{"productname":"Firefighter Wallet","color":"Tan","sku":4}`))
+	if matches := search("/product/search?q=wallet"); len(matches) != 1 || matches[0].SKU != 4 {
+		t.Errorf("wallet after update: unexpected matches %+v", matches)
+	}
+
+	for _, url := range []string{"/product/search", "/product/search?q=+", "/product/search?q=swing&limit=101"} {
+		if w := serve(router, "GET", url, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%v: got %v want %v", url, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSearchProductsMySQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// MySQL's FULLTEXT index hands over the candidates, which are ranked the same as everywhere else
+	candidates := sqlmock.NewRows([]string{"productid", "productname", "color", "trimcolor", "size", "dimensions"}).
+		AddRow(1, "Swing", "Red", "Black", "Small", "").
+		AddRow(2, "Swing", "Red", "Black", "Large", "")
+	rows := sqlmock.NewRows([]string{"productid", "productname", "notificationquantity", "color", "trimcolor", "size", "price", "dimensions", "sku", "deleted", "negativestock", "styleid", "quantity", "reserved"}).
+		AddRow(1, "Swing", 0, "Red", "Black", "Small", 99.99, "", 1, 0, "", 0, 4, nil).
+		AddRow(2, "Swing", 0, "Red", "Black", "Large", 129.99, "", 2, 0, "", 0, 2, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT ProductID, ProductName, Color, TrimColor, Size, Dimensions FROM Product WHERE Deleted = 0 AND MATCH \\(ProductName, Color, TrimColor, Size, Dimensions\\) AGAINST \\(\\?\\) ORDER BY (.+) DESC LIMIT \\?$").
+		WithArgs("swing large", "swing large", 200).WillReturnRows(candidates)
+	mock.ExpectQuery("^SELECT P.(.+) WHERE P.Deleted = 0 AND P.ProductID IN \\(\\?,\\?\\)$").WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	var matches []*models.ProductMatch
+	w := serve(newRouter(db), "GET", "/product/search?q=swing+large", nil)
+	json.Unmarshal(w.Body.Bytes(), &matches)
+	if w.Code != http.StatusOK || len(matches) != 2 || matches[0].SKU != 2 || matches[0].OnHand != 2 {
+		t.Errorf("unexpected matches %v %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expections: %s", err)
+	}
+}
+
+func TestSearchNotEnabled(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	if w := serve(router, "GET", "/product/search?q=swing", nil); w.Code != http.StatusNotFound {
+		t.Errorf("search without a search store: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"../app"
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/app"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+// newSQLiteStore opens a fresh, migrated SQLite file in a temp directory
+func newSQLiteStore(t *testing.T) *models.SQLStore {
+	Dbdriver := app.Dbdriver{Driver: "sqlite", Database: filepath.Join(t.TempDir(), "test.db")}
+	db, err := models.InitDB(&Dbdriver)
+	if err != nil {
+		t.Fatalf("unable to open sqlite database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return models.NewSQLStore(db, models.SQLite)
+}
+
+// newSQLiteRouter builds the API routes against a fresh SQLite file in a temp directory
+func newSQLiteRouter(t *testing.T) http.Handler {
+	store := newSQLiteStore(t)
+	return routes.InitRoutes(store, store)
+}
+
+func serve(router http.Handler, method string, url string, body []byte) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSQLiteProductAndInventory(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	data := []byte(`{"productname":"Swing","notificationquantity":2,"color":"Red","trimcolor":"Black","size":"Large","price":129.99,"dimensions":"4' wide","sku":7}`)
+	if w := serve(router, "POST", "/product/create", data); w.Code != http.StatusOK {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+
+	serve(router, "POST", "/inventory/update/7/5", nil)
+	serve(router, "POST", "/inventory/increment/7", nil)
+	serve(router, "POST", "/inventory/increment/7", nil)
+	serve(router, "POST", "/inventory/decrement/7", nil)
+
+	w := serve(router, "GET", "/product/7", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get returned %v: %s", w.Code, w.Body.String())
+	}
+	var prods []models.Product
+	if err := json.Unmarshal(w.Body.Bytes(), &prods); err != nil {
+		t.Fatal(err)
+	}
+	if len(prods) != 1 || prods[0].ProductName != "Swing" || prods[0].OnHand != 6 {
+		t.Errorf("unexpected product: %+v", prods)
+	}
+
+	w = serve(router, "GET", "/inventory/7/movements?limit=3", nil)
+	var moves []models.InventoryMovement
+	if err := json.Unmarshal(w.Body.Bytes(), &moves); err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 3 || moves[0].Delta != -1 || moves[0].Quantity != 6 || moves[0].Reason != models.ReasonSale || moves[2].Reason != models.ReasonReceive {
+		t.Errorf("unexpected movements: %+v", moves)
+	}
+	w = serve(router, "GET", "/inventory/7/movements?offset=3", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &moves); err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].Delta != 5 || moves[0].Reason != models.ReasonCountCorrection {
+		t.Errorf("unexpected movements: %+v", moves)
+	}
+
+	if w := serve(router, "POST", "/product/delete/7", nil); w.Code != http.StatusOK {
+		t.Errorf("delete returned %v: %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, "GET", "/product", nil); w.Body.String() != "[]\n" {
+		t.Errorf("archived product still listed: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestNegativeStockPolicies(t *testing.T) {
+	store := newSQLiteStore(t)
+	router := routes.InitRoutes(store, store)
+
+	// sku 1 follows the store default (reject), 2 clamps and 3 allows backorders
+	for _, data := range []string{
+		`{"productname":"Swing","sku":1}`,
+		`{"productname":"Apron","sku":2,"negativestock":"clamp"}`,
+		`{"productname":"Wallet","sku":3,"negativestock":"allow"}`,
+	} {
+		if w := serve(router, "POST", "/product/create", []byte(data)); w.Code != http.StatusOK {
+			t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+		}
+	}
+	for _, sku := range []string{"1", "2", "3"} {
+		serve(router, "POST", "/inventory/update/"+sku+"/2", nil)
+	}
+
+	w := serve(router, "POST", "/inventory/adjust/1", []byte(`{"delta":-5}`))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("oversell returned %v, want %v: %s", w.Code, http.StatusConflict, w.Body.String())
+	}
+	var stockErr models.StockError
+	if err := json.Unmarshal(w.Body.Bytes(), &stockErr); err != nil {
+		t.Fatal(err)
+	}
+	if stockErr.SKU != 1 || stockErr.Quantity != 2 || stockErr.Requested != -5 {
+		t.Errorf("unexpected conflict body: %+v", stockErr)
+	}
+	if w := serve(router, "POST", "/inventory/update/1/-1", nil); w.Code != http.StatusConflict {
+		t.Errorf("negative count returned %v, want %v", w.Code, http.StatusConflict)
+	}
+
+	w = serve(router, "POST", "/inventory/adjust/2", []byte(`{"delta":-5}`))
+	var m models.InventoryMovement
+	if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Delta != -2 || m.Quantity != 0 {
+		t.Errorf("clamped movement: got delta %v quantity %v, want -2 and 0", m.Delta, m.Quantity)
+	}
+
+	serve(router, "POST", "/inventory/adjust/3", []byte(`{"delta":-5}`))
+
+	want := map[int]int{1: 2, 2: 0, 3: -3}
+	inv, err := store.ListInventories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range inv {
+		if i.Quantity != want[i.SKU] {
+			t.Errorf("sku %v: got quantity %v, want %v", i.SKU, i.Quantity, want[i.SKU])
+		}
+	}
+
+	// The store default only applies to products without a policy of their own
+	store.NegativeStock = models.NegativeStockAllow
+	if w := serve(router, "POST", "/inventory/decrement/1", nil); w.Code != http.StatusOK {
+		t.Errorf("decrement with allow default returned %v", w.Code)
+	}
+	if w := serve(router, "POST", "/inventory/decrement/2", nil); w.Code != http.StatusOK {
+		t.Errorf("clamped decrement returned %v", w.Code)
+	}
+	if p, _ := store.GetProduct(2); p.OnHand != 0 {
+		t.Errorf("clamped product went to %v", p.OnHand)
+	}
+
+	if w := serve(router, "POST", "/product/create", []byte(`{"productname":"Hat","sku":4,"negativestock":"maybe"}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown policy accepted: got %v", w.Code)
+	}
+}
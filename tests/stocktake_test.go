@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestStocktake(t *testing.T) {
+	store := newSQLiteStore(t)
+	router := routes.InitRoutes(store, store)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":5,"price":100}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Apron","sku":6,"price":10}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Hammock","sku":7}`))
+	serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":10}`))
+	serve(router, "POST", "/inventory/adjust/6", []byte(`{"delta":4}`))
+	serve(router, "POST", "/inventory/adjust/7", []byte(`{"delta":2}`))
+	serve(router, "POST", "/barcodes/create", []byte(`{"code":"036000291452","sku":6}`))
+
+	w := serve(router, "POST", "/stocktakes/create", []byte(`{"location":1,"skus":[5,6],"note":"Q4","user":"sam"}`))
+	var st models.Stocktake
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil || st.StocktakeID == 0 || st.Status != models.StocktakeOpen || len(st.SKUs) != 2 {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+	id := strconv.Itoa(st.StocktakeID)
+
+	w = serve(router, "POST", "/stocktakes/"+id+"/counts", []byte(`{"counts":[{"sku":5,"counted":8}],"user":"sam"}`))
+	var counts []models.StocktakeCount
+	if json.Unmarshal(w.Body.Bytes(), &counts); w.Code != http.StatusOK || len(counts) != 1 || counts[0].Expected != 10 || counts[0].LocationID != 1 {
+		t.Fatalf("counts returned %v: %s", w.Code, w.Body.String())
+	}
+	// a sale after the count isn't undone by posting it
+	serve(router, "POST", "/inventory/decrement/5", nil)
+
+	var count models.StocktakeCount
+	for i := 0; i < 2; i++ {
+		w = serve(router, "POST", "/stocktakes/"+id+"/scan/036000291452", nil)
+	}
+	if json.Unmarshal(w.Body.Bytes(), &count); count.SKU != 6 || count.Counted != 2 || count.Expected != 4 {
+		t.Errorf("scan returned %v: %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, "POST", "/stocktakes/"+id+"/counts", []byte(`{"counts":[{"sku":7,"counted":1}]}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("counting outside the scope: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+
+	var report models.VarianceReport
+	json.Unmarshal(serve(router, "GET", "/stocktakes/"+id+"/variance", nil).Body.Bytes(), &report)
+	if report.Counted != 2 || report.Uncounted != 0 || report.Variance != -4 || report.Value != -220 || len(report.Lines) != 2 {
+		t.Fatalf("unexpected variance report %+v", report)
+	}
+	if l := report.Lines[0]; l.SKU != 5 || l.ProductName != "Swing" || l.Expected != 10 || l.Counted != 8 || l.Variance != -2 {
+		t.Errorf("unexpected variance line %+v", l)
+	}
+
+	w = serve(router, "POST", "/stocktakes/post/"+id, []byte(`{"user":"sam"}`))
+	if json.Unmarshal(w.Body.Bytes(), &st); w.Code != http.StatusOK || st.Status != models.StocktakePosted || st.DateClosed == "" {
+		t.Fatalf("post returned %v: %s", w.Code, w.Body.String())
+	}
+	for sku, want := range map[int]int{5: 7, 6: 2, 7: 2} {
+		inv, _ := store.GetInventory(sku)
+		if inv[0].Quantity != want {
+			t.Errorf("sku %v: quantity %v after posting, want %v", sku, inv[0].Quantity, want)
+		}
+	}
+	moves, _ := store.ListMovements(5, 1, 0)
+	if moves[0].Reason != models.ReasonCountCorrection || moves[0].Delta != -2 || moves[0].Note != "stocktake "+id || moves[0].User != "sam" {
+		t.Errorf("unexpected movement %+v", moves[0])
+	}
+	for _, url := range []string{"/stocktakes/post/" + id, "/stocktakes/cancel/" + id, "/stocktakes/" + id + "/scan/5"} {
+		if w := serve(router, "POST", url, nil); w.Code != http.StatusConflict {
+			t.Errorf("%s on a posted stocktake: got %v want %v", url, w.Code, http.StatusConflict)
+		}
+	}
+
+	// everything is counted, and a refused variance leaves every line alone
+	json.Unmarshal(serve(router, "POST", "/stocktakes/create", nil).Body.Bytes(), &st)
+	id = strconv.Itoa(st.StocktakeID)
+	serve(router, "POST", "/stocktakes/"+id+"/counts", []byte(`{"counts":[{"sku":5,"counted":9},{"sku":6,"counted":0}]}`))
+	json.Unmarshal(serve(router, "GET", "/stocktakes/"+id+"/variance", nil).Body.Bytes(), &report)
+	if report.Uncounted != 1 || report.Lines[2].SKU != 7 || !report.Lines[2].Uncounted || report.Lines[2].Expected != 2 {
+		t.Errorf("unexpected uncounted lines %+v", report.Lines)
+	}
+	serve(router, "POST", "/inventory/adjust/6", []byte(`{"delta":-2}`))
+	if w := serve(router, "POST", "/stocktakes/post/"+id, nil); w.Code != http.StatusConflict {
+		t.Errorf("posting a variance that oversells: got %v want %v", w.Code, http.StatusConflict)
+	}
+	if inv, _ := store.GetInventory(5); inv[0].Quantity != 7 {
+		t.Errorf("refused stocktake changed sku 5 to %v", inv[0].Quantity)
+	}
+	var open []models.Stocktake
+	json.Unmarshal(serve(router, "GET", "/stocktakes?status=open", nil).Body.Bytes(), &open)
+	if len(open) != 1 || open[0].StocktakeID != st.StocktakeID {
+		t.Errorf("unexpected open stocktakes %+v", open)
+	}
+
+	for url, want := range map[string]int{
+		"/stocktakes/create":              http.StatusNotFound,
+		"/stocktakes/" + id + "/counts":   http.StatusBadRequest,
+		"/stocktakes/99/counts":           http.StatusNotFound,
+		"/stocktakes/" + id + "/scan/abc": http.StatusNotFound,
+	} {
+		body := []byte(`{"skus":[42]}`)
+		if url != "/stocktakes/create" {
+			body = []byte(`{"counts":[{"sku":5}]}`)
+		}
+		if w := serve(router, "POST", url, body); w.Code != want {
+			t.Errorf("%s: got %v want %v", url, w.Code, want)
+		}
+	}
+}
+
+func TestStocktakeScannerSession(t *testing.T) {
+	store := newSQLiteStore(t)
+	router := routes.InitRoutes(store, store)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":7}`))
+	serve(router, "POST", "/inventory/adjust/7", []byte(`{"delta":5}`))
+	st := &models.Stocktake{}
+	if err := store.CreateStocktake(st); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, send := scanner(t, server)
+	defer conn.Close()
+
+	if r := send(`{"type":"hello","mode":"pick","stocktake":` + strconv.Itoa(st.StocktakeID) + `}`); r["type"] != "error" {
+		t.Errorf("pick session joined a stocktake: %v", r)
+	}
+	if r := send(`{"type":"hello","mode":"count","user":"sam","stocktake":` + strconv.Itoa(st.StocktakeID) + `}`); r["type"] != "session" || r["stocktake"] != float64(st.StocktakeID) {
+		t.Fatalf("unexpected hello reply: %v", r)
+	}
+	send(`{"type":"scan","seq":1,"code":"7"}`)
+	if r := send(`{"type":"scan","seq":2,"code":"7","qty":2}`); r["type"] != "ack" || r["counted"] != 3.0 || r["quantity"] != 5.0 {
+		t.Errorf("unexpected count ack: %v", r)
+	}
+	if r := send(`{"type":"commit","seq":3}`); r["type"] != "error" {
+		t.Errorf("commit in a stocktake session accepted: %v", r)
+	}
+	if inv, _ := store.GetInventory(7); inv[0].Quantity != 5 {
+		t.Errorf("scans changed the quantity to %v before posting", inv[0].Quantity)
+	}
+	report, _ := store.Variance(st.StocktakeID)
+	if len(report.Lines) != 1 || report.Lines[0].Counted != 3 || report.Variance != -2 {
+		t.Errorf("unexpected variance report %+v", report)
+	}
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+type streamEvent struct {
+	id string
+	m  models.InventoryMovement
+}
+
+// openStream connects to /inventory/stream and returns the events it sends. The request has been
+// answered, so the server is subscribed, by the time it returns.
+func openStream(t *testing.T, url string, lastEventID string) (<-chan streamEvent, func()) {
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream answered %v %v", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan streamEvent, 16)
+	go func() {
+		defer close(events)
+		var e streamEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.m)
+			case line == "" && e.id != "":
+				events <- e
+				e = streamEvent{}
+			}
+		}
+	}()
+	return events, func() { resp.Body.Close() }
+}
+
+func nextEvent(t *testing.T, events <-chan streamEvent) streamEvent {
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("stream closed")
+		}
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+	}
+	return streamEvent{}
+}
+
+func TestInventoryStream(t *testing.T) {
+	router := newSQLiteRouter(t)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":7}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Apron","sku":8}`))
+
+	events, stop := openStream(t, server.URL+"/inventory/stream?sku=7", "")
+	serve(router, "POST", "/inventory/increment/8", nil)
+	serve(router, "POST", "/inventory/adjust/7", []byte(`{"delta":5}`))
+	e := nextEvent(t, events)
+	if e.m.SKU != 7 || e.m.Quantity != 5 || e.id != strconv.Itoa(e.m.MovementID) {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+	stop()
+
+	// missed while disconnected
+	serve(router, "POST", "/inventory/decrement/7", nil)
+	serve(router, "POST", "/inventory/increment/8", nil)
+	serve(router, "POST", "/inventory/decrement/7", nil)
+
+	events, stop = openStream(t, server.URL+"/inventory/stream?sku=7", e.id)
+	defer stop()
+	for _, want := range []int{4, 3} {
+		if got := nextEvent(t, events); got.m.SKU != 7 || got.m.Quantity != want {
+			t.Errorf("replayed event: got %+v want quantity %v", got, want)
+		}
+	}
+	serve(router, "POST", "/inventory/increment/7", nil)
+	if got := nextEvent(t, events); got.m.Quantity != 4 {
+		t.Errorf("live event after replay: got %+v want quantity 4", got)
+	}
+}
+
+// lateStore numbers movements like a database where the transaction that took MovementID 1 commits after
+// the ones that took 2 and up
+type lateStore struct {
+	*memStore
+	late bool
+}
+
+func (s *lateStore) AdjustInventory(sku int, location int, delta int, move *models.InventoryMovement) error {
+	if err := s.memStore.AdjustInventory(sku, location, delta, move); err != nil {
+		return err
+	}
+	if s.late {
+		move.MovementID = 1
+	} else {
+		move.MovementID++
+	}
+	return nil
+}
+
+func TestInventoryStreamLateCommit(t *testing.T) {
+	store := &lateStore{memStore: newMemStore()}
+	router := routes.InitRoutes(store, store)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	serve(router, "POST", "/inventory/adjust/1", []byte(`{"delta":1}`))
+	serve(router, "POST", "/inventory/adjust/1", []byte(`{"delta":1}`))
+	events, stop := openStream(t, server.URL+"/inventory/stream", "0")
+	defer stop()
+	for _, want := range []string{"2", "3"} {
+		if got := nextEvent(t, events); got.id != want {
+			t.Errorf("replayed event: got %v want %v", got.id, want)
+		}
+	}
+
+	// lower than everything replayed, but the client has never seen it
+	store.late = true
+	serve(router, "POST", "/inventory/adjust/1", []byte(`{"delta":1}`))
+	if got := nextEvent(t, events); got.id != "1" {
+		t.Errorf("late commit: got event %v want 1", got.id)
+	}
+}
+
+func TestInventoryStreamInvalid(t *testing.T) {
+	router := routes.InitRoutes(newMemStore(), newMemStore())
+	if w := serve(router, "GET", "/inventory/stream?sku=abc", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("bad sku filter: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+	req, _ := http.NewRequest("GET", "/inventory/stream", nil)
+	req.Header.Set("Last-Event-ID", "nope")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad Last-Event-ID: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestStyleVariants(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Wallet","sku":40}`))
+	var st models.Style
+	w := serve(router, "POST", "/styles/create", []byte(`{"stylename":"Swing","price":129.99,"notificationquantity":2,"colors":["Red","Blue","Green"],"trimcolors":["Black","White"],"sizes":["Small","Large"]}`))
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil || st.StyleID < 1 {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+
+	var created []*models.Product
+	json.Unmarshal(serve(router, "POST", "/styles/1/variants", nil).Body.Bytes(), &created)
+	if len(created) != 12 {
+		t.Fatalf("generated %v variants, want 12", len(created))
+	}
+	// SKUs follow the highest one in use, colors vary slowest
+	first, last := created[0], created[11]
+	if first.SKU != 41 || first.Color != "Red" || first.TrimColor != "Black" || first.Size != "Small" || first.ProductName != "Swing (Red, Black, Small)" || first.Price != 129.99 || first.StyleID != 1 {
+		t.Errorf("unexpected first variant %+v", first)
+	}
+	if last.SKU != 52 || last.Color != "Green" || last.TrimColor != "White" || last.Size != "Large" {
+		t.Errorf("unexpected last variant %+v", last)
+	}
+
+	// a variant is an ordinary product with its own stock
+	serve(router, "POST", "/inventory/adjust/45", []byte(`{"delta":3}`))
+	var prods []*models.Product
+	json.Unmarshal(serve(router, "GET", "/product/45", nil).Body.Bytes(), &prods)
+	if len(prods) != 1 || prods[0].OnHand != 3 || prods[0].StyleID != 1 {
+		t.Errorf("unexpected variant %+v", prods)
+	}
+
+	// adding a size only makes the products for that size
+	w = serve(router, "POST", "/styles/update/1", []byte(`{"stylename":"Swing","price":139.99,"colors":["Red","Blue","Green"],"trimcolors":["Black","White"],"sizes":["Small","Large","XL"]}`))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("update returned %v: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(serve(router, "POST", "/styles/1/variants", nil).Body.Bytes(), &created)
+	if len(created) != 6 || created[0].SKU != 53 || created[0].Size != "XL" || created[0].Price != 139.99 {
+		t.Errorf("unexpected variants after adding a size %+v", created)
+	}
+	json.Unmarshal(serve(router, "GET", "/styles/1", nil).Body.Bytes(), &st)
+	if len(st.Variants) != 18 || len(st.Sizes) != 3 || st.Sizes[2] != "XL" {
+		t.Errorf("unexpected style %+v", st)
+	}
+
+	var grouped struct {
+		Styles   []*models.Style   `json:"styles"`
+		Products []*models.Product `json:"products"`
+	}
+	json.Unmarshal(serve(router, "GET", "/product?group=style", nil).Body.Bytes(), &grouped)
+	if len(grouped.Styles) != 1 || len(grouped.Styles[0].Variants) != 18 || len(grouped.Products) != 1 || grouped.Products[0].SKU != 40 {
+		t.Errorf("unexpected grouped products %+v", grouped)
+	}
+	json.Unmarshal(serve(router, "GET", "/product", nil).Body.Bytes(), &prods)
+	if len(prods) != 19 {
+		t.Errorf("ungrouped listing has %v products, want 19", len(prods))
+	}
+
+	for url, want := range map[string]int{
+		"/product?group=color": http.StatusBadRequest,
+		"/styles/9":            http.StatusNotFound,
+		"/styles/x":            http.StatusBadRequest,
+	} {
+		if w := serve(router, "GET", url, nil); w.Code != want {
+			t.Errorf("%v: got %v want %v", url, w.Code, want)
+		}
+	}
+	for body, want := range map[string]int{
+		`{"stylename":""}`:                        http.StatusBadRequest,
+		`{"stylename":"Hat","sizes":["S","S"]}`:   http.StatusBadRequest,
+		`{"stylename":"Hat","negativestock":"x"}`: http.StatusBadRequest,
+	} {
+		if w := serve(router, "POST", "/styles/create", []byte(body)); w.Code != want {
+			t.Errorf("%s: got %v want %v", body, w.Code, want)
+		}
+	}
+	if w := serve(router, "POST", "/product/create", []byte(`{"productname":"Hat","sku":99,"styleid":9}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("product of an unknown style: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+
+	// a style goes once its variants have
+	if w := serve(router, "POST", "/styles/delete/1", nil); w.Code != http.StatusConflict {
+		t.Errorf("delete with variants: got %v want %v", w.Code, http.StatusConflict)
+	}
+	for _, p := range st.Variants {
+		serve(router, "POST", "/product/delete/"+strconv.Itoa(p.SKU), nil)
+	}
+	if w := serve(router, "POST", "/styles/delete/1", nil); w.Code != http.StatusOK {
+		t.Errorf("delete without variants: got %v want %v", w.Code, http.StatusOK)
+	}
+}
+
+func TestStylesNotEnabled(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	for _, url := range []string{"/styles", "/product?group=style"} {
+		if w := serve(router, "GET", url, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%v without a style store: got %v want %v", url, w.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestTransfer(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":5}`))
+	serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":10}`))
+	var showroom models.Location
+	json.Unmarshal(serve(router, "POST", "/locations/create", []byte(`{"warehouse":"SHOWROOM"}`)).Body.Bytes(), &showroom)
+	to := strconv.Itoa(showroom.LocationID)
+
+	level := func() (l models.StockLevel) {
+		json.Unmarshal(serve(router, "GET", "/inventory/5", nil).Body.Bytes(), &l)
+		return l
+	}
+	quantityAt := func(l models.StockLevel, location int) int {
+		for _, i := range l.Locations {
+			if i.LocationID == location {
+				return i.Quantity
+			}
+		}
+		return 0
+	}
+
+	w := serve(router, "POST", "/transfers", []byte(`{"sku":5,"from":1,"to":`+to+`,"quantity":4,"user":"sam"}`))
+	var tr models.Transfer
+	if err := json.Unmarshal(w.Body.Bytes(), &tr); err != nil || tr.TransferID == 0 || tr.Status != models.TransferRequested {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+	id := strconv.Itoa(tr.TransferID)
+	if l := level(); l.OnHand != 10 || l.InTransit != 0 {
+		t.Errorf("a requested transfer moved stock: %+v", l)
+	}
+
+	// on the truck: out of the shop, not yet in the showroom, but still accounted for
+	w = serve(router, "POST", "/transfers/ship/"+id, []byte(`{"user":"sam"}`))
+	json.Unmarshal(w.Body.Bytes(), &tr)
+	if w.Code != http.StatusOK || tr.Status != models.TransferInTransit || tr.DateShipped == "" {
+		t.Fatalf("ship returned %v: %s", w.Code, w.Body.String())
+	}
+	if l := level(); l.OnHand != 6 || l.InTransit != 4 || quantityAt(l, 1) != 6 || quantityAt(l, showroom.LocationID) != 0 {
+		t.Errorf("unexpected stock level in transit %+v", l)
+	}
+	if w := serve(router, "POST", "/transfers/cancel/"+id, nil); w.Code != http.StatusConflict {
+		t.Errorf("cancelling a shipped transfer: got %v want %v", w.Code, http.StatusConflict)
+	}
+
+	w = serve(router, "POST", "/transfers/receive/"+id, nil)
+	json.Unmarshal(w.Body.Bytes(), &tr)
+	if w.Code != http.StatusOK || tr.Status != models.TransferReceived || tr.DateReceived == "" {
+		t.Fatalf("receive returned %v: %s", w.Code, w.Body.String())
+	}
+	if l := level(); l.OnHand != 10 || l.InTransit != 0 || quantityAt(l, showroom.LocationID) != 4 {
+		t.Errorf("unexpected stock level after receiving %+v", l)
+	}
+	if w := serve(router, "POST", "/transfers/receive/"+id, nil); w.Code != http.StatusConflict {
+		t.Errorf("receiving twice: got %v want %v", w.Code, http.StatusConflict)
+	}
+
+	var moves []models.InventoryMovement
+	json.Unmarshal(serve(router, "GET", "/inventory/5/movements", nil).Body.Bytes(), &moves)
+	if len(moves) != 3 || moves[0].Reason != models.ReasonTransfer || moves[0].Delta != 4 || moves[1].Delta != -4 || moves[1].User != "sam" || moves[0].Note != "transfer "+id {
+		t.Errorf("unexpected movements %+v", moves)
+	}
+
+	// straight from one bin to another in one go, and all or nothing when the stock isn't there
+	w = serve(router, "POST", "/transfers", []byte(`{"sku":5,"from":`+to+`,"to":1,"quantity":1,"status":"received"}`))
+	if json.Unmarshal(w.Body.Bytes(), &tr); tr.Status != models.TransferReceived {
+		t.Errorf("immediate transfer returned %v: %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, "POST", "/transfers", []byte(`{"sku":5,"from":`+to+`,"to":1,"quantity":9,"status":"received"}`)); w.Code != http.StatusConflict {
+		t.Errorf("overselling a transfer: got %v want %v", w.Code, http.StatusConflict)
+	}
+	if l := level(); quantityAt(l, 1) != 7 || quantityAt(l, showroom.LocationID) != 3 {
+		t.Errorf("unexpected stock level after the refused transfer %+v", l)
+	}
+
+	var list []models.Transfer
+	json.Unmarshal(serve(router, "GET", "/transfers?sku=5&status=received", nil).Body.Bytes(), &list)
+	if len(list) != 2 || list[0].TransferID <= list[1].TransferID {
+		t.Errorf("unexpected transfer list %+v", list)
+	}
+
+	for body, want := range map[string]int{
+		`{"sku":5,"from":1,"to":1,"quantity":1}`:                      http.StatusBadRequest,
+		`{"sku":5,"from":1,"to":2,"quantity":0}`:                      http.StatusBadRequest,
+		`{"sku":5,"from":1,"to":2,"quantity":1,"status":"cancelled"}`: http.StatusBadRequest,
+		`{"sku":5,"from":1,"to":99,"quantity":1}`:                     http.StatusNotFound,
+		`{"sku":42,"from":1,"to":2,"quantity":1}`:                     http.StatusNotFound,
+	} {
+		if w := serve(router, "POST", "/transfers", []byte(body)); w.Code != want {
+			t.Errorf("%s: got %v want %v", body, w.Code, want)
+		}
+	}
+	if w := serve(router, "GET", "/transfers/99", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown transfer: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestTransferClampShipsAllOrNothing(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","negativestock":"clamp","sku":5}`))
+	var showroom models.Location
+	json.Unmarshal(serve(router, "POST", "/locations/create", []byte(`{"warehouse":"SHOWROOM"}`)).Body.Bytes(), &showroom)
+	to := strconv.Itoa(showroom.LocationID)
+
+	var tr models.Transfer
+	json.Unmarshal(serve(router, "POST", "/transfers", []byte(`{"sku":5,"from":1,"to":`+to+`,"quantity":4}`)).Body.Bytes(), &tr)
+	id := strconv.Itoa(tr.TransferID)
+
+	// nothing on hand, and then only part of it
+	for _, delta := range []int{0, 2} {
+		serve(router, "POST", "/inventory/adjust/5", []byte(`{"delta":`+strconv.Itoa(delta)+`}`))
+		w := serve(router, "POST", "/transfers/ship/"+id, nil)
+		var stockErr models.StockError
+		if json.Unmarshal(w.Body.Bytes(), &stockErr); w.Code != http.StatusConflict || stockErr.Quantity != delta {
+			t.Errorf("shipping 4 of %v: got %v %s", delta, w.Code, w.Body.String())
+		}
+	}
+	json.Unmarshal(serve(router, "GET", "/transfers/"+id, nil).Body.Bytes(), &tr)
+	if tr.Status != models.TransferRequested || tr.Quantity != 4 {
+		t.Errorf("refused shipment changed the transfer %+v", tr)
+	}
+	var moves []models.InventoryMovement
+	json.Unmarshal(serve(router, "GET", "/inventory/5/movements", nil).Body.Bytes(), &moves)
+	for _, m := range moves {
+		if m.Reason == models.ReasonTransfer {
+			t.Errorf("refused shipment left a movement %+v", m)
+		}
+	}
+}
+
+func TestTransferNotEnabled(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	if w := serve(router, "GET", "/transfers", nil); w.Code != http.StatusNotFound {
+		t.Errorf("transfers without a transfer store: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
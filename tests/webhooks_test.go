@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"../models"
+	"../routes"
+	"../webhooks"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+	// "github.com/Xero67/web-fire-family/webhooks"
+)
+
+// receiver is a webhook endpoint that fails the first fail requests and records the rest
+type receiver struct {
+	mu       sync.Mutex
+	fail     int
+	payloads []webhooks.Payload
+	bad      int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.fail > 0 {
+		rc.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Header.Get(webhooks.HeaderSignature) != webhooks.Sign("s3cret", body) {
+		rc.bad++
+	}
+	var p webhooks.Payload
+	json.Unmarshal(body, &p)
+	rc.payloads = append(rc.payloads, p)
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	store := newSQLiteStore(t)
+	router := routes.InitRoutes(store, store)
+	d := webhooks.NewDispatcher(store)
+	d.Backoff = []time.Duration{time.Millisecond, time.Millisecond}
+	routes.SetWebhooks(d)
+	defer routes.SetWebhooks(nil)
+
+	rc := &receiver{fail: 1}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	w := serve(router, "POST", "/webhooks/create", []byte(`{"url":"`+server.URL+`","secret":"s3cret","events":["product.created","inventory.changed"]}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+	var h models.Webhook
+	json.Unmarshal(w.Body.Bytes(), &h)
+	id := strconv.Itoa(h.WebhookID)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing","sku":7}`))
+	d.Wait()
+	serve(router, "POST", "/inventory/increment/7", nil)
+	serve(router, "POST", "/product/delete/7", nil)
+	d.Wait()
+
+	if len(rc.payloads) != 2 || rc.payloads[0].Event != webhooks.ProductCreated || rc.payloads[1].Event != webhooks.InventoryChanged {
+		t.Fatalf("unexpected payloads, the archive isn't subscribed to: %+v", rc.payloads)
+	}
+	if rc.bad != 0 {
+		t.Errorf("%v payloads with a bad signature", rc.bad)
+	}
+	if m, ok := rc.payloads[1].Data.(map[string]interface{}); !ok || m["sku"] != 7.0 || m["quantity"] != 1.0 {
+		t.Errorf("unexpected inventory payload: %+v", rc.payloads[1].Data)
+	}
+
+	// the product.created delivery failed once and was retried
+	w = serve(router, "GET", "/webhooks/"+id+"/deliveries", nil)
+	var deliveries []models.WebhookDelivery
+	if err := json.Unmarshal(w.Body.Bytes(), &deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 3 {
+		t.Fatalf("got %v deliveries, want 3: %+v", len(deliveries), deliveries)
+	}
+	first, retry := deliveries[2], deliveries[1]
+	if first.Attempt != 1 || first.StatusCode != http.StatusServiceUnavailable || first.Error == "" || retry.Attempt != 2 || retry.StatusCode != http.StatusOK || retry.EventID != first.EventID {
+		t.Errorf("unexpected delivery log: %+v", deliveries)
+	}
+}
+
+func TestWebhookCRUD(t *testing.T) {
+	store := newSQLiteStore(t)
+	router := routes.InitRoutes(store, store)
+
+	if w := serve(router, "GET", "/webhooks", nil); w.Code != http.StatusNotFound {
+		t.Errorf("webhooks served without a dispatcher: got %v", w.Code)
+	}
+
+	routes.SetWebhooks(webhooks.NewDispatcher(store))
+	defer routes.SetWebhooks(nil)
+
+	if w := serve(router, "POST", "/webhooks/create", []byte(`{"url":"ftp://erp"}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("non http url accepted: got %v", w.Code)
+	}
+	if w := serve(router, "POST", "/webhooks/create", []byte(`{"url":"http://erp/hook","events":["product.exploded"]}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown event accepted: got %v", w.Code)
+	}
+
+	w := serve(router, "POST", "/webhooks/create", []byte(`{"url":"http://erp/hook"}`))
+	var h models.Webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &h); err != nil || h.WebhookID == 0 || len(h.Secret) != 40 || !h.Active {
+		t.Fatalf("unexpected webhook %+v: %s", h, w.Body.String())
+	}
+	id := strconv.Itoa(h.WebhookID)
+
+	if w := serve(router, "POST", "/webhooks/update/"+id, []byte(`{"url":"https://erp/hook","events":["inventory.changed"],"active":false}`)); w.Code != http.StatusAccepted {
+		t.Fatalf("update returned %v: %s", w.Code, w.Body.String())
+	}
+	w = serve(router, "GET", "/webhooks/"+id, nil)
+	var updated models.Webhook
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if updated.URL != "https://erp/hook" || updated.Active || updated.Secret != "" || len(updated.Events) != 1 {
+		t.Errorf("unexpected webhook after update: %+v", updated)
+	}
+	// the secret is only ever sent back by create
+	if kept, _ := store.GetWebhook(h.WebhookID); kept == nil || kept.Secret != h.Secret {
+		t.Errorf("update without a secret lost the old one: %+v", kept)
+	}
+	if w := serve(router, "GET", "/webhooks", nil); strings.Contains(w.Body.String(), h.Secret) {
+		t.Errorf("listing gives away the secret: %s", w.Body.String())
+	}
+	// saving it again unchanged is still an update of a webhook that exists
+	if w := serve(router, "POST", "/webhooks/update/"+id, []byte(`{"url":"https://erp/hook","events":["inventory.changed"],"active":false}`)); w.Code != http.StatusAccepted {
+		t.Errorf("unchanged update returned %v: %s", w.Code, w.Body.String())
+	}
+
+	if w := serve(router, "POST", "/webhooks/delete/"+id, nil); w.Code != http.StatusOK {
+		t.Errorf("delete returned %v", w.Code)
+	}
+	if w := serve(router, "GET", "/webhooks", nil); w.Body.String() != "[]\n" {
+		t.Errorf("deleted webhook still listed: %s", w.Body.String())
+	}
+	if w := serve(router, "POST", "/webhooks/update/"+id, []byte(`{"url":"https://erp/hook","secret":"x"}`)); w.Code != http.StatusNotFound {
+		t.Errorf("deleted webhook updated: got %v", w.Code)
+	}
+}
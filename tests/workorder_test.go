@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"../models"
+	"../routes"
+	// "github.com/Xero67/web-fire-family/models"
+	// "github.com/Xero67/web-fire-family/routes"
+)
+
+func TestWorkOrder(t *testing.T) {
+	router := newSQLiteRouter(t)
+
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Swing set","sku":1}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Chain","sku":2}`))
+	serve(router, "POST", "/product/create", []byte(`{"productname":"Seat","sku":3}`))
+	serve(router, "POST", "/inventory/adjust/2", []byte(`{"delta":20}`))
+	serve(router, "POST", "/inventory/adjust/3", []byte(`{"delta":5}`))
+	serve(router, "POST", "/product/1/bom", []byte(`{"components":[{"sku":2,"quantity":4},{"sku":3,"quantity":1}]}`))
+
+	available := func(sku int) int {
+		var level models.StockLevel
+		json.Unmarshal(serve(router, "GET", "/inventory/"+strconv.Itoa(sku), nil).Body.Bytes(), &level)
+		return level.Available
+	}
+
+	w := serve(router, "POST", "/workorders/create", []byte(`{"sku":1,"quantity":3,"user":"sam"}`))
+	var o models.WorkOrder
+	if err := json.Unmarshal(w.Body.Bytes(), &o); err != nil || o.WorkOrderID == 0 || o.Status != models.WorkOrderDraft {
+		t.Fatalf("create returned %v: %s", w.Code, w.Body.String())
+	}
+	if len(o.Components) != 2 || o.Components[0].SKU != 2 || o.Components[0].Quantity != 12 || o.Components[1].Quantity != 3 {
+		t.Errorf("unexpected components %+v", o.Components)
+	}
+	id := strconv.Itoa(o.WorkOrderID)
+
+	// releasing holds the components so nobody else sells them
+	w = serve(router, "POST", "/workorders/release/"+id, nil)
+	if json.Unmarshal(w.Body.Bytes(), &o); w.Code != http.StatusOK || o.Status != models.WorkOrderReleased || o.Components[0].ReservationID == 0 {
+		t.Fatalf("release returned %v: %s", w.Code, w.Body.String())
+	}
+	if available(2) != 8 || available(3) != 2 {
+		t.Errorf("components not reserved: %v chains and %v seats available", available(2), available(3))
+	}
+	if w := serve(router, "POST", "/inventory/3/reservations", []byte(`{"quantity":3}`)); w.Code != http.StatusConflict {
+		t.Errorf("reserving components held by a work order: got %v want %v", w.Code, http.StatusConflict)
+	}
+
+	w = serve(router, "POST", "/workorders/complete/"+id, []byte(`{"user":"sam"}`))
+	if json.Unmarshal(w.Body.Bytes(), &o); w.Code != http.StatusOK || o.Status != models.WorkOrderCompleted || o.DateCompleted == "" {
+		t.Fatalf("complete returned %v: %s", w.Code, w.Body.String())
+	}
+	for sku, want := range map[int]int{1: 3, 2: 8, 3: 2} {
+		if got := available(sku); got != want {
+			t.Errorf("sku %v: %v available after completing, want %v", sku, got, want)
+		}
+	}
+	var moves []models.InventoryMovement
+	json.Unmarshal(serve(router, "GET", "/inventory/2/movements", nil).Body.Bytes(), &moves)
+	if moves[0].Reason != models.ReasonAssembly || moves[0].Delta != -12 || moves[0].Note != "work order "+id || moves[0].User != "sam" {
+		t.Errorf("unexpected component movement %+v", moves[0])
+	}
+	json.Unmarshal(serve(router, "GET", "/inventory/1/movements", nil).Body.Bytes(), &moves)
+	if len(moves) != 1 || moves[0].Reason != models.ReasonAssembly || moves[0].Delta != 3 {
+		t.Errorf("unexpected kit movements %+v", moves)
+	}
+	var res []models.Reservation
+	json.Unmarshal(serve(router, "GET", "/inventory/3/reservations", nil).Body.Bytes(), &res)
+	if len(res) != 1 || res[0].Status != models.ReservationConsumed || res[0].Reference != "work order "+id {
+		t.Errorf("unexpected component reservations %+v", res)
+	}
+	for _, url := range []string{"/workorders/complete/" + id, "/workorders/cancel/" + id, "/workorders/release/" + id} {
+		if w := serve(router, "POST", url, nil); w.Code != http.StatusConflict {
+			t.Errorf("%s on a completed work order: got %v want %v", url, w.Code, http.StatusConflict)
+		}
+	}
+
+	// not enough seats for three more, nothing is reserved or used up
+	if w := serve(router, "POST", "/workorders/create", []byte(`{"sku":1,"quantity":3,"status":"completed"}`)); w.Code != http.StatusConflict {
+		t.Errorf("completing without the components: got %v want %v", w.Code, http.StatusConflict)
+	}
+	if available(2) != 8 || available(1) != 3 {
+		t.Errorf("refused work order changed stock: %v chains and %v kits", available(2), available(1))
+	}
+
+	// cancelling hands the reserved components back
+	json.Unmarshal(serve(router, "POST", "/workorders/create", []byte(`{"sku":1,"quantity":2,"status":"released"}`)).Body.Bytes(), &o)
+	if available(3) != 0 {
+		t.Errorf("released work order left %v seats available", available(3))
+	}
+	w = serve(router, "POST", "/workorders/cancel/"+strconv.Itoa(o.WorkOrderID), nil)
+	if json.Unmarshal(w.Body.Bytes(), &o); o.Status != models.WorkOrderCancelled || available(3) != 2 {
+		t.Errorf("cancel returned %v: %s", w.Code, w.Body.String())
+	}
+
+	var list []models.WorkOrder
+	json.Unmarshal(serve(router, "GET", "/workorders?sku=1&status=completed", nil).Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Quantity != 3 {
+		t.Errorf("unexpected work order list %+v", list)
+	}
+
+	for body, want := range map[string]int{
+		`{"sku":2,"quantity":1}`:                      http.StatusBadRequest,
+		`{"sku":1,"quantity":0}`:                      http.StatusBadRequest,
+		`{"sku":1,"quantity":1,"status":"cancelled"}`: http.StatusBadRequest,
+		`{"sku":1,"quantity":1,"location":99}`:        http.StatusNotFound,
+		`{"sku":42,"quantity":1}`:                     http.StatusNotFound,
+	} {
+		if w := serve(router, "POST", "/workorders/create", []byte(body)); w.Code != want {
+			t.Errorf("%s: got %v want %v", body, w.Code, want)
+		}
+	}
+	if w := serve(router, "GET", "/workorders/99", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown work order: got %v want %v", w.Code, http.StatusNotFound)
+	}
+
+	// a kit short of an archived component can't be ordered
+	serve(router, "POST", "/product/delete/3", nil)
+	if w := serve(router, "POST", "/workorders/create", []byte(`{"sku":1,"quantity":1}`)); w.Code != http.StatusConflict {
+		t.Errorf("archived component: got %v want %v", w.Code, http.StatusConflict)
+	}
+}
+
+func TestWorkOrderNotEnabled(t *testing.T) {
+	store := newMemStore()
+	router := routes.InitRoutes(store, store)
+
+	if w := serve(router, "GET", "/workorders", nil); w.Code != http.StatusNotFound {
+		t.Errorf("work orders without a work order store: got %v want %v", w.Code, http.StatusNotFound)
+	}
+}
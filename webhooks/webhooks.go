@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"../models"
+	// "github.com/Xero67/web-fire-family/models"
+)
+
+// Events a webhook can subscribe to
+const (
+	ProductCreated   = "product.created"
+	ProductUpdated   = "product.updated"
+	ProductArchived  = "product.archived"
+	InventoryChanged = "inventory.changed"
+)
+
+// ValidEvent reports whether event is one of the events above.
+func ValidEvent(event string) bool {
+	switch event {
+	case ProductCreated, ProductUpdated, ProductArchived, InventoryChanged:
+		return true
+	}
+	return false
+}
+
+// Payload is the JSON body POSTed to a webhook. Data is the product for product events and the
+// movement for inventory.changed.
+type Payload struct {
+	ID          string      `json:"id"`
+	Event       string      `json:"event"`
+	DateCreated string      `json:"datecreated"`
+	Data        interface{} `json:"data"`
+}
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256 of the body, keyed with the
+// webhook's secret, so the receiver can check the payload came from us.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the value of the signature header for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random secret for a webhook registered without one.
+func NewSecret() string {
+	return randomHex(20)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Dispatcher delivers events to the webhooks in Store. Deliveries run in the background, a failed one
+// is retried after each wait in Backoff in turn, and every attempt is written to the delivery log.
+// Retries are held in memory, anything still pending when the process stops is not sent.
+type Dispatcher struct {
+	Store   models.WebhookStore
+	Client  *http.Client
+	Backoff []time.Duration
+
+	wg sync.WaitGroup
+}
+
+// NewDispatcher returns a Dispatcher that tries each delivery five times over roughly a quarter of an hour.
+func NewDispatcher(store models.WebhookStore) *Dispatcher {
+	return &Dispatcher{
+		Store:   store,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		Backoff: []time.Duration{time.Second, 10 * time.Second, time.Minute, 10 * time.Minute},
+	}
+}
+
+// Publish sends event to every active webhook subscribed to it. It returns straight away.
+func (d *Dispatcher) Publish(event string, data interface{}) {
+	p := Payload{ID: randomHex(16), Event: event, DateCreated: time.Now().Format(time.RFC3339), Data: data}
+	body, err := json.Marshal(p)
+	if err != nil {
+		fmt.Println("webhooks.go - Publish - unable to encode " + event)
+		fmt.Println(err)
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		hooks, err := d.Store.ListWebhooks()
+		if err != nil {
+			fmt.Println("webhooks.go - Publish - ListWebhooks error")
+			fmt.Println(err)
+			return
+		}
+		for _, h := range hooks {
+			if h.Wants(event) {
+				d.wg.Add(1)
+				go d.deliver(h, p, body)
+			}
+		}
+	}()
+}
+
+// Wait blocks until every delivery started so far has succeeded or run out of retries.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(h *models.Webhook, p Payload, body []byte) {
+	defer d.wg.Done()
+	for attempt := 1; ; attempt++ {
+		status, err := d.post(h, p, body)
+
+		delivery := &models.WebhookDelivery{WebhookID: h.WebhookID, EventID: p.ID, Event: p.Event, Payload: string(body), Attempt: attempt, StatusCode: status}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if logErr := d.Store.RecordDelivery(delivery); logErr != nil {
+			fmt.Println("webhooks.go - deliver - RecordDelivery error for webhook: " + strconv.Itoa(h.WebhookID))
+			fmt.Println(logErr)
+		}
+
+		if err == nil || attempt > len(d.Backoff) {
+			return
+		}
+		time.Sleep(d.Backoff[attempt-1])
+	}
+}
+
+// post makes one delivery attempt. Anything but a 2xx answer counts as a failure.
+func (d *Dispatcher) post(h *models.Webhook, p Payload, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, p.Event)
+	req.Header.Set(HeaderID, p.ID)
+	req.Header.Set(HeaderSignature, Sign(h.Secret, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %v", resp.Status)
+	}
+	return resp.StatusCode, nil
+}